@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/danpilch/kong-route-tester/kong"
+	"github.com/spf13/pflag"
+)
+
+// Authentication matrix flags
+var (
+	authMatrix   = pflag.Bool("auth-matrix", true, "Exercise protected routes without, with invalid, with expired and with valid credentials (--auth-matrix=false to send only the configured credentials)")
+	invalidToken = pflag.String("invalid-token", "kong-route-tester-invalid-token", "Garbage bearer token sent by the auth matrix")
+)
+
+// Credential modes used when testing protected routes. An empty mode sends the
+// configured credentials when there are any.
+const (
+	authNone    = "none"
+	authInvalid = "invalid"
+	authExpired = "expired"
+	authValid   = "valid"
+)
+
+// authModes returns the credential modes to exercise for a route
+func authModes(requiresAuth bool) []string {
+	if !requiresAuth || !*authMatrix {
+		return []string{""}
+	}
+
+	modes := []string{authNone, authInvalid, authExpired}
+	if hasCredentials() {
+		modes = append(modes, authValid)
+	}
+	return modes
+}
+
+// credentialVariant is one way of presenting credentials to a route
+type credentialVariant struct {
+	authMode string
+	certMode string
+}
+
+// credentialVariants returns every combination of credentials to send to a
+// route. Negative client certificate checks are sent with valid credentials so
+// that only the certificate differs.
+func credentialVariants(route Route, service Service, requiresAuth bool) []credentialVariant {
+	var variants []credentialVariant
+	for _, mode := range authModes(requiresAuth) {
+		variants = append(variants, credentialVariant{authMode: mode})
+	}
+	for _, mode := range certModes(route, service) {
+		variants = append(variants, credentialVariant{certMode: mode})
+	}
+	return variants
+}
+
+// expectsRejection reports whether a result was sent with credentials the
+// gateway should reject
+func expectsRejection(result TestResult) bool {
+	switch result.AuthMode {
+	case authNone, authInvalid, authExpired:
+		return true
+	}
+	return result.CertMode == certNone || result.CertMode == certUntrusted
+}
+
+// hasCredentials reports whether valid credentials can be sent to protected routes
+func hasCredentials() bool {
+	return *authToken != "" || oauth != nil
+}
+
+// bearerFor returns the bearer token to send for a credential mode
+func bearerFor(service, route, authMode string) (string, error) {
+	switch authMode {
+	case authNone:
+		return "", nil
+	case authInvalid:
+		return *invalidToken, nil
+	case authExpired:
+		return kong.ExpiredJWT, nil
+	default:
+		return credentialFor(service, route)
+	}
+}
+
+// authViolation describes how a result from the auth matrix deviated from the
+// expected status. accepted is true when the gateway let bad credentials through.
+func authViolation(result TestResult) (violation string, accepted bool) {
+	if result.Error != nil || result.StatusCode == 0 {
+		// Failed requests are failures rather than violations, apart from
+		// client certificates refused during the TLS handshake
+		return "", false
+	}
+
+	code := result.StatusCode
+	if result.CertMode != "" {
+		if code < 400 {
+			return fmt.Sprintf("accepted %s client certificate (%d)", result.CertMode, code), true
+		}
+		if code != 400 && code != 401 && code != 403 {
+			return fmt.Sprintf("expected 400/401/403 with %s client certificate, got %d", result.CertMode, code), false
+		}
+		return "", false
+	}
+
+	switch result.AuthMode {
+	case authNone:
+		if code == 401 {
+			return "", false
+		}
+		if code < 400 {
+			return fmt.Sprintf("accepted without credentials (%d)", code), true
+		}
+		return fmt.Sprintf("expected 401 without credentials, got %d", code), false
+	case authInvalid, authExpired:
+		if code == 401 || code == 403 {
+			return "", false
+		}
+		if code < 400 {
+			return fmt.Sprintf("accepted %s credentials (%d)", result.AuthMode, code), true
+		}
+		return fmt.Sprintf("expected 401/403 with %s credentials, got %d", result.AuthMode, code), false
+	case authValid:
+		if code == 401 {
+			return "valid credentials rejected (401)", false
+		}
+	}
+
+	return "", false
+}
+
+// acceptedBadCredentials reports whether the gateway let any request with
+// missing or bad credentials through
+func acceptedBadCredentials(results []TestResult) bool {
+	for _, result := range results {
+		if _, accepted := authViolation(result); accepted {
+			return true
+		}
+	}
+	return false
+}
+
+func printAuthMatrixSummary(results []TestResult) {
+	var accepted, mismatched []string
+	tested := 0
+
+	for _, result := range results {
+		mode := result.AuthMode
+		if result.CertMode != "" {
+			mode = "cert:" + result.CertMode
+		}
+		if mode == "" {
+			continue
+		}
+		tested++
+
+		violation, bad := authViolation(result)
+		if violation == "" {
+			continue
+		}
+		line := fmt.Sprintf("  - %s %s (%s) [%s]: %s", result.Method, result.Path, result.Service, mode, violation)
+		if bad {
+			accepted = append(accepted, line)
+		} else {
+			mismatched = append(mismatched, line)
+		}
+	}
+
+	if tested == 0 {
+		return
+	}
+
+	fmt.Printf("\nAuthentication Enforcement (%d checks):\n", tested)
+	fmt.Printf("  Bad credentials accepted: %d\n", len(accepted))
+	fmt.Printf("  Unexpected responses:     %d\n", len(mismatched))
+
+	if len(accepted) > 0 {
+		fmt.Println("\nSECURITY: Gateway accepted missing or bad credentials:")
+		for _, line := range accepted {
+			fmt.Println(line)
+		}
+	}
+	if len(mismatched) > 0 {
+		fmt.Println("\nUnexpected authentication responses:")
+		for _, line := range mismatched {
+			fmt.Println(line)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestAuthModes(t *testing.T) {
+	defer func() {
+		*authMatrix = true
+		*authToken = ""
+	}()
+
+	tests := []struct {
+		name         string
+		matrix       bool
+		token        string
+		requiresAuth bool
+		expected     []string
+	}{
+		{
+			name:         "matrix disabled",
+			matrix:       false,
+			token:        "token",
+			requiresAuth: true,
+			expected:     []string{""},
+		},
+		{
+			name:         "unauthenticated route",
+			matrix:       true,
+			token:        "token",
+			requiresAuth: false,
+			expected:     []string{""},
+		},
+		{
+			name:         "protected route with credentials",
+			matrix:       true,
+			token:        "token",
+			requiresAuth: true,
+			expected:     []string{authNone, authInvalid, authExpired, authValid},
+		},
+		{
+			name:         "protected route without credentials",
+			matrix:       true,
+			token:        "",
+			requiresAuth: true,
+			expected:     []string{authNone, authInvalid, authExpired},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*authMatrix = tt.matrix
+			*authToken = tt.token
+
+			result := authModes(tt.requiresAuth)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("authModes() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAuthViolation(t *testing.T) {
+	tests := []struct {
+		name             string
+		result           TestResult
+		expectViolation  bool
+		expectedAccepted bool
+	}{
+		{
+			name:   "legacy result is never a violation",
+			result: TestResult{StatusCode: 200},
+		},
+		{
+			name:   "no credentials rejected with 401",
+			result: TestResult{AuthMode: authNone, StatusCode: 401},
+		},
+		{
+			name:             "no credentials accepted",
+			result:           TestResult{AuthMode: authNone, StatusCode: 200},
+			expectViolation:  true,
+			expectedAccepted: true,
+		},
+		{
+			name:            "no credentials with wrong rejection code",
+			result:          TestResult{AuthMode: authNone, StatusCode: 404},
+			expectViolation: true,
+		},
+		{
+			name:   "invalid credentials forbidden",
+			result: TestResult{AuthMode: authInvalid, StatusCode: 403},
+		},
+		{
+			name:             "expired credentials accepted",
+			result:           TestResult{AuthMode: authExpired, StatusCode: 302},
+			expectViolation:  true,
+			expectedAccepted: true,
+		},
+		{
+			name:   "valid credentials accepted",
+			result: TestResult{AuthMode: authValid, StatusCode: 200},
+		},
+		{
+			name:            "valid credentials rejected",
+			result:          TestResult{AuthMode: authValid, StatusCode: 401},
+			expectViolation: true,
+		},
+		{
+			name:   "request errors are not violations",
+			result: TestResult{AuthMode: authNone, Error: errors.New("connection refused")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violation, accepted := authViolation(tt.result)
+			if (violation != "") != tt.expectViolation {
+				t.Errorf("authViolation() = %q, want violation %v", violation, tt.expectViolation)
+			}
+			if accepted != tt.expectedAccepted {
+				t.Errorf("authViolation() accepted = %v, want %v", accepted, tt.expectedAccepted)
+			}
+		})
+	}
+}
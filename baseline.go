@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// Baseline flags
+var (
+	saveBaseline      = pflag.String("save-baseline", "", "Write this run's results to a baseline file")
+	baselineFile      = pflag.String("baseline", "", "Compare this run with a baseline file; only regressions fail the run")
+	latencyRegression = pflag.Float64("latency-regression", 50, "Percent a route's p95 latency may grow over the baseline before it is a regression")
+	latencyMinimum    = pflag.Duration("latency-regression-min", 100*time.Millisecond, "Smallest p95 latency growth reported as a regression")
+)
+
+// baselineVersion is the baseline file format this build writes
+const baselineVersion = 1
+
+// Baseline is a saved run to compare later runs with
+type Baseline struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	URL     string    `json:"url"`
+
+	// Routes in the Kong configuration, as service/route
+	Routes  []string         `json:"routes"`
+	Results []BaselineResult `json:"results"`
+}
+
+// BaselineResult is the outcome of one request in a baseline
+type BaselineResult struct {
+	Service    string  `json:"service"`
+	Route      string  `json:"route"`
+	Path       string  `json:"path"`
+	Method     string  `json:"method"`
+	AuthMode   string  `json:"auth_mode,omitempty"`
+	CertMode   string  `json:"cert_mode,omitempty"`
+	Operation  string  `json:"operation,omitempty"`
+	Probe      bool    `json:"probe,omitempty"`
+	StatusCode int     `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	Passed     bool    `json:"passed"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// key identifies the request across runs
+func (r BaselineResult) key() string {
+	return strings.Join([]string{r.Service, r.Route, r.Method, r.Path, r.AuthMode, r.CertMode, r.Operation, fmt.Sprint(r.Probe)}, "\x00")
+}
+
+// describe names the request in reports
+func (r BaselineResult) describe() string {
+	s := fmt.Sprintf("%s %s (%s)", r.Method, r.Path, r.Route)
+	if r.AuthMode != "" {
+		s += " [AUTH:" + r.AuthMode + "]"
+	}
+	if r.CertMode != "" {
+		s += " [CERT:" + r.CertMode + "]"
+	}
+	if r.Operation != "" {
+		s += " [" + r.Operation + "]"
+	}
+	if r.Probe {
+		s += " [PROBE]"
+	}
+	return s
+}
+
+// outcome is the status code, or the error when there was no response
+func (r BaselineResult) outcome() string {
+	if r.StatusCode == 0 && r.Error != "" {
+		return "error: " + truncate(r.Error, 50)
+	}
+	return fmt.Sprint(r.StatusCode)
+}
+
+func newBaseline(config *KongConfig, results []TestResult) *Baseline {
+	baseline := &Baseline{
+		Version: baselineVersion,
+		Created: time.Now().UTC().Truncate(time.Second),
+		URL:     *baseURL,
+		Routes:  []string{},
+		Results: make([]BaselineResult, 0, len(results)),
+	}
+	for _, service := range config.Services {
+		for _, route := range service.Routes {
+			baseline.Routes = append(baseline.Routes, service.Name+"/"+route.Name)
+		}
+	}
+	slices.Sort(baseline.Routes)
+
+	for _, result := range results {
+		r := BaselineResult{
+			Service:    result.Service,
+			Route:      result.Route,
+			Path:       result.Path,
+			Method:     result.Method,
+			AuthMode:   result.AuthMode,
+			CertMode:   result.CertMode,
+			Operation:  result.Operation,
+			Probe:      result.Probe,
+			StatusCode: result.StatusCode,
+			Passed:     resultPassed(result),
+			DurationMS: float64(result.Timings.Total) / float64(time.Millisecond),
+		}
+		if result.Error != nil {
+			r.Error = result.Error.Error()
+		}
+		baseline.Results = append(baseline.Results, r)
+	}
+	return baseline
+}
+
+func writeBaseline(filename string, baseline *Baseline) error {
+	data, err := json.MarshalIndent(baseline, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0644)
+}
+
+func loadBaseline(filename string) (*Baseline, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var baseline Baseline
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if baseline.Version != baselineVersion {
+		return nil, fmt.Errorf("%s: unsupported baseline version %d (this build reads version %d)", filename, baseline.Version, baselineVersion)
+	}
+	return &baseline, nil
+}
+
+// resultChange is a request whose outcome differs from the baseline
+type resultChange struct {
+	Before *BaselineResult // nil for requests the baseline lacks
+	After  BaselineResult
+}
+
+func (c resultChange) String() string {
+	if c.Before == nil {
+		return fmt.Sprintf("%s: new, %s", c.After.describe(), c.After.outcome())
+	}
+	return fmt.Sprintf("%s: %s → %s", c.After.describe(), c.Before.outcome(), c.After.outcome())
+}
+
+// latencyChange is a route whose p95 latency grew past the threshold
+type latencyChange struct {
+	Route  string
+	Before time.Duration
+	After  time.Duration
+}
+
+// BaselineDiff is how a run differs from a baseline
+type BaselineDiff struct {
+	NewFailures   []resultChange
+	Fixed         []resultChange
+	StatusChanges []resultChange // Outcome changed but still passing or still failing
+	Slower        []latencyChange
+	RoutesAdded   []string
+	RoutesRemoved []string
+}
+
+// Regressions counts the differences that fail a run
+func (d BaselineDiff) Regressions() int {
+	return len(d.NewFailures) + len(d.Slower)
+}
+
+// compareBaselines compares a run with a baseline. Routes count as slower
+// when their p95 latency grew by more than threshold percent and minimum.
+func compareBaselines(before, after *Baseline, threshold float64, minimum time.Duration) BaselineDiff {
+	var diff BaselineDiff
+
+	previous := make(map[string]*BaselineResult, len(before.Results))
+	for i := range before.Results {
+		previous[before.Results[i].key()] = &before.Results[i]
+	}
+	for _, r := range after.Results {
+		was := previous[r.key()]
+		change := resultChange{Before: was, After: r}
+		switch {
+		case was == nil:
+			if !r.Passed {
+				diff.NewFailures = append(diff.NewFailures, change)
+			}
+		case was.Passed && !r.Passed:
+			diff.NewFailures = append(diff.NewFailures, change)
+		case !was.Passed && r.Passed:
+			diff.Fixed = append(diff.Fixed, change)
+		case was.outcome() != r.outcome():
+			diff.StatusChanges = append(diff.StatusChanges, change)
+		}
+	}
+
+	beforeLatency, afterLatency := baselineLatencies(before), baselineLatencies(after)
+	for route, p95 := range afterLatency {
+		was, ok := beforeLatency[route]
+		if !ok {
+			continue
+		}
+		if grew := p95 - was; grew >= minimum && float64(grew) > float64(was)*threshold/100 {
+			diff.Slower = append(diff.Slower, latencyChange{Route: route, Before: was, After: p95})
+		}
+	}
+	slices.SortFunc(diff.Slower, func(a, b latencyChange) int { return strings.Compare(a.Route, b.Route) })
+
+	for _, route := range after.Routes {
+		if !slices.Contains(before.Routes, route) {
+			diff.RoutesAdded = append(diff.RoutesAdded, route)
+		}
+	}
+	for _, route := range before.Routes {
+		if !slices.Contains(after.Routes, route) {
+			diff.RoutesRemoved = append(diff.RoutesRemoved, route)
+		}
+	}
+	return diff
+}
+
+// baselineLatencies returns the p95 duration of each route's answered requests
+func baselineLatencies(baseline *Baseline) map[string]time.Duration {
+	durations := make(map[string][]time.Duration)
+	for _, r := range baseline.Results {
+		if r.StatusCode != 0 {
+			route := fmt.Sprintf("%s (%s)", r.Route, r.Service)
+			durations[route] = append(durations[route], time.Duration(r.DurationMS*float64(time.Millisecond)))
+		}
+	}
+	p95 := make(map[string]time.Duration, len(durations))
+	for route, d := range durations {
+		p95[route] = percentile(d, 95)
+	}
+	return p95
+}
+
+func printBaselineDiff(name string, baseline *Baseline, diff BaselineDiff) {
+	fmt.Printf("\nCompared With Baseline (%s, %s):\n", name, baseline.Created.Local().Format("2006-01-02 15:04"))
+	fmt.Printf("  New failures:        %d\n", len(diff.NewFailures))
+	fmt.Printf("  Fixed:               %d\n", len(diff.Fixed))
+	fmt.Printf("  Status changes:      %d\n", len(diff.StatusChanges))
+	fmt.Printf("  Latency regressions: %d\n", len(diff.Slower))
+	fmt.Printf("  Routes added:        %d\n", len(diff.RoutesAdded))
+	fmt.Printf("  Routes removed:      %d\n", len(diff.RoutesRemoved))
+
+	printChanges := func(heading string, changes []resultChange) {
+		if len(changes) == 0 {
+			return
+		}
+		fmt.Println("\n" + heading)
+		for _, change := range changes {
+			fmt.Printf("  - %s\n", change)
+		}
+	}
+	printChanges("REGRESSION: New failures:", diff.NewFailures)
+	if len(diff.Slower) > 0 {
+		fmt.Println("\nREGRESSION: Slower routes (p95):")
+		for _, change := range diff.Slower {
+			fmt.Printf("  - %s: %s → %s\n", change.Route, change.Before.Round(time.Millisecond), change.After.Round(time.Millisecond))
+		}
+	}
+	printChanges("Fixed:", diff.Fixed)
+	printChanges("Status changes:", diff.StatusChanges)
+	for _, routes := range []struct {
+		heading string
+		names   []string
+	}{{"Routes added:", diff.RoutesAdded}, {"Routes removed:", diff.RoutesRemoved}} {
+		if len(routes.names) == 0 {
+			continue
+		}
+		fmt.Println("\n" + routes.heading)
+		for _, name := range routes.names {
+			fmt.Printf("  - %s\n", name)
+		}
+	}
+}
+
+// runCompare compares two saved baselines without sending requests
+func runCompare(args []string) int {
+	flags := pflag.NewFlagSet("compare", pflag.ContinueOnError)
+	threshold := flags.Float64("latency-regression", 50, "Percent a route's p95 latency may grow before it is a regression")
+	minimum := flags.Duration("latency-regression-min", 100*time.Millisecond, "Smallest p95 latency growth reported as a regression")
+	flags.Usage = func() {
+		fmt.Println("Usage: kong-route-tester compare BASELINE CURRENT")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	before, err := loadBaseline(flags.Arg(0))
+	if err != nil {
+		fmt.Printf("Error reading baseline: %v\n", err)
+		return 1
+	}
+	after, err := loadBaseline(flags.Arg(1))
+	if err != nil {
+		fmt.Printf("Error reading baseline: %v\n", err)
+		return 1
+	}
+
+	diff := compareBaselines(before, after, *threshold, *minimum)
+	printBaselineDiff(flags.Arg(0), before, diff)
+	if diff.Regressions() > 0 {
+		return 1
+	}
+	return 0
+}
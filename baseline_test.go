@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBaselineRoundTrip(t *testing.T) {
+	config := &KongConfig{Services: []Service{{
+		Name:   "api",
+		Routes: []Route{{Name: "users"}, {Name: "health"}},
+	}}}
+	results := []TestResult{
+		{Service: "api", Route: "users", Path: "/users", Method: "GET", StatusCode: http.StatusOK, Timings: Timings{Total: 1500 * time.Microsecond}},
+		{Service: "api", Route: "users", Path: "/users", Method: "GET", RequiresAuth: true, AuthMode: authNone, StatusCode: http.StatusOK},
+		{Service: "api", Route: "health", Path: "/health", Method: "GET", Error: errors.New("connection refused")},
+	}
+
+	filename := filepath.Join(t.TempDir(), "baseline.json")
+	if err := writeBaseline(filename, newBaseline(config, results)); err != nil {
+		t.Fatal(err)
+	}
+	baseline, err := loadBaseline(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(baseline.Routes, []string{"api/health", "api/users"}) {
+		t.Errorf("routes = %q", baseline.Routes)
+	}
+	if len(baseline.Results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(baseline.Results))
+	}
+	if r := baseline.Results[0]; !r.Passed || r.DurationMS != 1.5 {
+		t.Errorf("expected a passing 1.5ms result, got %+v", r)
+	}
+	if r := baseline.Results[1]; r.Passed {
+		t.Errorf("expected a protected route answering without credentials to fail, got %+v", r)
+	}
+	if r := baseline.Results[2]; r.Passed || r.outcome() != "error: connection refused" {
+		t.Errorf("expected the error to be kept, got %+v", r)
+	}
+}
+
+func TestLoadBaselineVersion(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "baseline.json")
+	if err := writeBaseline(filename, &Baseline{Version: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadBaseline(filename); err == nil || !strings.Contains(err.Error(), "unsupported baseline version 2") {
+		t.Errorf("expected a version error, got %v", err)
+	}
+}
+
+func TestCompareBaselines(t *testing.T) {
+	result := func(route, method string, status int, passed bool, ms float64) BaselineResult {
+		return BaselineResult{Service: "api", Route: route, Path: "/" + route, Method: method, StatusCode: status, Passed: passed, DurationMS: ms}
+	}
+	before := &Baseline{
+		Routes: []string{"api/broken", "api/fixed", "api/old", "api/slow", "api/users"},
+		Results: []BaselineResult{
+			result("users", "GET", 200, true, 10),
+			result("users", "POST", 201, true, 10),
+			result("users", "DELETE", 500, false, 10),
+			result("fixed", "GET", 502, false, 10),
+			result("broken", "GET", 404, false, 10),
+			result("slow", "GET", 200, true, 100),
+			result("old", "GET", 200, true, 10),
+		},
+	}
+	after := &Baseline{
+		Routes: []string{"api/broken", "api/fixed", "api/new", "api/slow", "api/users"},
+		Results: []BaselineResult{
+			result("users", "GET", 200, true, 10),
+			result("users", "POST", 500, false, 10),
+			result("users", "DELETE", 503, false, 10),
+			result("fixed", "GET", 200, true, 10),
+			result("broken", "GET", 404, false, 10),
+			result("slow", "GET", 200, true, 400),
+			result("new", "GET", 401, false, 10),
+			result("new", "HEAD", 200, true, 10),
+		},
+	}
+
+	diff := compareBaselines(before, after, 50, 100*time.Millisecond)
+
+	describe := func(changes []resultChange) []string {
+		var lines []string
+		for _, change := range changes {
+			lines = append(lines, change.String())
+		}
+		return lines
+	}
+	if got := describe(diff.NewFailures); !slices.Equal(got, []string{"POST /users (users): 201 → 500", "GET /new (new): new, 401"}) {
+		t.Errorf("new failures = %q", got)
+	}
+	if got := describe(diff.Fixed); !slices.Equal(got, []string{"GET /fixed (fixed): 502 → 200"}) {
+		t.Errorf("fixed = %q", got)
+	}
+	if got := describe(diff.StatusChanges); !slices.Equal(got, []string{"DELETE /users (users): 500 → 503"}) {
+		t.Errorf("status changes = %q", got)
+	}
+	if len(diff.Slower) != 1 || diff.Slower[0].Route != "slow (api)" || diff.Slower[0].After != 400*time.Millisecond {
+		t.Errorf("slower = %+v", diff.Slower)
+	}
+	if !slices.Equal(diff.RoutesAdded, []string{"api/new"}) || !slices.Equal(diff.RoutesRemoved, []string{"api/old"}) {
+		t.Errorf("added %q, removed %q", diff.RoutesAdded, diff.RoutesRemoved)
+	}
+	if diff.Regressions() != 3 {
+		t.Errorf("expected 3 regressions, got %d", diff.Regressions())
+	}
+
+	// Growth under the minimum is noise, however large in percent
+	if diff := compareBaselines(before, after, 50, time.Second); len(diff.Slower) != 0 {
+		t.Errorf("expected no latency regressions under the minimum, got %+v", diff.Slower)
+	}
+}
+
+func TestRunCompare(t *testing.T) {
+	dir := t.TempDir()
+	before, after := filepath.Join(dir, "before.json"), filepath.Join(dir, "after.json")
+	passing := BaselineResult{Service: "api", Route: "users", Path: "/users", Method: "GET", StatusCode: 200, Passed: true}
+	failing := passing
+	failing.StatusCode, failing.Passed = 500, false
+
+	tests := []struct {
+		name          string
+		before, after BaselineResult
+		want          int
+	}{
+		{"unchanged", passing, passing, 0},
+		{"fixed", failing, passing, 0},
+		{"still failing", failing, failing, 0},
+		{"regressed", passing, failing, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := writeBaseline(before, &Baseline{Version: baselineVersion, Results: []BaselineResult{tt.before}}); err != nil {
+				t.Fatal(err)
+			}
+			if err := writeBaseline(after, &Baseline{Version: baselineVersion, Results: []BaselineResult{tt.after}}); err != nil {
+				t.Fatal(err)
+			}
+			if got := runCompare([]string{before, after}); got != tt.want {
+				t.Errorf("runCompare = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if got := runCompare([]string{before}); got != 2 {
+		t.Errorf("expected usage error with one file, got %d", got)
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/danpilch/kong-route-tester/kong"
+	"github.com/spf13/pflag"
+)
+
+// Auth bypass probe flags
+var (
+	bypassProbes = pflag.Bool("bypass-probes", false, "Send path and header variants of protected routes without credentials and report any that succeed")
+)
+
+// BypassProbe is one variant of a protected request sent without credentials
+type BypassProbe struct {
+	Service    string
+	Route      string
+	Path       string // Path of the protected route
+	Method     string
+	Variant    string
+	ProbePath  string // Path actually requested
+	Header     string // Header added by the variant, as "Name: value"
+	StatusCode int
+	Error      error
+
+	// RoutedTo names the unprotected route Kong is expected to select for
+	// the probe path, empty when the protected route or no route matches
+	RoutedTo string
+}
+
+// Succeeded reports whether the probe got a success response without credentials
+func (p BypassProbe) Succeeded() bool {
+	return p.Error == nil && p.StatusCode >= 200 && p.StatusCode < 300
+}
+
+// Bypassed reports whether the protected route itself answered the probe
+func (p BypassProbe) Bypassed() bool {
+	return p.Succeeded() && p.RoutedTo == ""
+}
+
+// bypassFound reports whether any probe got past a protected route
+func bypassFound(probes []BypassProbe) bool {
+	for _, probe := range probes {
+		if probe.Bypassed() {
+			return true
+		}
+	}
+	return false
+}
+
+// bypassVariant rewrites a protected request in a way that has let requests
+// past authentication in gateways or the upstreams behind them
+type bypassVariant struct {
+	name   string
+	path   func(p string) string
+	header [2]string
+}
+
+var bypassVariants = []bypassVariant{
+	{name: "trailing slash", path: func(p string) string { return strings.TrimSuffix(p, "/") + "/" }},
+	{name: "leading double slash", path: func(p string) string { return "/" + p }},
+	{name: "inner double slash", path: func(p string) string { return replaceSeparator(p, "//") }},
+	{name: "upper case", path: strings.ToUpper},
+	{name: "mixed case", path: swapLastSegmentCase},
+	{name: "encoded character", path: encodeLastSegment},
+	{name: "encoded slash", path: func(p string) string { return replaceSeparator(p, "%2F") }},
+	{name: "dot segment", path: func(p string) string { return replaceSeparator(p, "/./") }},
+	{name: "dot-dot-semicolon", path: func(p string) string { return "/kong-route-tester/..;" + p }},
+	{name: "path parameter", path: func(p string) string { return p + ";kong-route-tester=1" }},
+	{name: "segment parameter", path: func(p string) string { return replaceSeparator(p, ";/") }},
+	{name: "method override", header: [2]string{"X-HTTP-Method-Override", "OPTIONS"}},
+	{name: "method override", header: [2]string{"X-HTTP-Method", "OPTIONS"}},
+	{name: "method override", header: [2]string{"X-Method-Override", "OPTIONS"}},
+	{name: "original URL", header: [2]string{"X-Original-URL", "/"}},
+	{name: "rewrite URL", header: [2]string{"X-Rewrite-URL", "/"}},
+	{name: "forwarded for", header: [2]string{"X-Forwarded-For", "127.0.0.1"}},
+	{name: "real IP", header: [2]string{"X-Real-IP", "127.0.0.1"}},
+	{name: "forwarded host", header: [2]string{"X-Forwarded-Host", "localhost"}},
+	{name: "forwarded prefix", header: [2]string{"X-Forwarded-Prefix", "/"}},
+}
+
+// replaceSeparator replaces the last slash of a path with sep, leaving paths
+// with a single segment unchanged
+func replaceSeparator(p, sep string) string {
+	i := strings.LastIndex(strings.TrimSuffix(p, "/"), "/")
+	if i <= 0 {
+		return p
+	}
+	return p[:i] + sep + p[i+1:]
+}
+
+// lastSegmentStart returns the index of the first letter in the last segment
+func lastSegmentStart(p string) int {
+	for i := strings.LastIndex(strings.TrimSuffix(p, "/"), "/") + 1; i < len(p); i++ {
+		if c := p[i] | 0x20; c >= 'a' && c <= 'z' {
+			return i
+		}
+	}
+	return -1
+}
+
+// swapLastSegmentCase swaps the case of the first letter of the last segment
+func swapLastSegmentCase(p string) string {
+	i := lastSegmentStart(p)
+	if i < 0 {
+		return p
+	}
+	return p[:i] + string(p[i]^0x20) + p[i+1:]
+}
+
+// encodeLastSegment percent-encodes the first letter of the last segment
+func encodeLastSegment(p string) string {
+	i := lastSegmentStart(p)
+	if i < 0 {
+		return p
+	}
+	return p[:i] + fmt.Sprintf("%%%02X", p[i]) + p[i+1:]
+}
+
+// normalizePath approximates the normalization Kong applies before routing:
+// percent-decoding everything but slashes and removing dot segments. Repeated
+// slashes are kept, as Kong does not merge them.
+func normalizePath(p string) string {
+	p = strings.NewReplacer("%2F", "%252F", "%2f", "%252f").Replace(p)
+	if decoded, err := url.PathUnescape(p); err == nil {
+		p = decoded
+	}
+
+	segments := strings.Split(p, "/")
+	out := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+		case "..":
+			if len(out) > 1 {
+				out = out[:len(out)-1]
+			}
+			if last {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, segment)
+		}
+	}
+	return strings.Join(out, "/")
+}
+
+// testBypasses sends every bypass variant to each path of every protected route
+func testBypasses(ctx context.Context, config *KongConfig) []BypassProbe {
+	router := kong.NewRouter(config)
+
+	var probes []BypassProbe
+	for _, service := range config.Services {
+		if skipService(service) {
+			continue
+		}
+		for _, route := range service.Routes {
+			if !routeSelected(service, route) || !hasAuthPlugin(route, service) {
+				continue
+			}
+
+			for _, pattern := range route.Paths {
+				p, params := expandRegexPathParams(pattern)
+				target := requestTarget{
+					Service:      service,
+					Route:        route,
+					Pattern:      pattern,
+					Params:       params,
+					Method:       preferredMethod(route),
+					RequiresAuth: true,
+					Plugins:      config.EffectivePlugins(service, route),
+				}
+
+				// A probe that gets through reaches the upstream, so probe-only mode
+				// sends an invalid body
+				switch safetyAction("bypass", service.Name, route.Name, p, target.Method) {
+				case safetySkip:
+					continue
+				case safetyProbe:
+					target.Probe = true
+				}
+				for _, variant := range bypassVariants {
+					probe := BypassProbe{
+						Service:   service.Name,
+						Route:     route.Name,
+						Path:      p,
+						Method:    target.Method,
+						Variant:   variant.name,
+						ProbePath: p,
+					}
+					if variant.path != nil {
+						probe.ProbePath = variant.path(p)
+						if probe.ProbePath == p {
+							continue
+						}
+					}
+					if variant.header[0] != "" {
+						probe.Header = variant.header[0] + ": " + variant.header[1]
+					}
+					if ctx.Err() != nil {
+						return probes
+					}
+
+					target.Path = probe.ProbePath
+					if !*dryRun {
+						probe = sendBypassProbe(ctx, router, target, variant, probe)
+					}
+					printBypassProbe(probe)
+					probes = append(probes, probe)
+
+					sleepContext(ctx, *delay)
+				}
+			}
+		}
+	}
+	return probes
+}
+
+// sendBypassProbe sends a variant without credentials
+func sendBypassProbe(ctx context.Context, router *kong.Router, target requestTarget, variant bypassVariant, probe BypassProbe) BypassProbe {
+	tmpl, err := buildRequestTemplate(target)
+	if err != nil {
+		probe.Error = fmt.Errorf("rendering fixture: %w", err)
+		return probe
+	}
+	if target.Probe {
+		neutralizeTemplate(tmpl)
+	}
+	req, err := newTargetRequest(ctx, target, tmpl, authNone)
+	if err != nil {
+		probe.Error = err
+		return probe
+	}
+	if variant.header[0] != "" {
+		req.Header.Set(variant.header[0], variant.header[1])
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	if match, ok := router.Match(target.Method, host, normalizePath(probe.ProbePath)); ok &&
+		match.Route.Name != target.Route.Name && !hasAuthPlugin(match.Route, match.Service) {
+		probe.RoutedTo = match.Route.Name
+	}
+
+	client, err := clientFor(target.Service.Name, target.Route.Name, "")
+	if err != nil {
+		probe.Error = err
+		return probe
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		probe.Error = err
+		return probe
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+
+	probe.StatusCode = resp.StatusCode
+	return probe
+}
+
+func printBypassProbe(probe BypassProbe) {
+	finding := probe.Bypassed()
+	if !*verbose && !finding && !*dryRun {
+		return
+	}
+
+	status := "✓"
+	switch {
+	case *dryRun:
+		status = "○"
+	case finding:
+		status = "✗"
+	}
+
+	fmt.Printf("%s %-30s %-40s %-6s %3d [BYPASS:%s]", status, probe.Service, truncate(probe.ProbePath, 40), probe.Method, probe.StatusCode, probe.Variant)
+	switch {
+	case probe.Error != nil:
+		fmt.Printf(" ERROR: %v", probe.Error)
+	case probe.Header != "":
+		fmt.Printf(" - %s", probe.Header)
+	case probe.RoutedTo != "":
+		fmt.Printf(" - routed to %s", probe.RoutedTo)
+	}
+	fmt.Println()
+}
+
+func printBypassSummary(probes []BypassProbe) {
+	if !*bypassProbes || *dryRun {
+		return
+	}
+
+	var findings, public []string
+	errors := 0
+	for _, probe := range probes {
+		if probe.Error != nil {
+			errors++
+			continue
+		}
+		if !probe.Succeeded() {
+			continue
+		}
+		line := fmt.Sprintf("  - %s %s (%s) [%s]", probe.Method, probe.ProbePath, probe.Route, probe.Variant)
+		if probe.Header != "" {
+			line += " with " + probe.Header
+		}
+		line += fmt.Sprintf(": %d without credentials", probe.StatusCode)
+		if probe.RoutedTo != "" {
+			public = append(public, fmt.Sprintf("%s via public route %s", line, probe.RoutedTo))
+		} else {
+			findings = append(findings, line)
+		}
+	}
+
+	fmt.Printf("\nAuth Bypass Probes (%d requests):\n", len(probes))
+	fmt.Printf("  Bypasses:                  %d\n", len(findings))
+	fmt.Printf("  Served by public routes:   %d\n", len(public))
+	fmt.Printf("  Errors:                    %d\n", errors)
+
+	if len(findings) > 0 {
+		fmt.Println("\nSECURITY: Protected routes answered variants without credentials:")
+		for _, line := range findings {
+			fmt.Println(line)
+		}
+	}
+	if len(public) > 0 {
+		// A public route answering is only a bypass if its upstream
+		// normalizes the path back onto the protected resource
+		fmt.Println("\nVariants answered by public routes (check the upstream does not normalize them):")
+		for _, line := range public {
+			fmt.Println(line)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBypassVariantPaths(t *testing.T) {
+	want := map[string]string{
+		"trailing slash":       "/api/v1/users/",
+		"leading double slash": "//api/v1/users",
+		"inner double slash":   "/api/v1//users",
+		"upper case":           "/API/V1/USERS",
+		"mixed case":           "/api/v1/Users",
+		"encoded character":    "/api/v1/%75sers",
+		"encoded slash":        "/api/v1%2Fusers",
+		"dot segment":          "/api/v1/./users",
+		"dot-dot-semicolon":    "/kong-route-tester/..;/api/v1/users",
+		"path parameter":       "/api/v1/users;kong-route-tester=1",
+		"segment parameter":    "/api/v1;/users",
+	}
+
+	for _, variant := range bypassVariants {
+		if variant.path == nil {
+			continue
+		}
+		if got := variant.path("/api/v1/users"); got != want[variant.name] {
+			t.Errorf("%s: got %q, want %q", variant.name, got, want[variant.name])
+		}
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	tests := map[string]string{
+		"/api/v1/./users":   "/api/v1/users",
+		"/api/v1/%75sers":   "/api/v1/users",
+		"/api/v1%2Fusers":   "/api/v1%2Fusers",
+		"/api/v1/users/":    "/api/v1/users/",
+		"/a/..;/api/v1/x":   "/a/..;/api/v1/x",
+		"/public/../admin/": "/admin/",
+		"//api/v1/users":    "//api/v1/users",
+	}
+
+	for p, want := range tests {
+		if got := normalizePath(p); got != want {
+			t.Errorf("normalizePath(%q) = %q, want %q", p, got, want)
+		}
+	}
+}
+
+func TestTestBypasses(t *testing.T) {
+	// An upstream that ignores a trailing slash when checking credentials
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v1/users/", r.Header.Get("Authorization") != "":
+			w.WriteHeader(http.StatusOK)
+		case !strings.HasPrefix(r.URL.Path, "/api/"):
+			w.WriteHeader(http.StatusOK) // Public catch-all
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	originalURL := *baseURL
+	*baseURL = server.URL
+	defer func() { *baseURL = originalURL }()
+
+	config := &KongConfig{Services: []Service{
+		{Name: "users", URL: "http://users:8000", Routes: []Route{
+			{Name: "users", Paths: []string{"/api/v1/users"}, Methods: []string{"GET"}, Plugins: []Plugin{{Name: "auth"}}},
+		}},
+		{Name: "website", URL: "http://website:8000", Routes: []Route{{Name: "website", Paths: []string{"/"}}}},
+	}}
+
+	probes := testBypasses(context.Background(), config)
+	if len(probes) != len(bypassVariants) {
+		t.Fatalf("expected %d probes, got %d", len(bypassVariants), len(probes))
+	}
+
+	for _, probe := range probes {
+		switch {
+		case probe.Variant == "trailing slash":
+			if !probe.Succeeded() || probe.RoutedTo != "" {
+				t.Errorf("expected the trailing slash to be reported as a bypass, got %+v", probe)
+			}
+		case probe.Variant == "dot-dot-semicolon":
+			if !probe.Succeeded() || probe.RoutedTo != "website" {
+				t.Errorf("expected the dot-dot-semicolon probe to be served by the public route, got %+v", probe)
+			}
+		case probe.Bypassed():
+			t.Errorf("unexpected bypass %+v", probe)
+		}
+	}
+	if !bypassFound(probes) {
+		t.Error("expected the trailing slash bypass to fail the run")
+	}
+}
+
+func TestTestBypassesProbeOnly(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	originalURL := *baseURL
+	*baseURL = server.URL
+	defer func() { *baseURL = originalURL }()
+	withSafety(t, safeProbeOnly, nil, nil)
+
+	config := &KongConfig{Services: []Service{{
+		Name: "users",
+		Routes: []Route{
+			{Name: "users", Paths: []string{"/api/v1/users"}, Methods: []string{"POST"}, Plugins: []Plugin{{Name: "auth"}}},
+		},
+	}}}
+
+	probes := testBypasses(context.Background(), config)
+	if len(probes) == 0 || len(bodies) != len(probes) {
+		t.Fatalf("expected every probe to be sent, got %d probes and %d requests", len(probes), len(bodies))
+	}
+	for _, body := range bodies {
+		if body != probeBody {
+			t.Errorf("bypass probe sent with a valid body %q", body)
+		}
+	}
+	if bypassFound(probes) {
+		t.Error("expected rejected probes not to fail the run")
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/danpilch/kong-route-tester/kong"
+	"github.com/spf13/pflag"
+)
+
+// changedFrom is the configuration revision to compare --file with
+var changedFrom = pflag.String("changed-from", "", "Only test routes changed since this Kong configuration file or git revision of --file, and routes whose matching the change affects")
+
+// changedRoutes holds why each changed route is tested, keyed by
+// service/route, or nil when every route is tested
+var changedRoutes map[string][]string
+
+// readKongConfigRevision reads a Kong configuration from a file or, when no
+// such file exists, from a git revision of the file the tester reads
+func readKongConfigRevision(revision, filename string) (*KongConfig, error) {
+	if _, err := os.Stat(revision); err == nil {
+		return readKongConfig(revision)
+	}
+
+	cmd := exec.Command("git", "-C", filepath.Dir(filename), "show", revision+":./"+filepath.Base(filename))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	data, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("reading %s at %s: %s", filename, revision, strings.TrimSpace(stderr.String()))
+	}
+	return parseKongConfig(data)
+}
+
+// routeKey identifies a route across configurations
+func routeKey(service Service, route Route) string {
+	return service.Name + "/" + route.Name
+}
+
+// findChangedRoutes computes the routes an edit from before to after
+// affects, with the reasons. Routes that were added or removed, or whose
+// attributes, service or effective plugins changed are affected, as are
+// routes that now win or lose a sample request to another route, through new
+// shadowing or changed priorities.
+func findChangedRoutes(before, after *KongConfig) map[string][]string {
+	changes := make(map[string][]string)
+
+	type located struct {
+		service Service
+		route   Route
+	}
+	previous := make(map[string]located)
+	for _, service := range before.Services {
+		for _, route := range service.Routes {
+			previous[routeKey(service, route)] = located{service, route}
+		}
+	}
+
+	current := make(map[string]bool)
+	for _, service := range after.Services {
+		for _, route := range service.Routes {
+			key := routeKey(service, route)
+			current[key] = true
+			was, ok := previous[key]
+			if !ok {
+				changes[key] = []string{"added"}
+				continue
+			}
+			if reasons := routeDifferences(before, was.service, was.route, after, service, route); len(reasons) > 0 {
+				changes[key] = reasons
+			}
+		}
+	}
+	for key := range previous {
+		if !current[key] {
+			changes[key] = []string{"removed"}
+		}
+	}
+
+	// Compare the route each configuration selects for sample requests to
+	// every route in either of them
+	oldRouter, newRouter := kong.NewRouter(before), kong.NewRouter(after)
+	winner := func(router *kong.Router, method, host, path string) string {
+		if match, ok := router.Match(method, host, path); ok {
+			return routeKey(match.Service, match.Route)
+		}
+		return ""
+	}
+	matching := make(map[string][]string)
+	for _, config := range []*KongConfig{before, after} {
+		for _, service := range config.Services {
+			for _, route := range service.Routes {
+				host := routeHost(route)
+				paths := route.Paths
+				if len(paths) == 0 {
+					paths = []string{"/"}
+				}
+				for _, pattern := range paths {
+					path := expandRegexPath(pattern)
+					for _, method := range routeMethods(route) {
+						was, now := winner(oldRouter, method, host, path), winner(newRouter, method, host, path)
+						if was == now {
+							continue
+						}
+						reason := fmt.Sprintf("%s %s was routed to %s, now %s", method, path, routeOrNone(was), routeOrNone(now))
+						for _, key := range []string{was, now} {
+							if current[key] && changes[key] == nil && !slices.Contains(matching[key], reason) {
+								matching[key] = append(matching[key], reason)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	for key, reasons := range matching {
+		changes[key] = reasons
+	}
+	return changes
+}
+
+func routeOrNone(key string) string {
+	if key == "" {
+		return "no route"
+	}
+	return key
+}
+
+// routeDifferences lists what changed about a route, ignoring the order of
+// its paths, methods and hosts
+func routeDifferences(before *KongConfig, oldService Service, oldRoute Route, after *KongConfig, service Service, route Route) []string {
+	var reasons []string
+	differ := func(name string, a, b interface{}) {
+		if !reflect.DeepEqual(a, b) {
+			reasons = append(reasons, name)
+		}
+	}
+	differ("paths", sortedCopy(oldRoute.Paths), sortedCopy(route.Paths))
+	differ("methods", sortedCopy(upperAll(oldRoute.Methods)), sortedCopy(upperAll(route.Methods)))
+	differ("hosts", sortedCopy(oldRoute.Hosts), sortedCopy(route.Hosts))
+	differ("regex_priority", oldRoute.Priority, route.Priority)
+	differ("strip_path", kong.StripPath(oldRoute), kong.StripPath(route))
+	differ("path_handling", oldRoute.PathHandling, route.PathHandling)
+	differ("preserve_host", oldRoute.PreserveHost, route.PreserveHost)
+
+	differ("service url", kong.ServiceOrigin(oldService)+kong.ServicePath(oldService), kong.ServiceOrigin(service)+kong.ServicePath(service))
+
+	oldPlugins := pluginsByName(before.EffectivePlugins(oldService, oldRoute))
+	newPlugins := pluginsByName(after.EffectivePlugins(service, route))
+	for _, name := range unionKeys(oldPlugins, newPlugins) {
+		old, hadOld := oldPlugins[name]
+		plugin, hasNew := newPlugins[name]
+		switch {
+		case !hadOld:
+			reasons = append(reasons, "plugin "+name+" added")
+		case !hasNew:
+			reasons = append(reasons, "plugin "+name+" removed")
+		case !reflect.DeepEqual(old.Config, plugin.Config):
+			reasons = append(reasons, "plugin "+name+" config")
+		}
+	}
+	return reasons
+}
+
+func pluginsByName(plugins []Plugin) map[string]Plugin {
+	byName := make(map[string]Plugin, len(plugins))
+	for _, plugin := range plugins {
+		byName[plugin.Name] = plugin
+	}
+	return byName
+}
+
+// unionKeys returns the keys of both maps, sorted
+func unionKeys[V any](a, b map[string]V) []string {
+	var keys []string
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func sortedCopy(values []string) []string {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	return sorted
+}
+
+func upperAll(values []string) []string {
+	upper := make([]string, len(values))
+	for i, value := range values {
+		upper[i] = strings.ToUpper(value)
+	}
+	return upper
+}
+
+func printChangedRoutes(revision string, changes map[string][]string) {
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	if len(keys) == 0 {
+		fmt.Printf("No routes changed since %s, nothing to test\n", revision)
+		return
+	}
+	fmt.Printf("Routes changed since %s: %d\n", revision, len(keys))
+	for _, key := range keys {
+		fmt.Printf("  - %s: %s\n", key, strings.Join(changes[key], "; "))
+	}
+	fmt.Println()
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFindChangedRoutes(t *testing.T) {
+	before, err := parseKongConfig([]byte(`
+services:
+  - name: users
+    url: http://users:8000
+    plugins:
+      - name: auth
+    routes:
+      - name: list
+        paths: [/users]
+        methods: [GET, POST]
+      - name: profile
+        paths: ["/users/(?<id>[0-9]+)/profile"]
+        methods: [GET]
+      - name: legacy
+        paths: [/legacy]
+  - name: billing
+    url: http://billing:8000
+    routes:
+      - name: invoices
+        paths: [/invoices]
+        methods: [GET]
+      - name: reports
+        paths: [/reports]
+        methods: [GET]
+        hosts: [a.example.com, b.example.com]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := parseKongConfig([]byte(`
+services:
+  - name: billing
+    url: http://billing:8000
+    routes:
+      - name: reports
+        paths: [/reports]
+        methods: [get]
+        hosts: [b.example.com, a.example.com]
+      - name: invoices
+        paths: [/invoices]
+        methods: [GET]
+      - name: catch-all
+        paths: ["/users/(?<any>.*)"]
+        regex_priority: 100
+  - name: users
+    url: http://users:8000
+    plugins:
+      - name: auth
+        config:
+          anonymous: guest
+    routes:
+      - name: list
+        paths: [/users]
+        methods: [POST, GET, DELETE]
+      - name: profile
+        paths: ["/users/(?<id>[0-9]+)/profile"]
+        methods: [GET]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := findChangedRoutes(before, after)
+	want := map[string]string{
+		"billing/catch-all": "added",
+		"users/legacy":      "removed",
+		"users/list":        "methods; plugin auth config",
+		"users/profile":     "plugin auth config",
+	}
+	for key, reasons := range want {
+		if got := strings.Join(changes[key], "; "); got != reasons {
+			t.Errorf("%s: got %q, want %q", key, got, reasons)
+		}
+	}
+	// Reordering paths, methods and hosts changes nothing
+	for _, key := range []string{"billing/invoices", "billing/reports"} {
+		if reasons, ok := changes[key]; ok {
+			t.Errorf("expected %s unchanged, got %q", key, reasons)
+		}
+	}
+	if len(changes) != len(want) {
+		t.Errorf("expected %d changed routes, got %q", len(want), changes)
+	}
+}
+
+func TestFindChangedRoutesMatching(t *testing.T) {
+	before, err := parseKongConfig([]byte(`
+services:
+  - name: api
+    routes:
+      - name: catch-all
+        paths: ["/api/(?<rest>.*)"]
+        methods: [GET]
+        regex_priority: 0
+      - name: orders
+        paths: ["/api/orders/(?<id>[0-9]+)"]
+        methods: [GET]
+        regex_priority: 10
+      - name: health
+        paths: [/health]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := parseKongConfig([]byte(`
+services:
+  - name: api
+    routes:
+      - name: catch-all
+        paths: ["/api/(?<rest>.*)"]
+        methods: [GET]
+        regex_priority: 20
+      - name: orders
+        paths: ["/api/orders/(?<id>[0-9]+)"]
+        methods: [GET]
+        regex_priority: 10
+      - name: health
+        paths: [/health]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := findChangedRoutes(before, after)
+	if !slices.Equal(changes["api/catch-all"], []string{"regex_priority"}) {
+		t.Errorf("catch-all: %q", changes["api/catch-all"])
+	}
+	// Orders is unchanged but is now shadowed by the catch-all
+	if len(changes["api/orders"]) != 1 || !strings.Contains(changes["api/orders"][0], "was routed to api/orders, now api/catch-all") {
+		t.Errorf("orders: %q", changes["api/orders"])
+	}
+	if _, ok := changes["api/health"]; ok {
+		t.Errorf("expected health unaffected, got %q", changes["api/health"])
+	}
+}
+
+func TestReadKongConfigRevision(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "kong.yaml")
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	if err := os.WriteFile(filename, []byte("services:\n  - name: old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "kong.yaml")
+	git("commit", "-q", "-m", "old")
+	if err := os.WriteFile(filename, []byte("services:\n  - name: new\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := readKongConfigRevision("HEAD", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Services) != 1 || config.Services[0].Name != "old" {
+		t.Errorf("expected the committed configuration, got %+v", config.Services)
+	}
+
+	// Files take precedence over revisions
+	config, err = readKongConfigRevision(filename, filename)
+	if err != nil || config.Services[0].Name != "new" {
+		t.Errorf("expected the file, got %+v, %v", config, err)
+	}
+
+	if _, err := readKongConfigRevision("no-such-revision", filename); err == nil {
+		t.Error("expected an error for an unknown revision")
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/danpilch/kong-route-tester/kong"
+	"github.com/spf13/pflag"
+)
+
+// CORS verification flags
+var (
+	corsMode          = pflag.Bool("cors", false, "Send CORS preflights to routes with the cors plugin and check them against its config")
+	corsForeignOrigin = pflag.String("cors-foreign-origin", "https://disallowed.kong-route-tester.invalid", "Origin sent to confirm disallowed origins are rejected")
+)
+
+// defaultCORSMethods are the methods Kong's cors plugin allows by default
+var defaultCORSMethods = []string{"GET", "HEAD", "PUT", "PATCH", "POST", "DELETE", "OPTIONS", "TRACE", "CONNECT"}
+
+// CORSCheck is the outcome of one preflight request
+type CORSCheck struct {
+	Service    string
+	Route      string
+	Path       string
+	Method     string // Method asked for in Access-Control-Request-Method
+	Origin     string
+	Allowed    bool // Whether the plugin config allows the origin
+	StatusCode int
+	Error      error
+
+	// Failures are differences from the plugin config; Findings are
+	// responses that let other sites make credentialed requests
+	Failures []string
+	Findings []string
+}
+
+// corsPlugin returns the cors plugin that runs for a route, if any
+func corsPlugin(config *KongConfig, service Service, route Route) (Plugin, bool) {
+	for _, plugin := range config.EffectivePlugins(service, route) {
+		if plugin.Name == "cors" {
+			return plugin, true
+		}
+	}
+	return Plugin{}, false
+}
+
+// preflightMethods returns the methods to ask about in preflights to a route
+func preflightMethods(route Route) []string {
+	var methods []string
+	for _, method := range routeMethods(route) {
+		if !strings.EqualFold(method, http.MethodOptions) {
+			methods = append(methods, method)
+		}
+	}
+	if len(methods) == 0 {
+		// Options-only routes exist to answer preflights for other routes
+		methods = []string{http.MethodGet}
+	}
+	return methods
+}
+
+// testCORS sends preflights from an allowed and a disallowed origin for each
+// route and method covered by the cors plugin
+func testCORS(ctx context.Context, config *KongConfig) []CORSCheck {
+	var checks []CORSCheck
+	for _, service := range config.Services {
+		if skipService(service) {
+			continue
+		}
+		for _, route := range service.Routes {
+			plugin, ok := corsPlugin(config, service, route)
+			if !ok || !routeSelected(service, route) {
+				continue
+			}
+
+			methods := preflightMethods(route)
+			if !kong.MatchMethod(route, http.MethodOptions) {
+				// Preflights only reach the route's plugins through another
+				// route accepting OPTIONS, so one method per path shows it
+				methods = methods[:1]
+			}
+
+			for _, pattern := range route.Paths {
+				path := expandRegexPath(pattern)
+				// Preflights are OPTIONS requests, so only denied paths are skipped
+				if safetyAction("cors", service.Name, route.Name, path, http.MethodOptions) == safetySkip {
+					continue
+				}
+				for _, method := range methods {
+					origins := []string{*corsForeignOrigin}
+					if origin := allowedOriginSample(plugin.Config); origin != "" {
+						origins = append([]string{origin}, origins...)
+					}
+
+					for _, origin := range origins {
+						if ctx.Err() != nil {
+							return checks
+						}
+						_, allowed := kong.AllowedOrigin(plugin.Config, origin)
+						check := CORSCheck{
+							Service: service.Name,
+							Route:   route.Name,
+							Path:    path,
+							Method:  method,
+							Origin:  origin,
+							Allowed: allowed,
+						}
+						if !*dryRun {
+							check = sendPreflight(ctx, route, plugin.Config, check)
+						}
+						printCORSCheck(check)
+						checks = append(checks, check)
+
+						sleepContext(ctx, *delay)
+					}
+				}
+			}
+		}
+	}
+	return checks
+}
+
+// sendPreflight sends an OPTIONS preflight and checks the response
+func sendPreflight(ctx context.Context, route Route, config map[string]interface{}, check CORSCheck) CORSCheck {
+	req, err := http.NewRequestWithContext(ctx, http.MethodOptions, *baseURL+check.Path, nil)
+	if err != nil {
+		check.Error = err
+		return check
+	}
+	req.Header.Set("Origin", check.Origin)
+	req.Header.Set("Access-Control-Request-Method", check.Method)
+	if headers := kong.StringList(config, "headers"); len(headers) > 0 {
+		req.Header.Set("Access-Control-Request-Headers", headers[0])
+	}
+
+	client, err := clientFor(check.Service, check.Route, "")
+	if err != nil {
+		check.Error = err
+		return check
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		check.Error = err
+		return check
+	}
+	resp.Body.Close()
+
+	check.StatusCode = resp.StatusCode
+	check.Failures, check.Findings = checkPreflight(route, config, check, resp.Header)
+	return check
+}
+
+// checkPreflight compares a preflight response with the cors plugin config
+func checkPreflight(route Route, config map[string]interface{}, check CORSCheck, header http.Header) (failures, findings []string) {
+	allowOrigin := header.Get("Access-Control-Allow-Origin")
+	credentials := header.Get("Access-Control-Allow-Credentials") == "true"
+
+	// Findings apply whatever the config says
+	if allowOrigin == "*" && credentials {
+		findings = append(findings, "wildcard Access-Control-Allow-Origin with credentials")
+	}
+	if allowOrigin == check.Origin && credentials && check.Origin == *corsForeignOrigin {
+		findings = append(findings, fmt.Sprintf("reflected origin %s with credentials", check.Origin))
+	}
+
+	if !kong.MatchMethod(route, http.MethodOptions) && check.StatusCode == http.StatusNotFound {
+		failures = append(failures, "route does not accept OPTIONS, so preflights are not routed")
+		return failures, findings
+	}
+	if kong.BoolValue(config, "preflight_continue", false) {
+		// The upstream answers preflights itself
+		return failures, findings
+	}
+	if check.StatusCode != http.StatusOK && check.StatusCode != http.StatusNoContent {
+		failures = append(failures, fmt.Sprintf("expected preflight 200, got %d", check.StatusCode))
+		return failures, findings
+	}
+
+	if !check.Allowed {
+		if allowOrigin == check.Origin || allowOrigin == "*" {
+			failures = append(failures, fmt.Sprintf("disallowed origin accepted (Access-Control-Allow-Origin %s)", allowOrigin))
+		}
+		return failures, findings
+	}
+
+	if allowOrigin != check.Origin && allowOrigin != "*" {
+		failures = append(failures, fmt.Sprintf("expected Access-Control-Allow-Origin %s, got %q", check.Origin, allowOrigin))
+	}
+	if kong.BoolValue(config, "credentials", false) != credentials {
+		failures = append(failures, fmt.Sprintf("expected Access-Control-Allow-Credentials %t", kong.BoolValue(config, "credentials", false)))
+	}
+
+	methods := kong.StringList(config, "methods")
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	allowMethods := strings.Split(strings.ReplaceAll(header.Get("Access-Control-Allow-Methods"), " ", ""), ",")
+	if slices.Contains(methods, check.Method) && !slices.Contains(allowMethods, check.Method) {
+		failures = append(failures, fmt.Sprintf("%s missing from Access-Control-Allow-Methods %q", check.Method, header.Get("Access-Control-Allow-Methods")))
+	}
+
+	if headers := kong.StringList(config, "headers"); len(headers) > 0 {
+		if got := header.Get("Access-Control-Allow-Headers"); got != strings.Join(headers, ",") {
+			failures = append(failures, fmt.Sprintf("expected Access-Control-Allow-Headers %s, got %q", strings.Join(headers, ","), got))
+		}
+	}
+	if maxAge := kong.IntValue(config, "max_age", 0); maxAge > 0 {
+		if got := header.Get("Access-Control-Max-Age"); got != strconv.Itoa(maxAge) {
+			failures = append(failures, fmt.Sprintf("expected Access-Control-Max-Age %d, got %q", maxAge, got))
+		}
+	}
+	return failures, findings
+}
+
+func printCORSCheck(check CORSCheck) {
+	passed := check.Error == nil && len(check.Failures) == 0 && len(check.Findings) == 0
+	if !*verbose && passed && !*dryRun {
+		return
+	}
+
+	status := "✓"
+	switch {
+	case *dryRun:
+		status = "○"
+	case !passed:
+		status = "✗"
+	}
+
+	origin := "allowed"
+	if !check.Allowed {
+		origin = "disallowed"
+	}
+	fmt.Printf("%s %-30s %-40s %-6s %3d [CORS:%s]", status, check.Service, truncate(check.Path, 40), check.Method, check.StatusCode, origin)
+
+	switch {
+	case *dryRun:
+		fmt.Printf(" - DRY RUN preflight from %s", check.Origin)
+	case check.Error != nil:
+		fmt.Printf(" ERROR: %v", check.Error)
+	case len(check.Findings) > 0:
+		fmt.Printf(" - %s", check.Findings[0])
+	case len(check.Failures) > 0:
+		fmt.Printf(" - %s", truncate(check.Failures[0], 60))
+	}
+	fmt.Println()
+}
+
+func printCORSSummary(checks []CORSCheck) {
+	if !*corsMode || *dryRun {
+		return
+	}
+
+	var failures, findings []string
+	for _, check := range checks {
+		prefix := fmt.Sprintf("  - %s %s (%s) from %s: ", check.Method, check.Path, check.Route, check.Origin)
+		if check.Error != nil {
+			failures = append(failures, prefix+check.Error.Error())
+		}
+		for _, failure := range check.Failures {
+			failures = append(failures, prefix+failure)
+		}
+		for _, finding := range check.Findings {
+			findings = append(findings, prefix+finding)
+		}
+	}
+
+	fmt.Printf("\nCORS Preflights (%d checks):\n", len(checks))
+	fmt.Printf("  Policy mismatches: %d\n", len(failures))
+	fmt.Printf("  Security findings: %d\n", len(findings))
+
+	if len(findings) > 0 {
+		fmt.Println("\nSECURITY: CORS responses open to other origins:")
+		for _, line := range findings {
+			fmt.Println(line)
+		}
+	}
+	if len(failures) > 0 {
+		fmt.Println("\nCORS policy mismatches:")
+		for _, line := range failures {
+			fmt.Println(line)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/danpilch/kong-route-tester/kong"
+	"github.com/danpilch/kong-route-tester/mock"
+)
+
+func TestCheckPreflight(t *testing.T) {
+	config := map[string]interface{}{
+		"origins":     []interface{}{`^https?:\/\/localhost(:\d+)?\$`},
+		"credentials": true,
+		"max_age":     600,
+	}
+	route := Route{Name: "users", Methods: []string{"GET", "OPTIONS"}}
+
+	tests := []struct {
+		name     string
+		route    Route
+		origin   string
+		status   int
+		headers  map[string]string
+		failures []string
+		findings []string
+	}{
+		{
+			name:    "allowed origin",
+			route:   route,
+			origin:  "http://localhost",
+			status:  200,
+			headers: map[string]string{"Access-Control-Allow-Origin": "http://localhost", "Access-Control-Allow-Credentials": "true", "Access-Control-Allow-Methods": "GET,POST", "Access-Control-Max-Age": "600"},
+		},
+		{
+			name:     "allowed origin missing headers",
+			route:    route,
+			origin:   "http://localhost",
+			status:   200,
+			headers:  map[string]string{"Access-Control-Allow-Methods": "POST"},
+			failures: []string{`expected Access-Control-Allow-Origin http://localhost, got ""`, "expected Access-Control-Allow-Credentials true", `GET missing from Access-Control-Allow-Methods "POST"`, `expected Access-Control-Max-Age 600, got ""`},
+		},
+		{
+			name:   "disallowed origin rejected",
+			route:  route,
+			origin: *corsForeignOrigin,
+			status: 200,
+		},
+		{
+			name:     "disallowed origin reflected",
+			route:    route,
+			origin:   *corsForeignOrigin,
+			status:   200,
+			headers:  map[string]string{"Access-Control-Allow-Origin": *corsForeignOrigin, "Access-Control-Allow-Credentials": "true"},
+			failures: []string{"disallowed origin accepted (Access-Control-Allow-Origin " + *corsForeignOrigin + ")"},
+			findings: []string{"reflected origin " + *corsForeignOrigin + " with credentials"},
+		},
+		{
+			name:     "wildcard with credentials",
+			route:    route,
+			origin:   "http://localhost",
+			status:   200,
+			headers:  map[string]string{"Access-Control-Allow-Origin": "*", "Access-Control-Allow-Credentials": "true", "Access-Control-Allow-Methods": "GET", "Access-Control-Max-Age": "600"},
+			findings: []string{"wildcard Access-Control-Allow-Origin with credentials"},
+		},
+		{
+			name:     "preflight not routed",
+			route:    Route{Name: "users", Methods: []string{"GET"}},
+			origin:   "http://localhost",
+			status:   404,
+			failures: []string{"route does not accept OPTIONS, so preflights are not routed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := make(http.Header)
+			for name, value := range tt.headers {
+				header.Set(name, value)
+			}
+			_, allowed := kong.AllowedOrigin(config, tt.origin)
+			check := CORSCheck{Method: "GET", Origin: tt.origin, Allowed: allowed, StatusCode: tt.status}
+
+			failures, findings := checkPreflight(tt.route, config, check, header)
+			if strings.Join(failures, "\n") != strings.Join(tt.failures, "\n") {
+				t.Errorf("failures = %q, want %q", failures, tt.failures)
+			}
+			if strings.Join(findings, "\n") != strings.Join(tt.findings, "\n") {
+				t.Errorf("findings = %q, want %q", findings, tt.findings)
+			}
+		})
+	}
+}
+
+func TestTestCORS(t *testing.T) {
+	config := &kong.Config{
+		Plugins: []Plugin{{Name: "cors", Config: map[string]interface{}{"origins": []interface{}{"*"}, "credentials": true}}},
+		Services: []Service{{
+			Name: "public-api",
+			URL:  "http://public:8002",
+			Routes: []Route{
+				{Name: "public", Paths: []string{"/api/v1/public"}, Methods: []string{"GET", "OPTIONS"}},
+			},
+		}},
+	}
+
+	server := httptest.NewServer(mock.NewGateway(config, mock.Options{}))
+	defer server.Close()
+
+	originalURL := *baseURL
+	*baseURL = server.URL
+	defer func() { *baseURL = originalURL }()
+
+	checks := testCORS(context.Background(), config)
+	if len(checks) != 2 {
+		t.Fatalf("expected preflights from an allowed and a foreign origin, got %d", len(checks))
+	}
+
+	// Kong reflects any origin when origins is "*" and credentials are on
+	foreign := checks[1]
+	if foreign.Origin != *corsForeignOrigin || len(foreign.Findings) != 1 {
+		t.Errorf("expected a reflected origin finding, got %+v", foreign)
+	}
+}
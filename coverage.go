@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"sort"
+
+	"github.com/danpilch/kong-route-tester/kong"
+	"github.com/spf13/pflag"
+)
+
+// OpenAPI coverage report flags
+var (
+	coverageJSON = pflag.String("coverage-json", "", "Write the OpenAPI operation coverage matrix as JSON to this file")
+	coverageHTML = pflag.String("coverage-html", "", "Write the OpenAPI operation coverage matrix as HTML to this file")
+)
+
+// CoverageReport lists, per service, which documented operations Kong exposes
+type CoverageReport struct {
+	Services []ServiceCoverage `json:"services"`
+}
+
+type ServiceCoverage struct {
+	Service    string              `json:"service"`
+	Spec       string              `json:"spec"`
+	Total      int                 `json:"total"`
+	Reachable  int                 `json:"reachable"`
+	Percent    float64             `json:"coverage_percent"`
+	Operations []OperationCoverage `json:"operations"`
+}
+
+type OperationCoverage struct {
+	Method      string          `json:"method"`
+	Path        string          `json:"path"`
+	OperationID string          `json:"operation_id,omitempty"`
+	Reachable   bool            `json:"reachable"`
+	Routes      []RouteCoverage `json:"routes"`
+}
+
+// RouteCoverage is a route path that reaches an operation, with an example
+// request path that Kong forwards to it
+type RouteCoverage struct {
+	Route       string `json:"route"`
+	Path        string `json:"path"`
+	RequestPath string `json:"request_path"`
+}
+
+// buildCoverage computes which operations of each service's spec are
+// reachable through the service's routes
+func buildCoverage(config *KongConfig) CoverageReport {
+	var report CoverageReport
+
+	for _, service := range config.Services {
+		spec := openAPISpecs[service.Name]
+		if spec == nil {
+			continue
+		}
+
+		coverage := ServiceCoverage{Service: service.Name, Spec: (*openAPIFiles)[service.Name]}
+		seen := make(map[*Operation]int)
+
+		for _, op := range spec.operations() {
+			upstream, _ := op.concretePath()
+
+			var routes []RouteCoverage
+			for _, route := range service.Routes {
+				if !kong.MatchMethod(route, op.Method) {
+					continue
+				}
+				for _, pattern := range route.Paths {
+					if path, ok := gatewayPathFor(service, route, pattern, upstream); ok {
+						routes = append(routes, RouteCoverage{Route: route.Name, Path: pattern, RequestPath: path})
+					}
+				}
+			}
+
+			// Operations are listed once per server base path, keep the first
+			// and merge the routes reaching the others
+			if i, ok := seen[op.Operation]; ok {
+				coverage.Operations[i].Routes = append(coverage.Operations[i].Routes, routes...)
+				coverage.Operations[i].Reachable = len(coverage.Operations[i].Routes) > 0
+				continue
+			}
+			seen[op.Operation] = len(coverage.Operations)
+			coverage.Operations = append(coverage.Operations, OperationCoverage{
+				Method:      op.Method,
+				Path:        op.Template,
+				OperationID: op.Operation.OperationID,
+				Reachable:   len(routes) > 0,
+				Routes:      routes,
+			})
+		}
+
+		coverage.Total = len(coverage.Operations)
+		for _, op := range coverage.Operations {
+			if op.Reachable {
+				coverage.Reachable++
+			}
+		}
+		if coverage.Total > 0 {
+			coverage.Percent = float64(coverage.Reachable) / float64(coverage.Total) * 100
+		}
+		report.Services = append(report.Services, coverage)
+	}
+
+	sort.Slice(report.Services, func(i, j int) bool {
+		return report.Services[i].Service < report.Services[j].Service
+	})
+	return report
+}
+
+func writeCoverageJSON(filename string, report CoverageReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0644)
+}
+
+var coverageTemplate = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Kong Route Coverage</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+tr.reachable td.status { background: #d4f7d4; }
+tr.unreachable td.status { background: #f7d4d4; }
+code { font-size: 0.9em; }
+</style>
+</head>
+<body>
+<h1>Kong Route Coverage</h1>
+{{- range .Services }}
+<h2>{{ .Service }}</h2>
+<p>{{ .Reachable }} of {{ .Total }} operations reachable ({{ printf "%.1f" .Percent }}%){{ if .Spec }} &middot; <code>{{ .Spec }}</code>{{ end }}</p>
+<table>
+<tr><th>Status</th><th>Method</th><th>Path</th><th>Operation</th><th>Kong Routes</th></tr>
+{{- range .Operations }}
+<tr class="{{ if .Reachable }}reachable{{ else }}unreachable{{ end }}">
+<td class="status">{{ if .Reachable }}exposed{{ else }}not exposed{{ end }}</td>
+<td>{{ .Method }}</td>
+<td><code>{{ .Path }}</code></td>
+<td>{{ .OperationID }}</td>
+<td>{{ range .Routes }}{{ .Route }} <code>{{ .Path }}</code> via <code>{{ .RequestPath }}</code><br>{{ end }}</td>
+</tr>
+{{- end }}
+</table>
+{{- else }}
+<p>No services have an OpenAPI spec.</p>
+{{- end }}
+</body>
+</html>
+`))
+
+func writeCoverageHTML(filename string, report CoverageReport) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := coverageTemplate.Execute(file, report); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeCoverageReports writes the coverage matrix to the requested files
+func writeCoverageReports(config *KongConfig) error {
+	if *coverageJSON == "" && *coverageHTML == "" {
+		return nil
+	}
+
+	report := buildCoverage(config)
+	if *coverageJSON != "" {
+		if err := writeCoverageJSON(*coverageJSON, report); err != nil {
+			return err
+		}
+		fmt.Printf("OpenAPI coverage written to %s\n", *coverageJSON)
+	}
+	if *coverageHTML != "" {
+		if err := writeCoverageHTML(*coverageHTML, report); err != nil {
+			return err
+		}
+		fmt.Printf("OpenAPI coverage written to %s\n", *coverageHTML)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildCoverage(t *testing.T) {
+	openAPISpecs = map[string]*OpenAPISpec{"user-service": loadTestOpenAPISpec(t)}
+	defer func() { openAPISpecs = nil }()
+
+	config := &KongConfig{Services: []Service{
+		{
+			Name: "user-service",
+			URL:  "http://users:8080/api/accounts",
+			Routes: []Route{
+				{Name: "account-reads", Paths: []string{"/users/v1/accounts"}, Methods: []string{"GET"}},
+			},
+		},
+		{Name: "undocumented-service", URL: "http://other:8080"},
+	}}
+
+	report := buildCoverage(config)
+	if len(report.Services) != 1 {
+		t.Fatalf("expected coverage for 1 service, got %d", len(report.Services))
+	}
+
+	coverage := report.Services[0]
+	if coverage.Total != 3 || coverage.Reachable != 1 {
+		t.Errorf("expected 1 of 3 operations reachable, got %d of %d", coverage.Reachable, coverage.Total)
+	}
+
+	reachable := make(map[string]bool)
+	for _, op := range coverage.Operations {
+		reachable[op.OperationID] = op.Reachable
+	}
+	expected := map[string]bool{"getAccount": true, "createAccount": false, "reindex": false}
+	for id, want := range expected {
+		if reachable[id] != want {
+			t.Errorf("%s reachable = %v, want %v", id, reachable[id], want)
+		}
+	}
+}
+
+func TestWriteCoverageReports(t *testing.T) {
+	report := CoverageReport{Services: []ServiceCoverage{{
+		Service:   "user-service",
+		Total:     2,
+		Reachable: 1,
+		Percent:   50,
+		Operations: []OperationCoverage{
+			{Method: "GET", Path: "/api/accounts/{account_id}", Reachable: true, Routes: []RouteCoverage{
+				{Route: "account-reads", Path: "/users/v1/accounts", RequestPath: "/users/v1/accounts/abc"},
+			}},
+			{Method: "POST", Path: "/api/internal/<reindex>"},
+		},
+	}}}
+
+	dir := t.TempDir()
+	jsonFile, htmlFile := filepath.Join(dir, "coverage.json"), filepath.Join(dir, "coverage.html")
+
+	if err := writeCoverageJSON(jsonFile, report); err != nil {
+		t.Fatalf("writeCoverageJSON() error = %v", err)
+	}
+	data, _ := os.ReadFile(jsonFile)
+	var decoded CoverageReport
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Services[0].Percent != 50 {
+		t.Errorf("unexpected JSON report %s: %v", data, err)
+	}
+
+	if err := writeCoverageHTML(htmlFile, report); err != nil {
+		t.Fatalf("writeCoverageHTML() error = %v", err)
+	}
+	html, _ := os.ReadFile(htmlFile)
+	for _, want := range []string{"1 of 2 operations reachable (50.0%)", "not exposed", "/api/internal/&lt;reindex&gt;"} {
+		if !strings.Contains(string(html), want) {
+			t.Errorf("HTML report missing %q", want)
+		}
+	}
+}
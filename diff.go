@@ -0,0 +1,425 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/danpilch/kong-route-tester/kong"
+	"github.com/spf13/pflag"
+)
+
+// authenticationPlugins are the plugins that require clients to identify
+// themselves; a route with none of them is public
+var authenticationPlugins = []string{
+	"auth", "basic-auth", "hmac-auth", "jwt", "key-auth", "ldap-auth", "mtls-auth", "oauth2", "openid-connect",
+}
+
+// accessPlugins restrict who may reach a route without authenticating them
+var accessPlugins = []string{"acl", "ip-restriction", "bot-detection"}
+
+// RouteDiff is how a route differs between two configurations
+type RouteDiff struct {
+	Service string
+	Route   string
+	Status  string // added, removed or changed
+	Changes []RouteChange
+}
+
+// RouteChange is one semantic change to a route
+type RouteChange struct {
+	Message string
+
+	// Security is true for changes that expose the route more widely
+	Security bool
+}
+
+// Security reports whether any change exposes the route more widely
+func (d RouteDiff) Security() bool {
+	return slices.ContainsFunc(d.Changes, func(c RouteChange) bool { return c.Security })
+}
+
+// diffConfigs compares two configurations route by route. Routes are matched
+// by service and route name; the order of services, routes, plugins and
+// list values is ignored.
+func diffConfigs(before, after *KongConfig) []RouteDiff {
+	type located struct {
+		service Service
+		route   Route
+	}
+	index := func(config *KongConfig) map[string]located {
+		routes := make(map[string]located)
+		for _, service := range config.Services {
+			for _, route := range service.Routes {
+				routes[routeKey(service, route)] = located{service, route}
+			}
+		}
+		return routes
+	}
+	previous, current := index(before), index(after)
+
+	var diffs []RouteDiff
+	for _, key := range unionKeys(previous, current) {
+		was, hadOld := previous[key]
+		now, hasNew := current[key]
+		switch {
+		case !hasNew:
+			diffs = append(diffs, RouteDiff{Service: was.service.Name, Route: was.route.Name, Status: "removed"})
+		case !hadOld:
+			diffs = append(diffs, RouteDiff{
+				Service: now.service.Name,
+				Route:   now.route.Name,
+				Status:  "added",
+				Changes: addedRouteChanges(after, now.service, now.route),
+			})
+		default:
+			changes := routeChanges(before, was.service, was.route, after, now.service, now.route)
+			if len(changes) > 0 {
+				diffs = append(diffs, RouteDiff{Service: now.service.Name, Route: now.route.Name, Status: "changed", Changes: changes})
+			}
+		}
+	}
+	return diffs
+}
+
+// addedRouteChanges describes what a new route exposes
+func addedRouteChanges(config *KongConfig, service Service, route Route) []RouteChange {
+	plugins := pluginsByName(config.EffectivePlugins(service, route))
+	methods := "every method"
+	if len(route.Methods) > 0 {
+		methods = strings.Join(upperAll(route.Methods), ", ")
+	}
+	changes := []RouteChange{{Message: fmt.Sprintf("paths %s, %s", strings.Join(route.Paths, ", "), methods)}}
+	if !hasAnyPlugin(plugins, authenticationPlugins) {
+		changes = append(changes, RouteChange{Message: "public: no authentication plugin", Security: true})
+	}
+	return changes
+}
+
+// routeChanges lists the semantic changes to a route that exists in both
+// configurations
+func routeChanges(before *KongConfig, oldService Service, oldRoute Route, after *KongConfig, service Service, route Route) []RouteChange {
+	var changes []RouteChange
+	add := func(security bool, format string, args ...interface{}) {
+		changes = append(changes, RouteChange{Message: fmt.Sprintf(format, args...), Security: security})
+	}
+
+	oldPlugins := pluginsByName(before.EffectivePlugins(oldService, oldRoute))
+	newPlugins := pluginsByName(after.EffectivePlugins(service, route))
+	if hasAnyPlugin(oldPlugins, authenticationPlugins) && !hasAnyPlugin(newPlugins, authenticationPlugins) {
+		add(true, "now public: %s removed", strings.Join(presentPlugins(oldPlugins, authenticationPlugins), ", "))
+	}
+
+	// Methods: routes without methods accept all of them
+	oldMethods, newMethods := upperAll(oldRoute.Methods), upperAll(route.Methods)
+	switch {
+	case len(oldMethods) > 0 && len(newMethods) == 0:
+		add(true, "methods removed: now accepts every method")
+	case len(oldMethods) == 0 && len(newMethods) > 0:
+		add(false, "methods restricted to %s", strings.Join(sortedCopy(newMethods), ", "))
+	default:
+		for _, method := range sortedCopy(newMethods) {
+			if !slices.Contains(oldMethods, method) {
+				add(!idempotentMethod(method), "new method %s", method)
+			}
+		}
+		for _, method := range sortedCopy(oldMethods) {
+			if !slices.Contains(newMethods, method) {
+				add(false, "method %s removed", method)
+			}
+		}
+	}
+
+	changes = append(changes, pathChanges(oldRoute.Paths, route.Paths)...)
+
+	// Hosts: routes without hosts accept any of them
+	switch {
+	case len(oldRoute.Hosts) > 0 && len(route.Hosts) == 0:
+		add(true, "hosts removed: now matches any host")
+	case len(oldRoute.Hosts) == 0 && len(route.Hosts) > 0:
+		add(false, "hosts restricted to %s", strings.Join(sortedCopy(route.Hosts), ", "))
+	default:
+		for _, host := range sortedCopy(route.Hosts) {
+			if !slices.Contains(oldRoute.Hosts, host) {
+				add(true, "new host %s", host)
+			}
+		}
+		for _, host := range sortedCopy(oldRoute.Hosts) {
+			if !slices.Contains(route.Hosts, host) {
+				add(false, "host %s removed", host)
+			}
+		}
+	}
+
+	if oldRoute.Priority != route.Priority {
+		add(false, "regex_priority %d → %d", oldRoute.Priority, route.Priority)
+	}
+	if a, b := kong.StripPath(oldRoute), kong.StripPath(route); a != b {
+		add(false, "strip_path %v → %v", a, b)
+	}
+	if oldRoute.PathHandling != route.PathHandling {
+		add(false, "path_handling %q → %q", oldRoute.PathHandling, route.PathHandling)
+	}
+	if oldRoute.PreserveHost != route.PreserveHost {
+		add(false, "preserve_host %v → %v", oldRoute.PreserveHost, route.PreserveHost)
+	}
+	oldURL := kong.ServiceOrigin(oldService) + kong.ServicePath(oldService)
+	newURL := kong.ServiceOrigin(service) + kong.ServicePath(service)
+	if oldURL != newURL {
+		add(false, "service URL %s → %s", oldURL, newURL)
+	}
+
+	for _, name := range unionKeys(oldPlugins, newPlugins) {
+		old, hadOld := oldPlugins[name]
+		plugin, hasNew := newPlugins[name]
+		switch {
+		case !hadOld:
+			add(false, "plugin %s added", name)
+		case !hasNew:
+			// Losing the last authentication plugin is reported above
+			if !slices.Contains(authenticationPlugins, name) || hasAnyPlugin(newPlugins, authenticationPlugins) {
+				add(slices.Contains(accessPlugins, name), "plugin %s removed", name)
+			}
+		default:
+			if diffs := configDifferences("", old.Config, plugin.Config); len(diffs) > 0 {
+				add(false, "plugin %s: %s", name, strings.Join(diffs, ", "))
+			}
+		}
+	}
+	return changes
+}
+
+// pathChanges pairs each new path with a removed path it still matches, and
+// reports it as widened when it matches requests the old path rejected
+func pathChanges(oldPaths, newPaths []string) []RouteChange {
+	var removed, added []string
+	for _, p := range oldPaths {
+		if !slices.Contains(newPaths, p) {
+			removed = append(removed, p)
+		}
+	}
+	for _, p := range newPaths {
+		if !slices.Contains(oldPaths, p) {
+			added = append(added, p)
+		}
+	}
+
+	var changes []RouteChange
+	paired := make(map[string]bool)
+	for _, p := range added {
+		replaced := false
+		for _, old := range removed {
+			if paired[old] {
+				continue
+			}
+			example, covers := widenedPath(old, p)
+			if !covers {
+				continue
+			}
+			paired[old], replaced = true, true
+			if example != "" {
+				changes = append(changes, RouteChange{Message: fmt.Sprintf("path widened: %s → %s, now also matches %s", old, p, example), Security: true})
+			} else {
+				changes = append(changes, RouteChange{Message: fmt.Sprintf("path changed: %s → %s", old, p)})
+			}
+			break
+		}
+		if !replaced {
+			changes = append(changes, RouteChange{Message: "path added: " + p})
+		}
+	}
+	for _, old := range removed {
+		if !paired[old] {
+			changes = append(changes, RouteChange{Message: "path removed: " + old})
+		}
+	}
+	return changes
+}
+
+// widenedPath reports whether the new path matches the old path's sample
+// request, and returns a request it matches that the old path rejects
+func widenedPath(oldPath, newPath string) (example string, covers bool) {
+	sample, params := expandRegexPathParams(oldPath)
+	if _, ok := kong.MatchPath(newPath, sample); !ok {
+		return "", false
+	}
+
+	candidates := boundaryPaths(oldPath, sample, params)
+	// Kong's regex paths match prefixes, so also try parameters that start
+	// with characters the old capture group never accepted
+	for _, name := range unionKeys(params, nil) {
+		value := params[name]
+		if value == "" {
+			continue
+		}
+		for _, replacement := range []string{"!", ".", "~", "%20", "/"} {
+			path := strings.Replace(sample, value, replacement, 1)
+			if _, ok := kong.MatchPath(oldPath, path); !ok {
+				candidates = append(candidates, [2]string{name + " widened", path})
+			}
+		}
+	}
+	if newSample := expandRegexPath(newPath); newSample != sample {
+		if _, ok := kong.MatchPath(oldPath, newSample); !ok {
+			candidates = append([][2]string{{"sample", newSample}}, candidates...)
+		}
+	}
+	for _, c := range candidates {
+		if _, ok := kong.MatchPath(newPath, c[1]); ok {
+			return c[1], true
+		}
+	}
+	return "", true
+}
+
+// configDifferences compares plugin configs key by key, ignoring the order
+// of list values
+func configDifferences(prefix string, before, after map[string]interface{}) []string {
+	var diffs []string
+	for _, key := range unionKeys(before, after) {
+		name := prefix + key
+		old, hadOld := before[key]
+		value, hasNew := after[key]
+		oldMap, oldIsMap := old.(map[string]interface{})
+		newMap, newIsMap := value.(map[string]interface{})
+		switch {
+		case !hadOld:
+			diffs = append(diffs, fmt.Sprintf("%s set to %v", name, value))
+		case !hasNew:
+			diffs = append(diffs, fmt.Sprintf("%s removed", name))
+		case oldIsMap && newIsMap:
+			diffs = append(diffs, configDifferences(name+".", oldMap, newMap)...)
+		case !reflect.DeepEqual(normalizeConfigValue(old), normalizeConfigValue(value)):
+			diffs = append(diffs, fmt.Sprintf("%s %v → %v", name, old, value))
+		}
+	}
+	return diffs
+}
+
+// normalizeConfigValue sorts list values so that reordering isn't a change
+func normalizeConfigValue(value interface{}) interface{} {
+	items, ok := value.([]interface{})
+	if !ok {
+		return value
+	}
+	normalized := make([]string, len(items))
+	for i, item := range items {
+		normalized[i] = fmt.Sprintf("%#v", item)
+	}
+	slices.Sort(normalized)
+	return normalized
+}
+
+func hasAnyPlugin(plugins map[string]Plugin, names []string) bool {
+	return len(presentPlugins(plugins, names)) > 0
+}
+
+// presentPlugins returns which of the named plugins are enabled
+func presentPlugins(plugins map[string]Plugin, names []string) []string {
+	var present []string
+	for _, name := range names {
+		if _, ok := plugins[name]; ok {
+			present = append(present, name)
+		}
+	}
+	return present
+}
+
+func printConfigDiff(oldName, newName string, diffs []RouteDiff) {
+	added, removed, changed, security := 0, 0, 0, 0
+	for _, d := range diffs {
+		switch d.Status {
+		case "added":
+			added++
+		case "removed":
+			removed++
+		default:
+			changed++
+		}
+		if d.Security() {
+			security++
+		}
+	}
+
+	fmt.Printf("Route changes from %s to %s:\n", oldName, newName)
+	fmt.Printf("  Changed:             %d\n", changed)
+	fmt.Printf("  Added:               %d\n", added)
+	fmt.Printf("  Removed:             %d\n", removed)
+	fmt.Printf("  Security-relevant:   %d\n", security)
+
+	var routes []RouteDiff
+	for _, d := range diffs {
+		if d.Security() {
+			routes = append(routes, d)
+		}
+	}
+	if len(routes) > 0 {
+		fmt.Println("\nSECURITY: Routes exposed more widely:")
+		for _, d := range routes {
+			fmt.Printf("  - %s/%s: ", d.Service, d.Route)
+			var messages []string
+			for _, c := range d.Changes {
+				if c.Security {
+					messages = append(messages, c.Message)
+				}
+			}
+			fmt.Println(strings.Join(messages, "; "))
+		}
+	}
+
+	if len(diffs) == 0 {
+		return
+	}
+	fmt.Println()
+	markers := map[string]string{"added": "+", "removed": "-", "changed": "~"}
+	for _, d := range diffs {
+		fmt.Printf("%s %s/%s\n", markers[d.Status], d.Service, d.Route)
+		for _, c := range d.Changes {
+			marker := " "
+			if c.Security {
+				marker = "!"
+			}
+			fmt.Printf("    %s %s\n", marker, c.Message)
+		}
+	}
+}
+
+// runDiff compares two Kong configurations without sending requests
+func runDiff(args []string) int {
+	flags := pflag.NewFlagSet("diff", pflag.ContinueOnError)
+	file := flags.String("file", "kong.yaml", "Kong configuration file that git revisions are read from")
+	flags.Usage = func() {
+		fmt.Println("Usage: kong-route-tester diff OLD NEW")
+		fmt.Println("OLD and NEW are Kong configuration files or git revisions of --file.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	var configs [2]*KongConfig
+	for i, revision := range flags.Args() {
+		config, err := readKongConfigRevision(revision, *file)
+		if err != nil {
+			fmt.Printf("Error reading Kong configuration: %v\n", err)
+			return 2
+		}
+		configs[i] = config
+	}
+
+	diffs := diffConfigs(configs[0], configs[1])
+	printConfigDiff(flags.Arg(0), flags.Arg(1), diffs)
+	if slices.ContainsFunc(diffs, RouteDiff.Security) {
+		return 1
+	}
+	return 0
+}
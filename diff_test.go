@@ -0,0 +1,211 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestDiffConfigs(t *testing.T) {
+	before := `
+_format_version: "3.0"
+services:
+  - name: api
+    url: http://api:8080
+    plugins:
+      - name: key-auth
+      - name: rate-limiting
+        config:
+          minute: 10
+          policy: local
+    routes:
+      - name: users
+        paths: ["/users/(?<id>[0-9]+)"]
+        methods: [GET]
+        hosts: [api.example.com]
+      - name: orders
+        paths: [/orders]
+        methods: [GET, POST]
+      - name: legacy
+        paths: [/legacy]
+`
+	after := `
+_format_version: "3.0"
+services:
+  - name: api
+    url: http://api:8080
+    routes:
+      - name: orders
+        methods: [GET, DELETE, POST]
+        paths: [/orders]
+      - name: users
+        paths: ["/users/(?<id>.+)"]
+        methods: [GET]
+      - name: reports
+        paths: [/reports]
+    plugins:
+      - name: rate-limiting
+        config:
+          policy: local
+          minute: 100
+      - name: key-auth
+`
+	beforeConfig, err := parseKongConfig([]byte(before))
+	if err != nil {
+		t.Fatal(err)
+	}
+	afterConfig, err := parseKongConfig([]byte(after))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type change struct {
+		message  string
+		security bool
+	}
+	want := map[string][]change{
+		"api/legacy": nil,
+		"api/orders": {
+			{"new method DELETE", true},
+		},
+		"api/reports": {
+			{"paths /reports, every method", false},
+		},
+		"api/users": {
+			{"path widened: /users/(?<id>[0-9]+) → /users/(?<id>.+), now also matches /users/x", true},
+			{"hosts removed: now matches any host", true},
+		},
+	}
+	wantStatus := map[string]string{"api/legacy": "removed", "api/orders": "changed", "api/reports": "added", "api/users": "changed"}
+
+	diffs := diffConfigs(beforeConfig, afterConfig)
+	var keys []string
+	for _, d := range diffs {
+		key := d.Service + "/" + d.Route
+		keys = append(keys, key)
+		if d.Status != wantStatus[key] {
+			t.Errorf("%s: status %s, want %s", key, d.Status, wantStatus[key])
+		}
+		var got []change
+		for _, c := range d.Changes {
+			got = append(got, change{c.Message, c.Security})
+		}
+		// The service-level rate-limiting change applies to every route
+		got = slices.DeleteFunc(got, func(c change) bool { return c.message == "plugin rate-limiting: minute 10 → 100" })
+		if !slices.Equal(got, want[key]) {
+			t.Errorf("%s: changes = %+v, want %+v", key, got, want[key])
+		}
+	}
+	if !slices.Equal(keys, []string{"api/legacy", "api/orders", "api/reports", "api/users"}) {
+		t.Errorf("routes = %q", keys)
+	}
+}
+
+func TestDiffConfigsPublicRoutes(t *testing.T) {
+	config := func(plugins ...Plugin) *KongConfig {
+		return &KongConfig{Services: []Service{{
+			Name:    "api",
+			Plugins: plugins,
+			Routes:  []Route{{Name: "users", Paths: []string{"/users"}}},
+		}}}
+	}
+	auth, jwt, acl := Plugin{Name: "auth"}, Plugin{Name: "jwt"}, Plugin{Name: "acl"}
+
+	tests := []struct {
+		name          string
+		before, after *KongConfig
+		want          []RouteChange
+	}{
+		{"unchanged", config(auth), config(auth), nil},
+		{"auth removed", config(auth, acl), config(acl), []RouteChange{{Message: "now public: auth removed", Security: true}}},
+		{"auth replaced", config(auth), config(jwt), []RouteChange{{Message: "plugin auth removed"}, {Message: "plugin jwt added"}}},
+		{"acl removed", config(auth, acl), config(auth), []RouteChange{{Message: "plugin acl removed", Security: true}}},
+		{"auth added", config(), config(auth), []RouteChange{{Message: "plugin auth added"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []RouteChange
+			if diffs := diffConfigs(tt.before, tt.after); len(diffs) > 0 {
+				got = diffs[0].Changes
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("changes = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWidenedPath(t *testing.T) {
+	tests := []struct {
+		old, new string
+		example  string
+		covers   bool
+	}{
+		{"/users/(?<id>[0-9]+)", "/users/(?<id>[0-9]+)", "", true},
+		{"/users/(?<id>[0-9]+)", "/users/(?<id>[0-9a-f]+)", "/users/a", true},
+		{"/users/(?<id>[0-9]+)$", "/users/(?<id>[0-9]+)", "/users/1/kong-route-tester", true},
+		{"/users/(?<id>[0-9]+)", "/users/(?<id>[0-9]+)/profile", "", false},
+		{"/users", "/accounts", "", false},
+	}
+	for _, tt := range tests {
+		example, covers := widenedPath(tt.old, tt.new)
+		if covers != tt.covers || (tt.example != "" && example == "") || (tt.example == "" && example != "") {
+			t.Errorf("widenedPath(%s, %s) = %q, %v, want %q, %v", tt.old, tt.new, example, covers, tt.example, tt.covers)
+		}
+	}
+}
+
+func TestRunDiff(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		filename := filepath.Join(dir, name)
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+	protected := write("protected.yaml", `
+services:
+  - name: api
+    url: http://api:8080
+    plugins: [{name: auth}]
+    routes: [{name: users, paths: [/users]}]
+`)
+	reformatted := write("reformatted.yaml", `
+services:
+- routes:
+  - paths:
+    - /users
+    name: users
+  plugins:
+  - name: auth
+  url: http://api:8080
+  name: api
+`)
+	public := write("public.yaml", `
+services:
+  - name: api
+    url: http://api:8080
+    routes: [{name: users, paths: [/users]}]
+`)
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"formatting only", []string{protected, reformatted}, 0},
+		{"auth added", []string{public, protected}, 0},
+		{"auth removed", []string{protected, public}, 1},
+		{"missing file", []string{protected, filepath.Join(dir, "missing.yaml")}, 2},
+		{"one file", []string{protected}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runDiff(tt.args); got != tt.want {
+				t.Errorf("runDiff = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
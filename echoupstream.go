@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/danpilch/kong-route-tester/kong"
+	"github.com/danpilch/kong-route-tester/mock"
+	"github.com/spf13/pflag"
+)
+
+// runEchoUpstream serves echo upstreams on the ports of the services in a
+// Kong configuration, for use behind a real Kong
+func runEchoUpstream(args []string) int {
+	flags := pflag.NewFlagSet("echo-upstream", pflag.ContinueOnError)
+	file := flags.String("file", "kong.yaml", "Path to Kong configuration file")
+	bind := flags.String("bind", "127.0.0.1", "Address to listen on (use 0.0.0.0 inside a container)")
+	port := flags.Int("port", 0, "Serve every service on this port, telling them apart by Host (0 = each service's own port)")
+	only := flags.StringSlice("service", nil, "Only serve these services (repeatable)")
+	logRequests := flags.Bool("verbose", false, "Log every request")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	config, err := readKongConfig(*file)
+	if err != nil {
+		fmt.Printf("Error reading Kong configuration: %v\n", err)
+		return 1
+	}
+
+	var services []kong.Service
+	for _, service := range config.Services {
+		if len(*only) == 0 || slices.Contains(*only, service.Name) {
+			services = append(services, service)
+		}
+	}
+
+	ports, skipped := mock.UpstreamPorts(services)
+	for _, service := range skipped {
+		fmt.Printf("Skipping %s: only plain http services can be echoed\n", service.Name)
+	}
+	if *port != 0 {
+		var all []kong.Service
+		for _, p := range ports {
+			all = append(all, p...)
+		}
+		ports = map[int][]kong.Service{*port: all}
+	}
+	if len(ports) == 0 {
+		fmt.Println("No services to serve")
+		return 1
+	}
+
+	var logger *log.Logger
+	if *logRequests {
+		logger = log.New(os.Stdout, "", log.LstdFlags)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, len(ports))
+	var servers []*http.Server
+	for _, p := range sortedPorts(ports) {
+		server := &http.Server{
+			Addr:              net.JoinHostPort(*bind, strconv.Itoa(p)),
+			Handler:           mock.NewUpstream(ports[p], logger),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		servers = append(servers, server)
+
+		fmt.Printf("Echo upstream listening on http://%s for %s\n", server.Addr, serviceNames(ports[p]))
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errs <- fmt.Errorf("%s: %w", server.Addr, err)
+			}
+		}()
+	}
+
+	status := 0
+	select {
+	case <-ctx.Done():
+	case err := <-errs:
+		fmt.Printf("Error running echo upstream: %v\n", err)
+		status = 1
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, server := range servers {
+		server.Shutdown(shutdown)
+	}
+	return status
+}
+
+func sortedPorts(ports map[int][]kong.Service) []int {
+	sorted := make([]int, 0, len(ports))
+	for port := range ports {
+		sorted = append(sorted, port)
+	}
+	sort.Ints(sorted)
+	return sorted
+}
+
+func serviceNames(services []kong.Service) string {
+	names := make([]string, 0, len(services))
+	for _, service := range services {
+		names = append(names, service.Name)
+	}
+	return strings.Join(names, ", ")
+}
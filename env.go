@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// envName selects a named environment profile from the test plan
+var envName = pflag.String("env", "", "Environment profile from the test plan to run against (overrides its environment setting)")
+
+// environment is the profile selected for this run, if any
+var (
+	environment     *PlanEnvironment
+	environmentName string
+)
+
+// writeAllowed reports whether the selected environment lets a route receive
+// a method other than GET, HEAD and OPTIONS
+func writeAllowed(route, method string) bool {
+	if environment == nil || !environment.Production {
+		return true
+	}
+	for _, allowed := range environment.AllowWrites[route] {
+		if strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+	return false
+}
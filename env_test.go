@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestWriteAllowed(t *testing.T) {
+	defer func() { environment = nil }()
+
+	production := &PlanEnvironment{
+		Production:  true,
+		AllowWrites: map[string][]string{"webhooks": {"post"}},
+	}
+	tests := []struct {
+		name   string
+		env    *PlanEnvironment
+		route  string
+		method string
+		want   bool
+	}{
+		{"no environment", nil, "users", "DELETE", true},
+		{"not production", &PlanEnvironment{URL: "https://api.dev.example.com"}, "users", "DELETE", true},
+		{"write", production, "users", "POST", false},
+		{"allowed write", production, "webhooks", "POST", true},
+		{"other write on allowed route", production, "webhooks", "DELETE", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			environment = tt.env
+			if got := writeAllowed(tt.route, tt.method); got != tt.want {
+				t.Errorf("writeAllowed(%s, %s) = %v, want %v", tt.route, tt.method, got, tt.want)
+			}
+		})
+	}
+}
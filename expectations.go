@@ -0,0 +1,381 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/danpilch/kong-route-tester/kong"
+	"github.com/spf13/pflag"
+)
+
+// Plugin expectation flags
+var (
+	clientIP     = pflag.String("client-ip", "", "Client address sent in X-Forwarded-For, used to predict ip-restriction outcomes")
+	pluginProbes = pflag.Bool("plugin-probes", false, "Send an allowed Origin to cors routes and sample headers and query parameters to request-transformer routes")
+)
+
+// ExpectationFailure is a behaviour declared by a plugin that a response
+// didn't show
+type ExpectationFailure struct {
+	Plugin  string
+	Message string
+}
+
+// pluginExpectation derives checks from a plugin declared in kong.yaml
+type pluginExpectation struct {
+	// priority is the plugin's Kong execution priority; a plugin that answers
+	// a request stops lower priority plugins from running
+	priority int
+
+	// prepare adjusts the request so the plugin's effect can be observed,
+	// when asked to with --plugin-probes or --client-ip
+	prepare func(config map[string]interface{}, req *http.Request)
+
+	// check returns how the response differed from the plugin's declared
+	// behaviour, and the status the plugin answered with, if it did
+	check func(config map[string]interface{}, resp *http.Response, body []byte) (failures []string, answered int)
+}
+
+// pluginExpectations are the providers for plugins with observable behaviour
+var pluginExpectations = map[string]pluginExpectation{
+	"cors":                {priority: 2000, prepare: prepareCORS, check: checkCORS},
+	"ip-restriction":      {priority: 990, prepare: prepareIPRestriction, check: checkIPRestriction},
+	"rate-limiting":       {priority: 910, check: checkRateLimitHeaders},
+	"request-transformer": {priority: 801, prepare: prepareTransformer, check: checkTransformer},
+	"request-termination": {priority: 2, check: checkTermination},
+}
+
+// expectedPlugins returns the target's plugins that have expectations, in
+// the order Kong runs them
+func expectedPlugins(target requestTarget) []Plugin {
+	var plugins []Plugin
+	for _, plugin := range target.Plugins {
+		if _, ok := pluginExpectations[plugin.Name]; ok {
+			plugins = append(plugins, plugin)
+		}
+	}
+	sort.SliceStable(plugins, func(i, j int) bool {
+		return pluginExpectations[plugins[i].Name].priority > pluginExpectations[plugins[j].Name].priority
+	})
+	return plugins
+}
+
+// preparePluginRequest lets each plugin's provider adjust the request
+func preparePluginRequest(target requestTarget, req *http.Request) {
+	for _, plugin := range expectedPlugins(target) {
+		if prepare := pluginExpectations[plugin.Name].prepare; prepare != nil {
+			prepare(plugin.Config, req)
+		}
+	}
+}
+
+// checkPluginExpectations checks a response against the plugins on its route,
+// returning the status a plugin answered with in place of the upstream
+func checkPluginExpectations(target requestTarget, result TestResult, resp *http.Response, body []byte) (int, []ExpectationFailure) {
+	if expectsRejection(result) || (result.RequiresAuth && resp.StatusCode == http.StatusUnauthorized) {
+		// The auth plugin answered before the others could run
+		return 0, nil
+	}
+	if noRouteMatched(resp.StatusCode, body) {
+		// The request never reached the route, e.g. one restricted to other hosts
+		return 0, nil
+	}
+
+	var failures []ExpectationFailure
+	for _, plugin := range expectedPlugins(target) {
+		messages, answered := pluginExpectations[plugin.Name].check(plugin.Config, resp, body)
+		for _, message := range messages {
+			failures = append(failures, ExpectationFailure{Plugin: plugin.Name, Message: message})
+		}
+		if answered != 0 {
+			return answered, failures
+		}
+	}
+	return 0, failures
+}
+
+// noRouteMatched reports whether a response is Kong's answer to a request
+// no route accepts
+func noRouteMatched(status int, body []byte) bool {
+	return status == http.StatusNotFound && strings.Contains(string(body), "no Route matched")
+}
+
+// request-termination
+
+func checkTermination(config map[string]interface{}, resp *http.Response, body []byte) ([]string, int) {
+	status := kong.IntValue(config, "status_code", http.StatusServiceUnavailable)
+	if resp.StatusCode != status {
+		return []string{fmt.Sprintf("expected %d, got %d", status, resp.StatusCode)}, status
+	}
+
+	if resp.Request.Method == http.MethodHead {
+		return nil, status
+	}
+
+	if want, ok := config["body"]; ok && want != nil {
+		if got := strings.TrimSpace(string(body)); got != strings.TrimSpace(fmt.Sprint(want)) {
+			return []string{fmt.Sprintf("expected body %q, got %q", truncate(fmt.Sprint(want), 40), truncate(got, 40))}, status
+		}
+		if contentType, ok := config["content_type"].(string); ok && !strings.HasPrefix(resp.Header.Get("Content-Type"), contentType) {
+			return []string{fmt.Sprintf("expected Content-Type %s, got %s", contentType, resp.Header.Get("Content-Type"))}, status
+		}
+	} else if want, ok := config["message"].(string); ok {
+		var got struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &got) != nil || got.Message != want {
+			return []string{fmt.Sprintf("expected message %q, got %q", want, truncate(strings.TrimSpace(string(body)), 40))}, status
+		}
+	}
+	return nil, status
+}
+
+// rate-limiting
+
+func checkRateLimitHeaders(config map[string]interface{}, resp *http.Response, body []byte) ([]string, int) {
+	answered := 0
+	if resp.StatusCode == http.StatusTooManyRequests {
+		answered = http.StatusTooManyRequests
+	}
+	if kong.BoolValue(config, "hide_client_headers", false) {
+		return nil, answered
+	}
+
+	var failures []string
+	for _, period := range kong.RatePeriods {
+		limit := kong.IntValue(config, period.Name, 0)
+		if limit <= 0 {
+			continue
+		}
+		if got := resp.Header.Get("X-RateLimit-Limit-" + period.Header); got != strconv.Itoa(limit) {
+			failures = append(failures, fmt.Sprintf("expected X-RateLimit-Limit-%s %d, got %q", period.Header, limit, got))
+		}
+		if resp.Header.Get("X-RateLimit-Remaining-"+period.Header) == "" {
+			failures = append(failures, "missing X-RateLimit-Remaining-"+period.Header)
+		}
+	}
+	for _, header := range []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset"} {
+		if resp.Header.Get(header) == "" {
+			failures = append(failures, "missing "+header)
+		}
+	}
+	if answered != 0 && resp.Header.Get("Retry-After") == "" {
+		failures = append(failures, "429 without Retry-After")
+	}
+	return failures, answered
+}
+
+// cors
+
+// allowedOriginSample returns an origin the cors plugin allows, for regex
+// origins an example generated from the pattern
+func allowedOriginSample(config map[string]interface{}) string {
+	origins := kong.CORSOrigins(config)
+	if len(origins) == 0 || slices.Contains(origins, "*") {
+		return "https://example.com"
+	}
+
+	for _, origin := range origins {
+		if !kong.IsRegexOrigin(origin) {
+			return origin
+		}
+		re, err := regexp.Compile(origin)
+		if err != nil {
+			continue
+		}
+		if sample := sampleRegex(origin); re.MatchString(sample) {
+			return sample
+		}
+	}
+	return ""
+}
+
+func prepareCORS(config map[string]interface{}, req *http.Request) {
+	if !*pluginProbes || req.Header.Get("Origin") != "" {
+		return
+	}
+	if origin := allowedOriginSample(config); origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+}
+
+func checkCORS(config map[string]interface{}, resp *http.Response, body []byte) ([]string, int) {
+	origin := resp.Request.Header.Get("Origin")
+	if origin == "" {
+		return nil, 0
+	}
+
+	var failures []string
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != origin && got != "*" {
+		failures = append(failures, fmt.Sprintf("expected Access-Control-Allow-Origin %s, got %q", origin, got))
+	}
+	if kong.BoolValue(config, "credentials", false) && resp.Header.Get("Access-Control-Allow-Credentials") != "true" {
+		failures = append(failures, "expected Access-Control-Allow-Credentials: true")
+	}
+	return failures, 0
+}
+
+// ip-restriction
+
+func prepareIPRestriction(config map[string]interface{}, req *http.Request) {
+	if *clientIP != "" {
+		req.Header.Set("X-Forwarded-For", *clientIP)
+	}
+}
+
+func checkIPRestriction(config map[string]interface{}, resp *http.Response, body []byte) ([]string, int) {
+	ip, err := netip.ParseAddr(*clientIP)
+	if err != nil {
+		// Without a known client address the outcome can't be predicted
+		return nil, 0
+	}
+
+	status := kong.IntValue(config, "status", http.StatusForbidden)
+	if kong.IPDenied(config, ip) {
+		if resp.StatusCode != status {
+			return []string{fmt.Sprintf("expected %d for %s, got %d", status, ip, resp.StatusCode)}, status
+		}
+		return nil, status
+	}
+	if resp.StatusCode == status {
+		return []string{fmt.Sprintf("allowed address %s rejected (%d)", ip, status)}, status
+	}
+	return nil, 0
+}
+
+// request-transformer
+
+// transformerFields are the request parts the transformer edits that an echo
+// upstream reports, with how their names compare
+var transformerFields = []struct {
+	config string
+	echo   string
+	key    func(string) string
+}{
+	{"headers", "headers", http.CanonicalHeaderKey},
+	{"querystring", "query", func(s string) string { return s }},
+}
+
+// transformerSample is sent for headers and parameters the transformer
+// removes, renames or replaces, so the change is visible upstream
+const transformerSample = "kong-route-tester"
+
+func transformerEntries(config map[string]interface{}, operation, field string) [][2]string {
+	var entries [][2]string
+	for _, entry := range kong.StringList(kong.Section(config, operation), field) {
+		name, value, _ := strings.Cut(entry, ":")
+		entries = append(entries, [2]string{strings.TrimSpace(name), strings.TrimSpace(value)})
+	}
+	return entries
+}
+
+func prepareTransformer(config map[string]interface{}, req *http.Request) {
+	if !*pluginProbes {
+		return
+	}
+	query := req.URL.Query()
+	for _, operation := range []string{"remove", "rename", "replace"} {
+		for _, entry := range transformerEntries(config, operation, "headers") {
+			if req.Header.Get(entry[0]) == "" {
+				req.Header.Set(entry[0], transformerSample)
+			}
+		}
+		for _, entry := range transformerEntries(config, operation, "querystring") {
+			if !query.Has(entry[0]) {
+				query.Set(entry[0], transformerSample)
+			}
+		}
+	}
+	req.URL.RawQuery = query.Encode()
+}
+
+// checkTransformer compares what an echo upstream received with the
+// transformations; responses that aren't echoes can't be checked
+func checkTransformer(config map[string]interface{}, resp *http.Response, body []byte) ([]string, int) {
+	var echo map[string]json.RawMessage
+	if resp.StatusCode >= 400 || json.Unmarshal(body, &echo) != nil || echo["headers"] == nil {
+		return nil, 0
+	}
+
+	var failures []string
+	if method, ok := config["http_method"].(string); ok && method != "" {
+		var got string
+		json.Unmarshal(echo["method"], &got)
+		if !strings.EqualFold(got, method) {
+			failures = append(failures, fmt.Sprintf("expected upstream method %s, got %s", strings.ToUpper(method), got))
+		}
+	}
+
+	sent := map[string]map[string][]string{
+		"headers": resp.Request.Header,
+		"query":   resp.Request.URL.Query(),
+	}
+	for _, field := range transformerFields {
+		received := make(map[string][]string)
+		var raw map[string][]string
+		json.Unmarshal(echo[field.echo], &raw)
+		for name, values := range raw {
+			received[field.key(name)] = values
+		}
+		sentValues := make(map[string][]string)
+		for name, values := range sent[field.echo] {
+			sentValues[field.key(name)] = values
+		}
+
+		label := strings.TrimSuffix(field.config, "s")
+		for _, e := range transformerEntries(config, "remove", field.config) {
+			if _, ok := received[field.key(e[0])]; ok {
+				failures = append(failures, fmt.Sprintf("%s %s was not removed", label, e[0]))
+			}
+		}
+		for _, e := range transformerEntries(config, "rename", field.config) {
+			if _, ok := sentValues[field.key(e[0])]; ok {
+				if _, ok := received[field.key(e[1])]; !ok {
+					failures = append(failures, fmt.Sprintf("%s %s was not renamed to %s", label, e[0], e[1]))
+				}
+			}
+		}
+		for _, operation := range []string{"replace", "add", "append"} {
+			for _, e := range transformerEntries(config, operation, field.config) {
+				name := field.key(e[0])
+				if operation == "replace" && sentValues[name] == nil {
+					continue
+				}
+				if operation == "add" && sentValues[name] != nil {
+					continue
+				}
+				if !slices.Contains(received[name], e[1]) {
+					failures = append(failures, fmt.Sprintf("%s %s: %s did not reach the upstream (%s)", label, e[0], e[1], operation))
+				}
+			}
+		}
+	}
+	return failures, 0
+}
+
+// printExpectationSummary lists requests whose responses didn't show the
+// behaviour their plugins declare
+func printExpectationSummary(results []TestResult) {
+	var lines []string
+	for _, result := range results {
+		for _, failure := range result.ExpectationFailures {
+			lines = append(lines, fmt.Sprintf("  - [%s] %s %s (%s/%s): %s",
+				failure.Plugin, result.Method, result.Path, result.Service, result.Route, failure.Message))
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	fmt.Println("\nPlugin Expectation Failures:")
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+}
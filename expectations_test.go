@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/danpilch/kong-route-tester/kong"
+	"github.com/danpilch/kong-route-tester/mock"
+)
+
+func TestCheckPluginExpectations(t *testing.T) {
+	termination := Plugin{Name: "request-termination", Config: map[string]interface{}{"status_code": 403, "message": "Access Denied"}}
+	rateLimit := Plugin{Name: "rate-limiting", Config: map[string]interface{}{"minute": 10}}
+	cors := Plugin{Name: "cors", Config: map[string]interface{}{"origins": []interface{}{`^https?:\/\/localhost(:\d+)?\$`}, "credentials": true}}
+
+	tests := []struct {
+		name     string
+		plugins  []Plugin
+		origin   string
+		status   int
+		headers  map[string]string
+		body     string
+		expected int
+		failures []string
+	}{
+		{
+			name:     "termination as configured",
+			plugins:  []Plugin{termination},
+			status:   403,
+			body:     `{"message":"Access Denied"}`,
+			expected: 403,
+		},
+		{
+			name:     "termination missing",
+			plugins:  []Plugin{termination},
+			status:   200,
+			expected: 403,
+			failures: []string{"request-termination: expected 403, got 200"},
+		},
+		{
+			name:     "termination message differs",
+			plugins:  []Plugin{termination},
+			status:   403,
+			body:     `{"message":"Forbidden"}`,
+			expected: 403,
+			failures: []string{`request-termination: expected message "Access Denied", got "{\"message\":\"Forbidden\"}"`},
+		},
+		{
+			name:    "rate limit headers",
+			plugins: []Plugin{rateLimit},
+			status:  200,
+			headers: map[string]string{"X-RateLimit-Limit-Minute": "10", "X-RateLimit-Remaining-Minute": "9", "RateLimit-Limit": "10", "RateLimit-Remaining": "9", "RateLimit-Reset": "30"},
+		},
+		{
+			name:     "rate limit headers missing",
+			plugins:  []Plugin{rateLimit},
+			status:   200,
+			headers:  map[string]string{"X-RateLimit-Limit-Minute": "60", "X-RateLimit-Remaining-Minute": "9", "RateLimit-Limit": "60", "RateLimit-Remaining": "59"},
+			failures: []string{`rate-limiting: expected X-RateLimit-Limit-Minute 10, got "60"`, "rate-limiting: missing RateLimit-Reset"},
+		},
+		{
+			name:     "rate limited stops later plugins",
+			plugins:  []Plugin{termination, rateLimit},
+			status:   429,
+			headers:  map[string]string{"X-RateLimit-Limit-Minute": "10", "X-RateLimit-Remaining-Minute": "0", "RateLimit-Limit": "10", "RateLimit-Remaining": "0", "RateLimit-Reset": "30", "Retry-After": "30"},
+			expected: 429,
+		},
+		{
+			name:    "cors origin allowed",
+			plugins: []Plugin{cors},
+			origin:  "http://localhost",
+			status:  200,
+			headers: map[string]string{"Access-Control-Allow-Origin": "http://localhost", "Access-Control-Allow-Credentials": "true"},
+		},
+		{
+			name:     "cors headers missing",
+			plugins:  []Plugin{cors},
+			origin:   "http://localhost",
+			status:   200,
+			failures: []string{`cors: expected Access-Control-Allow-Origin http://localhost, got ""`, "cors: expected Access-Control-Allow-Credentials: true"},
+		},
+		{
+			name:    "route not matched",
+			plugins: []Plugin{termination},
+			status:  404,
+			body:    `{"message":"no Route matched with those values"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			resp := &http.Response{StatusCode: tt.status, Header: make(http.Header), Request: req}
+			for name, value := range tt.headers {
+				resp.Header.Set(name, value)
+			}
+
+			target := requestTarget{Plugins: tt.plugins}
+			expected, failures := checkPluginExpectations(target, TestResult{}, resp, []byte(tt.body))
+			if expected != tt.expected {
+				t.Errorf("expected status = %d, want %d", expected, tt.expected)
+			}
+
+			var got []string
+			for _, f := range failures {
+				got = append(got, f.Plugin+": "+f.Message)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.failures, "\n") {
+				t.Errorf("failures = %q, want %q", got, tt.failures)
+			}
+		})
+	}
+}
+
+func TestAllowedOriginSample(t *testing.T) {
+	tests := []struct {
+		origins []interface{}
+		want    string
+	}{
+		{nil, "https://example.com"},
+		{[]interface{}{"https://app.example.com"}, "https://app.example.com"},
+		{[]interface{}{`^https?:\/\/127\.0\.0\.1(:\d+)?\$`}, "http://127.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		if got := allowedOriginSample(map[string]interface{}{"origins": tt.origins}); got != tt.want {
+			t.Errorf("allowedOriginSample(%v) = %q, want %q", tt.origins, got, tt.want)
+		}
+	}
+}
+
+// TestTestEndpointChecksPluginExpectations runs requests through the mock
+// gateway, whose plugins behave as configured
+func TestPreparePluginRequest(t *testing.T) {
+	defer func() { *pluginProbes = false }()
+
+	target := requestTarget{Plugins: []Plugin{
+		{Name: "cors", Config: map[string]interface{}{"origins": []interface{}{"https://app.example.com"}}},
+		{Name: "request-transformer", Config: map[string]interface{}{
+			"remove": map[string]interface{}{"headers": []interface{}{"X-Remove-Me"}, "querystring": []interface{}{"debug"}},
+		}},
+	}}
+
+	for _, probes := range []bool{false, true} {
+		*pluginProbes = probes
+		req := httptest.NewRequest("GET", "/users", nil)
+		preparePluginRequest(target, req)
+
+		changed := req.Header.Get("Origin") == "https://app.example.com" &&
+			req.Header.Get("X-Remove-Me") == transformerSample && req.URL.Query().Get("debug") == transformerSample
+		unchanged := len(req.Header) == 0 && req.URL.RawQuery == ""
+		if probes && !changed || !probes && !unchanged {
+			t.Errorf("plugin probes %v: request sent with headers %v and query %q", probes, req.Header, req.URL.RawQuery)
+		}
+	}
+}
+
+func TestTestEndpointChecksPluginExpectations(t *testing.T) {
+	service := Service{Name: "gateway-test", URL: "http://127.0.0.1:9001"}
+	headers := Route{Name: "test-headers", Paths: []string{"/_test/headers"}, Plugins: []Plugin{{Name: "request-transformer", Config: map[string]interface{}{
+		"add":    map[string]interface{}{"headers": []interface{}{"X-Test-Header: test-value"}},
+		"remove": map[string]interface{}{"headers": []interface{}{"X-Remove-Me"}},
+	}}}}
+	internal := Route{Name: "internal", Paths: []string{"/_test/internal"}, Plugins: []Plugin{{Name: "ip-restriction", Config: map[string]interface{}{"allow": []interface{}{"10.0.0.0/8"}}}}}
+	service.Routes = []Route{headers, internal}
+	config := &kong.Config{Services: []Service{service}}
+
+	server := httptest.NewServer(mock.NewGateway(config, mock.Options{}))
+	defer server.Close()
+
+	originalURL := *baseURL
+	*baseURL = server.URL
+	defer func() {
+		*baseURL = originalURL
+		*clientIP = ""
+	}()
+
+	target := requestTarget{Service: service, Route: headers, Pattern: "/_test/headers", Path: "/_test/headers", Method: "GET", Plugins: config.EffectivePlugins(service, headers)}
+	if result := testEndpoint(context.Background(), target, credentialVariant{}); len(result.ExpectationFailures) > 0 {
+		t.Errorf("unexpected failures for request-transformer: %v", result.ExpectationFailures)
+	}
+
+	*clientIP = "192.168.1.1"
+	target = requestTarget{Service: service, Route: internal, Pattern: "/_test/internal", Path: "/_test/internal", Method: "GET", Plugins: config.EffectivePlugins(service, internal)}
+	result := testEndpoint(context.Background(), target, credentialVariant{})
+	if result.StatusCode != 403 || result.ExpectedStatus != 403 || len(result.ExpectationFailures) > 0 {
+		t.Errorf("expected an answered 403 from ip-restriction, got %d (expected %d) %v", result.StatusCode, result.ExpectedStatus, result.ExpectationFailures)
+	}
+}
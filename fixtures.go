@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/pflag"
+	"go.yaml.in/yaml/v4"
+)
+
+// fixturesFile is the path to the request fixtures file
+var fixturesFile = pflag.String("fixtures", "", "YAML file with request bodies, headers and query parameters per route")
+
+// FixturesFile is the top level of a fixtures file
+type FixturesFile struct {
+	Fixtures []Fixture `yaml:"fixtures"`
+}
+
+// Fixture describes the request to send to matching routes. Empty match
+// fields match everything, and the most specific fixture wins.
+type Fixture struct {
+	Service string   `yaml:"service"`
+	Route   string   `yaml:"route"`
+	Path    string   `yaml:"path"`
+	Methods []string `yaml:"methods"`
+
+	ContentType string            `yaml:"content_type"`
+	Headers     map[string]string `yaml:"headers"`
+	Query       map[string]string `yaml:"query"`
+	Body        string            `yaml:"body"`
+	JSON        interface{}       `yaml:"json"`
+	BodyFile    string            `yaml:"body_file"`
+	Form        map[string]string `yaml:"form"`
+	Files       map[string]string `yaml:"files"`
+
+	// dir is the directory of the fixtures file, used to resolve relative paths
+	dir string
+}
+
+// requestTemplate is the rendered request for a single target
+type requestTemplate struct {
+	ContentType string
+	Headers     map[string]string
+	Query       url.Values
+	Body        []byte
+}
+
+// fixtures holds the loaded request fixtures
+var fixtures []Fixture
+
+func loadFixtures(filename string) ([]Fixture, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var file FixturesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(filename)
+	for i := range file.Fixtures {
+		file.Fixtures[i].dir = dir
+	}
+	return file.Fixtures, nil
+}
+
+// specificity scores how closely a fixture matches a target, or -1 if it
+// does not match at all
+func (f *Fixture) specificity(target requestTarget) int {
+	score := 0
+
+	if f.Service != "" {
+		if f.Service != target.Service.Name {
+			return -1
+		}
+		score++
+	}
+	if f.Route != "" {
+		if f.Route != target.Route.Name {
+			return -1
+		}
+		score += 2
+	}
+	if f.Path != "" {
+		if f.Path != target.Pattern && f.Path != target.Path {
+			return -1
+		}
+		score += 4
+	}
+	if len(f.Methods) > 0 {
+		if !slices.ContainsFunc(f.Methods, func(m string) bool { return strings.EqualFold(m, target.Method) }) {
+			return -1
+		}
+		score += 8
+	}
+
+	return score
+}
+
+// findFixture returns the most specific fixture for a target, or nil
+func findFixture(target requestTarget) *Fixture {
+	var best *Fixture
+	bestScore := -1
+
+	for i := range fixtures {
+		if score := fixtures[i].specificity(target); score > bestScore {
+			best, bestScore = &fixtures[i], score
+		}
+	}
+	return best
+}
+
+// templateData is available to fixture templates
+type templateData struct {
+	Service string
+	Route   string
+	Method  string
+	Pattern string
+	Path    string
+	Params  map[string]string
+}
+
+// buildRequestTemplate renders the request to send to a target. Fixtures win
+// over requests generated from the OpenAPI operation, and writes with neither
+// fall back to a placeholder JSON body.
+func buildRequestTemplate(target requestTarget) (*requestTemplate, error) {
+	fixture := findFixture(target)
+	if fixture == nil && target.Operation != nil {
+		return openAPIRequest(target.Operation)
+	}
+	if fixture == nil {
+		tmpl := &requestTemplate{}
+		if target.Method == "POST" || target.Method == "PUT" || target.Method == "PATCH" {
+			tmpl.ContentType = "application/json"
+			tmpl.Body = []byte(`{"test": "data"}`)
+		}
+		return tmpl, nil
+	}
+
+	data := templateData{
+		Service: target.Service.Name,
+		Route:   target.Route.Name,
+		Method:  target.Method,
+		Pattern: target.Pattern,
+		Path:    target.Path,
+		Params:  target.Params,
+	}
+	return fixture.render(data)
+}
+
+func (f *Fixture) render(data templateData) (*requestTemplate, error) {
+	tmpl := &requestTemplate{
+		ContentType: f.ContentType,
+		Headers:     make(map[string]string),
+		Query:       url.Values{},
+	}
+
+	for name, value := range f.Headers {
+		rendered, err := renderTemplate(value, data)
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %w", name, err)
+		}
+		tmpl.Headers[name] = rendered
+	}
+	for name, value := range f.Query {
+		rendered, err := renderTemplate(value, data)
+		if err != nil {
+			return nil, fmt.Errorf("query parameter %s: %w", name, err)
+		}
+		tmpl.Query.Set(name, rendered)
+	}
+
+	var err error
+	switch {
+	case strings.HasPrefix(tmpl.ContentType, "multipart/form-data") || len(f.Files) > 0:
+		tmpl.Body, tmpl.ContentType, err = f.renderMultipart(data)
+	case tmpl.ContentType == "application/x-www-form-urlencoded" || (len(f.Form) > 0 && tmpl.ContentType == ""):
+		tmpl.ContentType = "application/x-www-form-urlencoded"
+		tmpl.Body, err = f.renderForm(data)
+	case f.JSON != nil:
+		if tmpl.ContentType == "" {
+			tmpl.ContentType = "application/json"
+		}
+		tmpl.Body, err = f.renderJSON(data)
+	case f.BodyFile != "":
+		var content []byte
+		content, err = os.ReadFile(f.resolve(f.BodyFile))
+		if err == nil {
+			tmpl.Body, err = renderBytes(string(content), data)
+		}
+	case f.Body != "":
+		tmpl.Body, err = renderBytes(f.Body, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if tmpl.ContentType == "" && len(tmpl.Body) > 0 {
+		tmpl.ContentType = "application/json"
+	}
+	return tmpl, nil
+}
+
+func (f *Fixture) resolve(path string) string {
+	if filepath.IsAbs(path) || f.dir == "" {
+		return path
+	}
+	return filepath.Join(f.dir, path)
+}
+
+func (f *Fixture) renderJSON(data templateData) ([]byte, error) {
+	value, err := renderValue(f.JSON, data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+func (f *Fixture) renderForm(data templateData) ([]byte, error) {
+	form := url.Values{}
+	for name, value := range f.Form {
+		rendered, err := renderTemplate(value, data)
+		if err != nil {
+			return nil, fmt.Errorf("form field %s: %w", name, err)
+		}
+		form.Set(name, rendered)
+	}
+	return []byte(form.Encode()), nil
+}
+
+func (f *Fixture) renderMultipart(data templateData) ([]byte, string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	for _, name := range sortedKeys(f.Form) {
+		rendered, err := renderTemplate(f.Form[name], data)
+		if err != nil {
+			return nil, "", fmt.Errorf("form field %s: %w", name, err)
+		}
+		if err := writer.WriteField(name, rendered); err != nil {
+			return nil, "", err
+		}
+	}
+
+	for _, name := range sortedKeys(f.Files) {
+		path := f.resolve(f.Files[name])
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("file field %s: %w", name, err)
+		}
+		part, err := writer.CreateFormFile(name, filepath.Base(path))
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := part.Write(content); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return body.Bytes(), writer.FormDataContentType(), nil
+}
+
+// renderValue renders every string inside a decoded YAML value as a template
+func renderValue(value interface{}, data templateData) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return renderTemplate(v, data)
+	case map[string]interface{}:
+		rendered := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			r, err := renderValue(item, data)
+			if err != nil {
+				return nil, err
+			}
+			rendered[key] = r
+		}
+		return rendered, nil
+	case []interface{}:
+		rendered := make([]interface{}, len(v))
+		for i, item := range v {
+			r, err := renderValue(item, data)
+			if err != nil {
+				return nil, err
+			}
+			rendered[i] = r
+		}
+		return rendered, nil
+	}
+	return value, nil
+}
+
+func renderTemplate(text string, data templateData) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := template.New("fixture").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func renderBytes(text string, data templateData) ([]byte, error) {
+	rendered, err := renderTemplate(text, data)
+	return []byte(rendered), err
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
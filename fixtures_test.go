@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFixtures(t *testing.T, content string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "fixtures.yaml")
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write fixtures: %v", err)
+	}
+	return filename
+}
+
+func TestFindFixture(t *testing.T) {
+	filename := writeTestFixtures(t, `fixtures:
+  - service: media-service
+    body: service-wide
+  - route: media-upload
+    body: route-wide
+  - route: media-upload
+    path: /media/v2/clients/(?<client_id>[0-9a-fA-F-]+)/upload
+    methods: [PUT]
+    body: route-path-method
+`)
+
+	var err error
+	fixtures, err = loadFixtures(filename)
+	if err != nil {
+		t.Fatalf("loadFixtures() error = %v", err)
+	}
+	defer func() { fixtures = nil }()
+
+	media := Service{Name: "media-service"}
+	upload := Route{Name: "media-upload"}
+
+	tests := []struct {
+		name     string
+		target   requestTarget
+		expected string
+	}{
+		{
+			name:     "service match only",
+			target:   requestTarget{Service: media, Route: Route{Name: "media-assets"}, Path: "/media/v1/assets/path", Method: "GET"},
+			expected: "service-wide",
+		},
+		{
+			name:     "route beats service",
+			target:   requestTarget{Service: media, Route: upload, Path: "/media/v1/upload", Method: "POST"},
+			expected: "route-wide",
+		},
+		{
+			name: "path and method beat route",
+			target: requestTarget{
+				Service: media,
+				Route:   upload,
+				Pattern: "/media/v2/clients/(?<client_id>[0-9a-fA-F-]+)/upload",
+				Path:    "/media/v2/clients/3a45625e-fd29-47a5-8294-e30fe2d3d391/upload",
+				Method:  "PUT",
+			},
+			expected: "route-path-method",
+		},
+		{
+			name:     "no match",
+			target:   requestTarget{Service: Service{Name: "public-api"}, Route: Route{Name: "public-endpoints"}, Method: "GET"},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixture := findFixture(tt.target)
+			body := ""
+			if fixture != nil {
+				body = fixture.Body
+			}
+			if body != tt.expected {
+				t.Errorf("findFixture() body = %q, want %q", body, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBuildRequestTemplateDefaults(t *testing.T) {
+	fixtures = nil
+
+	tmpl, err := buildRequestTemplate(requestTarget{Method: "POST"})
+	if err != nil {
+		t.Fatalf("buildRequestTemplate() error = %v", err)
+	}
+	if tmpl.ContentType != "application/json" || string(tmpl.Body) != `{"test": "data"}` {
+		t.Errorf("unexpected default write request: %q %q", tmpl.ContentType, tmpl.Body)
+	}
+
+	tmpl, err = buildRequestTemplate(requestTarget{Method: "GET"})
+	if err != nil {
+		t.Fatalf("buildRequestTemplate() error = %v", err)
+	}
+	if tmpl.Body != nil || tmpl.ContentType != "" {
+		t.Errorf("expected no body for GET, got %q %q", tmpl.ContentType, tmpl.Body)
+	}
+}
+
+func TestFixtureRender(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "avatar.png"), []byte("png-bytes"), 0644); err != nil {
+		t.Fatalf("Failed to write upload file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "seat.json"), []byte(`{"seat": "{{ .Params.seat_id }}"}`), 0644); err != nil {
+		t.Fatalf("Failed to write body file: %v", err)
+	}
+
+	data := templateData{
+		Service: "auth-service",
+		Route:   "activate-seat",
+		Method:  "POST",
+		Params:  map[string]string{"seat_id": "123e4567-e89b-12d3-a456-426614174000"},
+	}
+
+	tests := []struct {
+		name                string
+		fixture             Fixture
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name: "inline json with templates",
+			fixture: Fixture{JSON: map[string]interface{}{
+				"seat":  "{{ .Params.seat_id }}",
+				"count": 2,
+				"tags":  []interface{}{"{{ .Route }}"},
+			}},
+			expectedContentType: "application/json",
+			expectedBody:        `{"count":2,"seat":"123e4567-e89b-12d3-a456-426614174000","tags":["activate-seat"]}`,
+		},
+		{
+			name:                "raw body with explicit content type",
+			fixture:             Fixture{ContentType: "text/plain", Body: "hello {{ .Service }}"},
+			expectedContentType: "text/plain",
+			expectedBody:        "hello auth-service",
+		},
+		{
+			name:                "body file relative to fixtures file",
+			fixture:             Fixture{BodyFile: "seat.json", dir: dir},
+			expectedContentType: "application/json",
+			expectedBody:        `{"seat": "123e4567-e89b-12d3-a456-426614174000"}`,
+		},
+		{
+			name:                "form urlencoded",
+			fixture:             Fixture{Form: map[string]string{"seat": "{{ .Params.seat_id }}", "mode": "activate"}},
+			expectedContentType: "application/x-www-form-urlencoded",
+			expectedBody:        "mode=activate&seat=123e4567-e89b-12d3-a456-426614174000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := tt.fixture.render(data)
+			if err != nil {
+				t.Fatalf("render() error = %v", err)
+			}
+			if tmpl.ContentType != tt.expectedContentType {
+				t.Errorf("ContentType = %q, want %q", tmpl.ContentType, tt.expectedContentType)
+			}
+			if string(tmpl.Body) != tt.expectedBody {
+				t.Errorf("Body = %q, want %q", tmpl.Body, tt.expectedBody)
+			}
+		})
+	}
+
+	t.Run("multipart upload", func(t *testing.T) {
+		fixture := Fixture{
+			Form:  map[string]string{"owner": "{{ .Params.seat_id }}"},
+			Files: map[string]string{"file": "avatar.png"},
+			dir:   dir,
+		}
+
+		tmpl, err := fixture.render(data)
+		if err != nil {
+			t.Fatalf("render() error = %v", err)
+		}
+
+		mediaType, params, err := mime.ParseMediaType(tmpl.ContentType)
+		if err != nil || mediaType != "multipart/form-data" {
+			t.Fatalf("unexpected content type %q: %v", tmpl.ContentType, err)
+		}
+
+		reader := multipart.NewReader(bytes.NewReader(tmpl.Body), params["boundary"])
+		parts := make(map[string]string)
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("NextPart() error = %v", err)
+			}
+			content, _ := io.ReadAll(part)
+			parts[part.FormName()] = string(content)
+		}
+
+		if parts["owner"] != data.Params["seat_id"] || parts["file"] != "png-bytes" {
+			t.Errorf("unexpected multipart parts: %v", parts)
+		}
+	})
+
+	t.Run("unknown capture group is an error", func(t *testing.T) {
+		fixture := Fixture{Body: "{{ .Params.missing }}"}
+		if _, err := fixture.render(data); err == nil || !strings.Contains(err.Error(), "missing") {
+			t.Errorf("expected missing key error, got %v", err)
+		}
+	})
+}
@@ -4,4 +4,4 @@ go 1.25.0
 
 require go.yaml.in/yaml/v4 v4.0.0-rc.2
 
-require github.com/spf13/pflag v1.0.10 // indirect
+require github.com/spf13/pflag v1.0.10
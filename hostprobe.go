@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/danpilch/kong-route-tester/kong"
+	"github.com/spf13/pflag"
+)
+
+// Host probe flags
+var (
+	hostProbes = pflag.Bool("host-probes", false, "Send host-restricted routes other, missing and spoofed hosts and report any the gateway routes")
+)
+
+// foreignHost is a host no route should accept
+const foreignHost = "kong-route-tester.invalid"
+
+// HostProbe is one request to a host-restricted route with a host it should refuse
+type HostProbe struct {
+	Service    string
+	Route      string
+	Path       string
+	Method     string
+	Variant    string
+	Sent       string // Request target and headers that carried the host
+	StatusCode int
+	Error      error
+
+	// Refused is true when the gateway matched no route or rejected the request
+	Refused bool
+
+	// Control probes send an accepted host to confirm the route is reachable
+	Control bool
+
+	// RoutedTo names another route Kong is expected to select for the host
+	// the probe is routed on, empty when no route should match
+	RoutedTo string
+}
+
+// GotThrough reports whether the gateway routed a probe no route should accept
+func (p HostProbe) GotThrough() bool {
+	return !p.Control && p.Error == nil && !p.Refused && p.RoutedTo == ""
+}
+
+// hostBypassFound reports whether any probe got through to a host-restricted route
+func hostBypassFound(probes []HostProbe) bool {
+	for _, probe := range probes {
+		if probe.GotThrough() {
+			return true
+		}
+	}
+	return false
+}
+
+// hostVariant is a way of presenting a host to the gateway. Requests are
+// written by hand, since net/http always sends exactly one Host header.
+type hostVariant struct {
+	name  string
+	proto string
+
+	// request returns the request target and host headers for a path and a
+	// host the route accepts
+	request func(path, host string) (target string, headers []string)
+
+	// routedOn returns the host Kong should route the request on
+	routedOn func(host string) string
+}
+
+var controlHostVariant = hostVariant{
+	name:     "allowed host",
+	request:  func(path, host string) (string, []string) { return path, []string{"Host: " + host} },
+	routedOn: func(host string) string { return host },
+}
+
+var hostVariants = []hostVariant{
+	{
+		name:     "different host",
+		request:  func(path, host string) (string, []string) { return path, []string{"Host: " + foreignHost} },
+		routedOn: func(host string) string { return foreignHost },
+	},
+	{
+		name:     "missing host",
+		proto:    "HTTP/1.0",
+		request:  func(path, host string) (string, []string) { return path, nil },
+		routedOn: func(host string) string { return "" },
+	},
+	{
+		name:     "empty host",
+		request:  func(path, host string) (string, []string) { return path, []string{"Host: "} },
+		routedOn: func(host string) string { return "" },
+	},
+	{
+		name:     "subdomain",
+		request:  func(path, host string) (string, []string) { return path, []string{"Host: kong-route-tester." + host} },
+		routedOn: func(host string) string { return "kong-route-tester." + host },
+	},
+	{
+		name:     "suffix",
+		request:  func(path, host string) (string, []string) { return path, []string{"Host: " + host + "." + foreignHost} },
+		routedOn: func(host string) string { return host + "." + foreignHost },
+	},
+	{
+		name: "X-Forwarded-Host",
+		request: func(path, host string) (string, []string) {
+			return path, []string{"Host: " + foreignHost, "X-Forwarded-Host: " + host}
+		},
+		routedOn: func(host string) string { return foreignHost },
+	},
+	{
+		name: "Forwarded",
+		request: func(path, host string) (string, []string) {
+			return path, []string{"Host: " + foreignHost, "Forwarded: host=" + host}
+		},
+		routedOn: func(host string) string { return foreignHost },
+	},
+	{
+		// The host in an absolute request target takes precedence over the
+		// Host header, so a gateway routing on the header disagrees with
+		// proxies in front of it
+		name: "absolute URI",
+		request: func(path, host string) (string, []string) {
+			return "http://" + foreignHost + path, []string{"Host: " + host}
+		},
+		routedOn: func(host string) string { return foreignHost },
+	},
+	{
+		name: "duplicate host",
+		request: func(path, host string) (string, []string) {
+			return path, []string{"Host: " + foreignHost, "Host: " + host}
+		},
+		routedOn: func(host string) string { return foreignHost },
+	},
+}
+
+// routeHost returns a host the route accepts, so probes differ from routed
+// requests only in the dimension being tested
+func routeHost(route Route) string {
+	for _, host := range route.Hosts {
+		if !strings.Contains(host, "*") {
+			return host
+		}
+	}
+	for _, host := range route.Hosts {
+		switch {
+		case strings.HasPrefix(host, "*."):
+			return "kong-route-tester" + host[1:]
+		case strings.HasSuffix(host, ".*"):
+			return host[:len(host)-1] + "invalid"
+		}
+	}
+	return ""
+}
+
+// testHostProbes sends every host variant to each path of every
+// host-restricted route, after a control request with an accepted host
+func testHostProbes(ctx context.Context, config *KongConfig) []HostProbe {
+	router := kong.NewRouter(config)
+	var probes []HostProbe
+	for _, service := range config.Services {
+		if skipService(service) {
+			continue
+		}
+		for _, route := range service.Routes {
+			host := routeHost(route)
+			if host == "" || !routeSelected(service, route) {
+				continue
+			}
+
+			for _, pattern := range route.Paths {
+				path := expandRegexPath(pattern)
+				if safetyAction("host probes", service.Name, route.Name, path, preferredMethod(route)) == safetySkip {
+					continue
+				}
+				variants := append([]hostVariant{controlHostVariant}, hostVariants...)
+				for i, variant := range variants {
+					if i > 0 && kong.MatchHost(route, variant.routedOn(host)) {
+						// The route legitimately accepts this host, e.g. through a wildcard
+						continue
+					}
+					if ctx.Err() != nil {
+						return probes
+					}
+
+					probe := HostProbe{
+						Service: service.Name,
+						Route:   route.Name,
+						Path:    path,
+						Method:  preferredMethod(route),
+						Variant: variant.name,
+						Control: i == 0,
+					}
+					target, headers := variant.request(path, host)
+					probe.Sent = strings.Join(headers, ", ")
+					if len(headers) == 0 {
+						probe.Sent = "no Host header"
+					}
+					if target != path {
+						probe.Sent += ", target " + target
+					}
+					if match, ok := router.Match(probe.Method, variant.routedOn(host), path); ok && !probe.Control && match.Route.Name != route.Name {
+						// Routes without the host restriction may serve the path too
+						probe.RoutedTo = match.Route.Name
+					}
+
+					if !*dryRun {
+						probe = sendHostProbe(ctx, variant, target, headers, probe)
+					}
+					printHostProbe(probe)
+					probes = append(probes, probe)
+
+					sleepContext(ctx, *delay)
+				}
+			}
+		}
+	}
+	return probes
+}
+
+// sendHostProbe writes the request by hand over a new connection
+func sendHostProbe(ctx context.Context, variant hostVariant, target string, headers []string, probe HostProbe) HostProbe {
+	proto := variant.proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+
+	var raw strings.Builder
+	fmt.Fprintf(&raw, "%s %s %s\r\n", probe.Method, target, proto)
+	for _, header := range headers {
+		raw.WriteString(header + "\r\n")
+	}
+	raw.WriteString("User-Agent: kong-route-tester\r\nContent-Length: 0\r\nConnection: close\r\n\r\n")
+
+	conn, err := dialGateway(ctx, probe.Service, probe.Route)
+	if err != nil {
+		probe.Error = err
+		return probe
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(*requestTimeout))
+
+	if _, err := io.WriteString(conn, raw.String()); err != nil {
+		probe.Error = err
+		return probe
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: probe.Method})
+	if err != nil {
+		probe.Error = err
+		return probe
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	probe.StatusCode = resp.StatusCode
+	probe.Refused = noRouteMatched(resp.StatusCode, body) ||
+		resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusMisdirectedRequest
+	return probe
+}
+
+// dialGateway opens a connection to the gateway, negotiating HTTP/1.1 over
+// TLS for https URLs. Proxies are not used, since the request is written raw.
+func dialGateway(ctx context.Context, service, route string) (net.Conn, error) {
+	u, err := url.Parse(*baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	network, addr := "tcp", u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		addr = net.JoinHostPort(u.Hostname(), port)
+	}
+	if *unixSocket != "" {
+		network, addr = "unix", *unixSocket
+	}
+
+	dialer := &net.Dialer{Timeout: *dialTimeout}
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil || u.Scheme != "https" {
+		return conn, err
+	}
+
+	tlsConfig, err := tlsConfigFor(service, route, "")
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = u.Hostname()
+	}
+	tlsConfig.NextProtos = []string{"http/1.1"}
+
+	tlsConn := tls.Client(conn, tlsConfig)
+	handshakeCtx, cancel := context.WithTimeout(ctx, *tlsTimeout)
+	defer cancel()
+	if err := tlsConn.HandshakeContext(handshakeCtx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+func printHostProbe(probe HostProbe) {
+	failed := probe.GotThrough() || probe.Error != nil || (probe.Control && probe.Refused)
+	if !*verbose && !failed && !*dryRun {
+		return
+	}
+
+	status := "✓"
+	switch {
+	case *dryRun:
+		status = "○"
+	case failed:
+		status = "✗"
+	}
+
+	fmt.Printf("%s %-30s %-40s %-6s %3d [HOST:%s]", status, probe.Service, truncate(probe.Path, 40), probe.Method, probe.StatusCode, probe.Variant)
+	switch {
+	case probe.Error != nil:
+		fmt.Printf(" ERROR: %v", probe.Error)
+	case probe.Control && probe.Refused:
+		fmt.Printf(" - not routed with %s", probe.Sent)
+	case probe.RoutedTo != "":
+		fmt.Printf(" - %s, routed to %s", probe.Sent, probe.RoutedTo)
+	default:
+		fmt.Printf(" - %s", probe.Sent)
+	}
+	fmt.Println()
+}
+
+func printHostProbeSummary(probes []HostProbe) {
+	if !*hostProbes || *dryRun {
+		return
+	}
+
+	var through, other, unreachable []string
+	refused, errors := 0, 0
+	for _, probe := range probes {
+		line := fmt.Sprintf("  - %s %s (%s) [%s] %s: %d", probe.Method, probe.Path, probe.Route, probe.Variant, probe.Sent, probe.StatusCode)
+		switch {
+		case probe.Error != nil:
+			errors++
+		case probe.Control:
+			if probe.Refused {
+				unreachable = append(unreachable, line)
+			}
+		case probe.Refused:
+			refused++
+		case probe.RoutedTo != "":
+			other = append(other, fmt.Sprintf("%s via %s", line, probe.RoutedTo))
+		default:
+			through = append(through, line)
+		}
+	}
+
+	fmt.Printf("\nHost Probes (%d requests):\n", len(probes))
+	fmt.Printf("  Refused:                  %d\n", refused)
+	fmt.Printf("  Got through:              %d\n", len(through))
+	fmt.Printf("  Served by other routes:   %d\n", len(other))
+	fmt.Printf("  Errors:                   %d\n", errors)
+
+	if len(through) > 0 {
+		fmt.Println("\nSECURITY: Host-restricted routes reached with other hosts:")
+		for _, line := range through {
+			fmt.Println(line)
+		}
+	}
+	if len(other) > 0 {
+		fmt.Println("\nProbes answered by routes that accept these hosts:")
+		for _, line := range other {
+			fmt.Println(line)
+		}
+	}
+	if len(unreachable) > 0 {
+		fmt.Println("\nNot routed with an allowed host, so probes for these paths prove nothing:")
+		for _, line := range unreachable {
+			fmt.Println(line)
+		}
+	}
+}
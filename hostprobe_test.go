@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouteHost(t *testing.T) {
+	tests := []struct {
+		hosts []string
+		want  string
+	}{
+		{nil, ""},
+		{[]string{"*.example.com", "admin.localhost"}, "admin.localhost"},
+		{[]string{"*.example.com"}, "kong-route-tester.example.com"},
+		{[]string{"api.*"}, "api.invalid"},
+	}
+
+	for _, tt := range tests {
+		if got := routeHost(Route{Hosts: tt.hosts}); got != tt.want {
+			t.Errorf("routeHost(%v) = %q, want %q", tt.hosts, got, tt.want)
+		}
+	}
+}
+
+func TestTestHostProbes(t *testing.T) {
+	// A gateway that wrongly routes on X-Forwarded-Host
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host == "admin.localhost" || r.Header.Get("X-Forwarded-Host") == "admin.localhost" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"no Route matched with those values"}`))
+	}))
+	defer server.Close()
+
+	originalURL := *baseURL
+	*baseURL = server.URL
+	defer func() { *baseURL = originalURL }()
+
+	config := &KongConfig{Services: []Service{{
+		Name: "admin",
+		URL:  "http://admin:8000",
+		Routes: []Route{
+			{Name: "blocked", Paths: []string{"/admin/backdoor"}, Hosts: []string{"admin.localhost"}, Methods: []string{"POST"}},
+			{Name: "public", Paths: []string{"/public"}},
+		},
+	}}}
+
+	probes := testHostProbes(context.Background(), config)
+	if len(probes) != len(hostVariants)+1 {
+		t.Fatalf("expected a control and %d probes, got %d", len(hostVariants), len(probes))
+	}
+	if control := probes[0]; !control.Control || control.Refused || control.StatusCode != http.StatusForbidden {
+		t.Errorf("expected the control request to be routed, got %+v", control)
+	}
+
+	for _, probe := range probes[1:] {
+		if probe.Error != nil {
+			t.Fatalf("%s: %v", probe.Variant, probe.Error)
+		}
+		if through := probe.Variant == "X-Forwarded-Host"; probe.GotThrough() != through {
+			t.Errorf("%s: got through = %v, want %v (%d)", probe.Variant, probe.GotThrough(), through, probe.StatusCode)
+		}
+	}
+	if !hostBypassFound(probes) {
+		t.Error("expected the X-Forwarded-Host probe to fail the run")
+	}
+}
+
+func TestTestHostProbesOtherRoutes(t *testing.T) {
+	// Every host reaches the path, through a route without a host restriction
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	originalURL := *baseURL
+	*baseURL = server.URL
+	defer func() { *baseURL = originalURL }()
+
+	config := &KongConfig{Services: []Service{{
+		Name: "admin",
+		URL:  "http://admin:8000",
+		Routes: []Route{
+			{Name: "blocked", Paths: []string{"/admin/backdoor"}, Hosts: []string{"admin.localhost"}, Methods: []string{"POST"}},
+			{Name: "admin-ui", Paths: []string{"/admin"}},
+		},
+	}}}
+
+	probes := testHostProbes(context.Background(), config)
+	for _, probe := range probes[1:] {
+		if probe.GotThrough() || probe.RoutedTo != "admin-ui" {
+			t.Errorf("%s: expected the probe to be served by admin-ui, got %+v", probe.Variant, probe)
+		}
+	}
+	if hostBypassFound(probes) {
+		t.Error("expected probes served by another route not to fail the run")
+	}
+}
@@ -10,30 +10,30 @@ import (
 	"time"
 )
 
-// TestServer holds information about a running test server
+// TestServer holds information about a running mock gateway
 type TestServer struct {
-	cmd    *exec.Cmd
-	port   int
+	cmd     *exec.Cmd
+	port    int
 	baseURL string
 }
 
-// startTestServer starts the test server and waits for it to be ready
-func startTestServer(port int, requireAuth bool, authToken string, simulateErrors bool) (*TestServer, error) {
+// startTestServer starts the mock gateway for a Kong configuration and waits
+// for it to be ready. A non-empty authToken is the only token the auth plugin
+// accepts.
+func startTestServer(port int, configFile string, authToken string) (*TestServer, error) {
 	args := []string{
+		"mock-gateway",
+		"--file", configFile,
 		"--port", fmt.Sprintf("%d", port),
 	}
-	
-	if requireAuth {
-		args = append(args, "--require-auth", "--auth-token", authToken)
-	}
-	
-	if simulateErrors {
-		args = append(args, "--simulate-errors")
+
+	if authToken != "" {
+		args = append(args, "--auth-token", authToken)
 	}
 
-	cmd := exec.Command("./test-server-bin", args...)
+	cmd := exec.Command("./kong-route-tester", args...)
 	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("failed to start test server: %v", err)
+		return nil, fmt.Errorf("failed to start mock gateway: %v", err)
 	}
 
 	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
@@ -46,13 +46,13 @@ func startTestServer(port int, requireAuth bool, authToken string, simulateError
 	// Wait for server to be ready
 	if err := server.waitForReady(); err != nil {
 		server.Stop()
-		return nil, fmt.Errorf("test server failed to start: %v", err)
+		return nil, fmt.Errorf("mock gateway failed to start: %v", err)
 	}
 
 	return server, nil
 }
 
-// waitForReady waits for the test server to respond to health checks
+// waitForReady waits for the mock gateway to respond to health checks
 func (ts *TestServer) waitForReady() error {
 	client := &http.Client{Timeout: 1 * time.Second}
 	
@@ -67,10 +67,10 @@ func (ts *TestServer) waitForReady() error {
 		time.Sleep(1 * time.Second)
 	}
 	
-	return fmt.Errorf("test server did not become ready within 30 seconds")
+	return fmt.Errorf("mock gateway did not become ready within 30 seconds")
 }
 
-// Stop stops the test server
+// Stop stops the mock gateway
 func (ts *TestServer) Stop() error {
 	if ts.cmd != nil && ts.cmd.Process != nil {
 		return ts.cmd.Process.Kill()
@@ -78,10 +78,8 @@ func (ts *TestServer) Stop() error {
 	return nil
 }
 
-// TestIntegrationWithTestServer tests the kong route tester against the test server
+// TestIntegrationWithTestServer tests the kong route tester against the mock gateway
 func TestIntegrationWithTestServer(t *testing.T) {
-	// Build both binaries if they don't exist
-	buildTestServer(t)
 	buildKongRouteTester(t)
 
 	// Create a simple test kong configuration
@@ -89,14 +87,15 @@ func TestIntegrationWithTestServer(t *testing.T) {
 	defer os.Remove(testConfig)
 
 	t.Run("test without authentication", func(t *testing.T) {
-		// Start test server without authentication
-		server, err := startTestServer(8081, false, "", false)
+		// Start the mock gateway accepting only its own token, so the auth
+		// matrix sees garbage tokens rejected
+		server, err := startTestServer(8081, testConfig, "test-integration-token")
 		if err != nil {
-			t.Fatalf("Failed to start test server: %v", err)
+			t.Fatalf("Failed to start mock gateway: %v", err)
 		}
 		defer server.Stop()
 
-		// Run kong-route-tester against the test server
+		// Run kong-route-tester against the mock gateway
 		cmd := exec.Command("./kong-route-tester", 
 			"--file", testConfig,
 			"--url", server.baseURL,
@@ -124,10 +123,10 @@ func TestIntegrationWithTestServer(t *testing.T) {
 	t.Run("test with authentication required", func(t *testing.T) {
 		authToken := "test-integration-token"
 		
-		// Start test server with authentication required
-		server, err := startTestServer(8082, true, authToken, false)
+		// Start the mock gateway accepting only authToken
+		server, err := startTestServer(8082, testConfig, authToken)
 		if err != nil {
-			t.Fatalf("Failed to start test server: %v", err)
+			t.Fatalf("Failed to start mock gateway: %v", err)
 		}
 		defer server.Stop()
 
@@ -183,10 +182,10 @@ func TestIntegrationWithTestServer(t *testing.T) {
 	})
 
 	t.Run("test filtering by route type", func(t *testing.T) {
-		// Start test server without authentication
-		server, err := startTestServer(8083, false, "", false)
+		// Start the mock gateway accepting only its own token
+		server, err := startTestServer(8083, testConfig, "test-integration-token")
 		if err != nil {
-			t.Fatalf("Failed to start test server: %v", err)
+			t.Fatalf("Failed to start mock gateway: %v", err)
 		}
 		defer server.Stop()
 
@@ -235,10 +234,10 @@ func TestIntegrationWithTestServer(t *testing.T) {
 	})
 
 	t.Run("dry run mode", func(t *testing.T) {
-		// Start test server
-		server, err := startTestServer(8084, false, "", false)
+		// Start the mock gateway
+		server, err := startTestServer(8084, testConfig, "")
 		if err != nil {
-			t.Fatalf("Failed to start test server: %v", err)
+			t.Fatalf("Failed to start mock gateway: %v", err)
 		}
 		defer server.Stop()
 
@@ -269,23 +268,12 @@ func TestIntegrationWithTestServer(t *testing.T) {
 	})
 }
 
-// buildTestServer builds the test server if it doesn't exist
-func buildTestServer(t *testing.T) {
-	if _, err := os.Stat("./test-server-bin"); os.IsNotExist(err) {
-		cmd := exec.Command("go", "build", "-o", "test-server-bin", "./test-server")
-		if err := cmd.Run(); err != nil {
-			t.Fatalf("Failed to build test server: %v", err)
-		}
-	}
-}
-
-// buildKongRouteTester builds the kong route tester if it doesn't exist
+// buildKongRouteTester builds the kong route tester, which also serves the
+// mock gateway, so the tests never run a stale binary
 func buildKongRouteTester(t *testing.T) {
-	if _, err := os.Stat("./kong-route-tester"); os.IsNotExist(err) {
-		cmd := exec.Command("go", "build", "-o", "kong-route-tester", "main.go")
-		if err := cmd.Run(); err != nil {
-			t.Fatalf("Failed to build kong-route-tester: %v", err)
-		}
+	cmd := exec.Command("go", "build", "-o", "kong-route-tester", ".")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build kong-route-tester: %v\n%s", err, output)
 	}
 }
 
@@ -403,16 +391,15 @@ malformed`
 
 // TestVerboseOutput tests verbose output functionality
 func TestVerboseOutput(t *testing.T) {
-	buildTestServer(t)
 	buildKongRouteTester(t)
 
 	testConfig := createTestKongConfig(t)
 	defer os.Remove(testConfig)
 
-	// Start test server
-	server, err := startTestServer(8085, false, "", false)
+	// Start the mock gateway accepting only its own token
+	server, err := startTestServer(8085, testConfig, "test-integration-token")
 	if err != nil {
-		t.Fatalf("Failed to start test server: %v", err)
+		t.Fatalf("Failed to start mock gateway: %v", err)
 	}
 	defer server.Stop()
 
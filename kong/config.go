@@ -0,0 +1,55 @@
+// Package kong models Kong declarative configuration and reproduces how Kong
+// routes requests, computes the paths sent to upstream services and reads
+// plugin config the way the plugins do.
+package kong
+
+// Config is a Kong declarative configuration file
+type Config struct {
+	Services []Service `yaml:"services"`
+	Plugins  []Plugin  `yaml:"plugins"` // Global plugins
+}
+
+type Service struct {
+	Name     string   `yaml:"name"`
+	URL      string   `yaml:"url"`
+	Protocol string   `yaml:"protocol"`
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"`
+	Path     string   `yaml:"path"`
+	Plugins  []Plugin `yaml:"plugins"`
+	Routes   []Route  `yaml:"routes"`
+}
+
+type Route struct {
+	Name         string   `yaml:"name"`
+	Paths        []string `yaml:"paths"`
+	Methods      []string `yaml:"methods"`
+	Hosts        []string `yaml:"hosts"`
+	Plugins      []Plugin `yaml:"plugins"`
+	Priority     int      `yaml:"regex_priority"`
+	StripPath    *bool    `yaml:"strip_path"`    // Kong defaults to true
+	PathHandling string   `yaml:"path_handling"` // v0 (default) or v1
+	PreserveHost bool     `yaml:"preserve_host"`
+}
+
+type Plugin struct {
+	Name   string                 `yaml:"name"`
+	Config map[string]interface{} `yaml:"config"`
+}
+
+// EffectivePlugins returns the plugins that run for a route, with route
+// plugins overriding service plugins overriding global plugins of the same name
+func (c *Config) EffectivePlugins(service Service, route Route) []Plugin {
+	var plugins []Plugin
+	seen := make(map[string]bool)
+
+	for _, list := range [][]Plugin{route.Plugins, service.Plugins, c.Plugins} {
+		for _, plugin := range list {
+			if !seen[plugin.Name] {
+				seen[plugin.Name] = true
+				plugins = append(plugins, plugin)
+			}
+		}
+	}
+	return plugins
+}
@@ -0,0 +1,179 @@
+package kong
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// prefixPathChars are the characters Kong allows in a plain prefix path; any
+// other character makes a path a regex in the 1.x/2.x declarative format
+const prefixPathChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789.-_~/%"
+
+// IsRegexPath reports whether Kong treats a route path as a regex
+func IsRegexPath(path string) bool {
+	if strings.HasPrefix(path, "~") {
+		return true
+	}
+	return strings.ContainsFunc(path, func(r rune) bool {
+		return !strings.ContainsRune(prefixPathChars, r)
+	})
+}
+
+var (
+	pathRegexMu sync.Mutex
+	pathRegexes = make(map[string]*regexp.Regexp)
+)
+
+// pathRegex compiles a Kong regex path, anchored at the start like Kong does
+func pathRegex(path string) (*regexp.Regexp, error) {
+	pathRegexMu.Lock()
+	defer pathRegexMu.Unlock()
+
+	if re, ok := pathRegexes[path]; ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(`^(?:` + strings.TrimPrefix(path, "~") + `)`)
+	if err != nil {
+		return nil, err
+	}
+	pathRegexes[path] = re
+	return re, nil
+}
+
+// MatchPath reports whether a request path matches a Kong route path and
+// returns the length of the matched prefix
+func MatchPath(pattern, requestPath string) (int, bool) {
+	if !IsRegexPath(pattern) {
+		if strings.HasPrefix(requestPath, pattern) {
+			return len(pattern), true
+		}
+		return 0, false
+	}
+
+	re, err := pathRegex(pattern)
+	if err != nil {
+		return 0, false
+	}
+	loc := re.FindStringIndex(requestPath)
+	if loc == nil {
+		return 0, false
+	}
+	return loc[1], true
+}
+
+// StripPath reports whether a route strips the matched path, which Kong
+// does by default
+func StripPath(route Route) bool {
+	return route.StripPath == nil || *route.StripPath
+}
+
+// ServicePath returns the path upstream requests are sent under
+func ServicePath(service Service) string {
+	if service.Path != "" {
+		return service.Path
+	}
+	if u, err := url.Parse(service.URL); err == nil && u.Path != "" {
+		return u.Path
+	}
+	return "/"
+}
+
+// ServiceOrigin returns the scheme, host and port upstream requests are sent
+// to, from either the service URL or its protocol, host and port fields
+func ServiceOrigin(service Service) string {
+	if service.URL != "" {
+		if u, err := url.Parse(service.URL); err == nil && u.Host != "" {
+			return u.Scheme + "://" + u.Host
+		}
+	}
+
+	protocol := service.Protocol
+	if protocol == "" {
+		protocol = "http"
+	}
+	port := service.Port
+	if port == 0 {
+		port = 80
+		if protocol == "https" {
+			port = 443
+		}
+	}
+	return fmt.Sprintf("%s://%s:%d", protocol, service.Host, port)
+}
+
+// UpstreamPath computes the path the upstream receives for a request that
+// matched the route path pattern, following the route's path_handling rules
+func UpstreamPath(service Service, route Route, pattern, requestPath string) string {
+	prefixLen, ok := MatchPath(pattern, requestPath)
+	if !ok {
+		prefixLen = 0
+	}
+
+	base := ServicePath(service)
+	strip := StripPath(route)
+	postfix := requestPath
+	if strip {
+		postfix = requestPath[prefixLen:]
+	}
+
+	if route.PathHandling == "v1" {
+		return upstreamPathV1(base, strip, postfix, requestPath)
+	}
+
+	if strings.HasSuffix(base, "/") {
+		if !strip {
+			return base + strings.TrimPrefix(requestPath, "/")
+		}
+		switch {
+		case postfix == "" && base == "/":
+			return "/"
+		case postfix == "" && strings.HasSuffix(requestPath, "/"):
+			return base
+		case postfix == "":
+			return strings.TrimSuffix(base, "/")
+		case strings.HasPrefix(postfix, "/"):
+			return strings.TrimSuffix(base, "/") + postfix
+		default:
+			return base + postfix
+		}
+	}
+
+	if !strip {
+		if requestPath == "/" {
+			return base
+		}
+		return base + requestPath
+	}
+	switch {
+	case postfix == "" && len(requestPath) > 1 && strings.HasSuffix(requestPath, "/"):
+		return base + "/"
+	case postfix == "":
+		return base
+	case strings.HasPrefix(postfix, "/"):
+		return base + postfix
+	default:
+		return base + "/" + postfix
+	}
+}
+
+// upstreamPathV1 applies path_handling v1, which concatenates the service path
+// with the stripped path, or the request path minus its leading slash, without
+// normalising slashes
+func upstreamPathV1(base string, strip bool, postfix, requestPath string) string {
+	if base == "/" {
+		base = ""
+	}
+
+	path := base + strings.TrimPrefix(requestPath, "/")
+	if strip {
+		path = base + postfix
+	}
+	if path == "" || path[0] != '/' {
+		path = "/" + path
+	}
+	return path
+}
@@ -0,0 +1,61 @@
+package kong
+
+import "testing"
+
+func TestIsRegexPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"/api/v1/users", false},
+		{"/media/v1/assets/%20", false},
+		{"/users/(?<user_id>[^/]+)/profile", true},
+		{"~/api/v1$", true},
+		{"/api/v1/search$", true},
+	}
+
+	for _, tt := range tests {
+		if result := IsRegexPath(tt.path); result != tt.expected {
+			t.Errorf("IsRegexPath(%q) = %v, want %v", tt.path, result, tt.expected)
+		}
+	}
+}
+
+func TestUpstreamPath(t *testing.T) {
+	noStrip := false
+
+	tests := []struct {
+		name         string
+		serviceURL   string
+		strip        *bool
+		pathHandling string
+		pattern      string
+		requestPath  string
+		expected     string
+	}{
+		{name: "strip to root", serviceURL: "http://users:8080", pattern: "/users", requestPath: "/users/42", expected: "/42"},
+		{name: "strip exact match", serviceURL: "http://users:8080", pattern: "/users", requestPath: "/users", expected: "/"},
+		{name: "strip under service path", serviceURL: "http://users:8080/internal", pattern: "/users", requestPath: "/users/42", expected: "/internal/42"},
+		{name: "strip exact match under service path", serviceURL: "http://users:8080/internal", pattern: "/users", requestPath: "/users", expected: "/internal"},
+		{name: "service path with trailing slash", serviceURL: "http://users:8080/internal/", pattern: "/users/", requestPath: "/users/42", expected: "/internal/42"},
+		{name: "no strip", serviceURL: "http://users:8080", strip: &noStrip, pattern: "/users", requestPath: "/users/42", expected: "/users/42"},
+		{name: "no strip under service path", serviceURL: "http://users:8080/internal", strip: &noStrip, pattern: "/users", requestPath: "/users/42", expected: "/internal/users/42"},
+		{name: "strip regex", serviceURL: "http://users:8080", pattern: "/users/(?<user_id>[^/]+)", requestPath: "/users/42/profile", expected: "/profile"},
+		{name: "v1 strip joins without slash", serviceURL: "http://users:8080/s", pathHandling: "v1", pattern: "/fv1", requestPath: "/fv1req", expected: "/sreq"},
+		{name: "v1 strip with slash", serviceURL: "http://users:8080/s", pathHandling: "v1", pattern: "/fv1", requestPath: "/fv1/req", expected: "/s/req"},
+		{name: "v1 no strip joins without slash", serviceURL: "http://users:8080/s", strip: &noStrip, pathHandling: "v1", pattern: "/fv1", requestPath: "/fv1req", expected: "/sfv1req"},
+		{name: "v1 no strip with slash", serviceURL: "http://users:8080/s/", strip: &noStrip, pathHandling: "v1", pattern: "/tv1", requestPath: "/tv1req", expected: "/s/tv1req"},
+		{name: "v1 no strip at root", serviceURL: "http://users:8080", strip: &noStrip, pathHandling: "v1", pattern: "/users", requestPath: "/users/42", expected: "/users/42"},
+		{name: "v1 strip to root", serviceURL: "http://users:8080", pathHandling: "v1", pattern: "/users", requestPath: "/users", expected: "/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := Service{URL: tt.serviceURL}
+			route := Route{StripPath: tt.strip, PathHandling: tt.pathHandling}
+			if result := UpstreamPath(service, route, tt.pattern, tt.requestPath); result != tt.expected {
+				t.Errorf("UpstreamPath() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
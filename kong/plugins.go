@@ -0,0 +1,178 @@
+package kong
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Readers for plugin config decoded from YAML
+
+// StringList returns a list setting with every item formatted as a string
+func StringList(config map[string]interface{}, key string) []string {
+	items, _ := config[key].([]interface{})
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		list = append(list, fmt.Sprint(item))
+	}
+	return list
+}
+
+func StringValue(config map[string]interface{}, key, fallback string) string {
+	if value, ok := config[key]; ok && value != nil {
+		return fmt.Sprint(value)
+	}
+	return fallback
+}
+
+func BoolValue(config map[string]interface{}, key string, fallback bool) bool {
+	if value, ok := config[key].(bool); ok {
+		return value
+	}
+	return fallback
+}
+
+// NumberValue reads an integer setting, which YAML and JSON decode
+// differently and some configs quote
+func NumberValue(config map[string]interface{}, key string) (int, bool) {
+	switch value := config[key].(type) {
+	case int:
+		return value, true
+	case float64:
+		return int(value), true
+	case string:
+		n, err := strconv.Atoi(value)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func IntValue(config map[string]interface{}, key string, fallback int) int {
+	if n, ok := NumberValue(config, key); ok {
+		return n
+	}
+	return fallback
+}
+
+func Section(config map[string]interface{}, key string) map[string]interface{} {
+	value, _ := config[key].(map[string]interface{})
+	return value
+}
+
+// CORSOrigins returns the origins the cors plugin allows. Declarative
+// configs escape $ from the templating engine, so the escapes are removed.
+func CORSOrigins(config map[string]interface{}) []string {
+	origins := StringList(config, "origins")
+	for i, origin := range origins {
+		origins[i] = strings.ReplaceAll(origin, `\$`, "$")
+	}
+	return origins
+}
+
+// IsRegexOrigin reports whether a cors origin is matched as a regex
+func IsRegexOrigin(origin string) bool {
+	return strings.ContainsAny(origin, `^$*+?()[]{}|\`)
+}
+
+// AllowedOrigin returns the Access-Control-Allow-Origin value for a request
+// origin. Origins containing regex characters are matched as regexes.
+func AllowedOrigin(config map[string]interface{}, origin string) (string, bool) {
+	origins := CORSOrigins(config)
+	if len(origins) == 0 || slices.Contains(origins, "*") {
+		if origin != "" && BoolValue(config, "credentials", false) {
+			// Browsers reject "*" with credentials, so Kong reflects the origin
+			return origin, true
+		}
+		return "*", true
+	}
+	if origin == "" {
+		return "", false
+	}
+
+	for _, allowed := range origins {
+		if allowed == origin {
+			return origin, true
+		}
+		if IsRegexOrigin(allowed) {
+			if re, err := regexp.Compile(allowed); err == nil && re.MatchString(origin) {
+				return origin, true
+			}
+		}
+	}
+	return "", false
+}
+
+// RatePeriods are the rate-limiting plugin's limits in the order Kong
+// reports them, with their header suffixes and window lengths
+var RatePeriods = []struct {
+	Name   string
+	Header string
+	Length time.Duration
+}{
+	{"second", "Second", time.Second},
+	{"minute", "Minute", time.Minute},
+	{"hour", "Hour", time.Hour},
+	{"day", "Day", 24 * time.Hour},
+	{"month", "Month", 30 * 24 * time.Hour},
+	{"year", "Year", 365 * 24 * time.Hour},
+}
+
+// IPDenied reports whether ip-restriction blocks an address. The deny list is
+// checked first; when an allow list is set, everything else is blocked.
+func IPDenied(config map[string]interface{}, ip netip.Addr) bool {
+	deny := append(StringList(config, "deny"), StringList(config, "blacklist")...)
+	allow := append(StringList(config, "allow"), StringList(config, "whitelist")...)
+
+	if containsIP(deny, ip) {
+		return true
+	}
+	return len(allow) > 0 && !containsIP(allow, ip)
+}
+
+func containsIP(list []string, ip netip.Addr) bool {
+	for _, entry := range list {
+		if prefix, err := netip.ParsePrefix(entry); err == nil && prefix.Contains(ip) {
+			return true
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil && addr == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpiredJWT is a well-formed but long expired and unsigned JWT, used to check
+// that gateways validate token expiry and signatures rather than structure
+var ExpiredJWT = func() string {
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload := enc.EncodeToString([]byte(`{"sub":"kong-route-tester","iat":946684800,"exp":946688400}`))
+	signature := enc.EncodeToString([]byte("not-a-real-signature"))
+	return strings.Join([]string{header, payload, signature}, ".")
+}()
+
+// JWTExpired reports whether a token is a JWT whose exp claim has passed
+func JWTExpired(token string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return false
+	}
+	return time.Unix(claims.Exp, 0).Before(time.Now())
+}
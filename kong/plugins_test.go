@@ -0,0 +1,85 @@
+package kong
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestNumberValue(t *testing.T) {
+	config := map[string]interface{}{"yaml": 10, "json": float64(20), "quoted": "30", "word": "many"}
+
+	tests := []struct {
+		key  string
+		want int
+		ok   bool
+	}{
+		{"yaml", 10, true},
+		{"json", 20, true},
+		{"quoted", 30, true},
+		{"word", 0, false},
+		{"missing", 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := NumberValue(config, tt.key); got != tt.want || ok != tt.ok {
+			t.Errorf("NumberValue(%s) = %d, %v, want %d, %v", tt.key, got, ok, tt.want, tt.ok)
+		}
+	}
+	if got := IntValue(config, "missing", 503); got != 503 {
+		t.Errorf("IntValue(missing) = %d, want the fallback", got)
+	}
+}
+
+func TestIPDenied(t *testing.T) {
+	config := map[string]interface{}{"allow": []interface{}{"10.0.0.0/8"}, "deny": []interface{}{"10.0.0.9"}}
+
+	for ip, denied := range map[string]bool{"10.1.2.3": false, "10.0.0.9": true, "192.168.1.1": true} {
+		if got := IPDenied(config, netip.MustParseAddr(ip)); got != denied {
+			t.Errorf("IPDenied(%s) = %v, want %v", ip, got, denied)
+		}
+	}
+}
+
+func TestJWTExpired(t *testing.T) {
+	enc := base64.RawURLEncoding
+	valid := enc.EncodeToString([]byte(`{"alg":"HS256"}`)) + "." +
+		enc.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, time.Now().Add(time.Hour).Unix()))) + ".signature"
+
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{"expired", ExpiredJWT, true},
+		{"valid", valid, false},
+		{"opaque token", "test-token-123", false},
+	}
+	for _, tt := range tests {
+		if got := JWTExpired(tt.token); got != tt.want {
+			t.Errorf("%s: JWTExpired() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAllowedOrigin(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		origin string
+		want   string
+		ok     bool
+	}{
+		{"any origin", map[string]interface{}{}, "https://app.example.com", "*", true},
+		{"credentials reflect the origin", map[string]interface{}{"credentials": true}, "https://app.example.com", "https://app.example.com", true},
+		{"listed", map[string]interface{}{"origins": []interface{}{"https://app.example.com"}}, "https://app.example.com", "https://app.example.com", true},
+		{"escaped regex", map[string]interface{}{"origins": []interface{}{`^https://.*\.example\.com\$`}}, "https://app.example.com", "https://app.example.com", true},
+		{"not listed", map[string]interface{}{"origins": []interface{}{"https://app.example.com"}}, "https://evil.example", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := AllowedOrigin(tt.config, tt.origin); got != tt.want || ok != tt.ok {
+			t.Errorf("%s: AllowedOrigin() = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package kong
+
+import (
+	"net"
+	"sort"
+	"strings"
+)
+
+// Router selects the route Kong would match for a request, following the
+// traditional router's precedence rules
+type Router struct {
+	candidates []candidate
+}
+
+// candidate is a single route path, or a route without paths
+type candidate struct {
+	service Service
+	route   Route
+	path    string
+	order   int
+}
+
+// Match describes the route a request matched
+type Match struct {
+	Service      Service
+	Route        Route
+	Path         string // Route path that matched, empty for routes without paths
+	UpstreamPath string
+}
+
+// NewRouter indexes every route in the configuration
+func NewRouter(config *Config) *Router {
+	var candidates []candidate
+	for _, service := range config.Services {
+		for _, route := range service.Routes {
+			if len(route.Paths) == 0 {
+				candidates = append(candidates, candidate{service: service, route: route, order: len(candidates)})
+				continue
+			}
+			for _, path := range route.Paths {
+				candidates = append(candidates, candidate{service: service, route: route, path: path, order: len(candidates)})
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].before(candidates[j])
+	})
+	return &Router{candidates: candidates}
+}
+
+// before orders candidates the way Kong evaluates them: routes with more
+// matching attributes first, then regex paths by regex_priority, then prefix
+// paths longest first
+func (c candidate) before(other candidate) bool {
+	if a, b := c.attributes(), other.attributes(); a != b {
+		return a > b
+	}
+
+	cRegex, oRegex := IsRegexPath(c.path), IsRegexPath(other.path)
+	switch {
+	case cRegex && !oRegex:
+		return true
+	case !cRegex && oRegex:
+		return false
+	case cRegex && c.route.Priority != other.route.Priority:
+		return c.route.Priority > other.route.Priority
+	case !cRegex && len(c.path) != len(other.path):
+		return len(c.path) > len(other.path)
+	}
+	return c.order < other.order
+}
+
+func (c candidate) attributes() int {
+	n := 0
+	if len(c.route.Hosts) > 0 {
+		n++
+	}
+	if len(c.route.Methods) > 0 {
+		n++
+	}
+	if c.path != "" {
+		n++
+	}
+	return n
+}
+
+// Match returns the route Kong would select for a request
+func (r *Router) Match(method, host, path string) (*Match, bool) {
+	for _, c := range r.candidates {
+		if !MatchMethod(c.route, method) || !MatchHost(c.route, host) {
+			continue
+		}
+		if c.path != "" {
+			if _, ok := MatchPath(c.path, path); !ok {
+				continue
+			}
+		}
+
+		pattern := c.path
+		if pattern == "" {
+			pattern = "/"
+		}
+		return &Match{
+			Service:      c.service,
+			Route:        c.route,
+			Path:         c.path,
+			UpstreamPath: UpstreamPath(c.service, c.route, pattern, path),
+		}, true
+	}
+	return nil, false
+}
+
+// MatchMethod reports whether a route accepts a method; routes without
+// methods accept all of them
+func MatchMethod(route Route, method string) bool {
+	if len(route.Methods) == 0 {
+		return true
+	}
+	for _, m := range route.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchHost reports whether a route accepts a Host header, supporting
+// leading and trailing wildcards. Routes without hosts accept any host.
+func MatchHost(route Route, host string) bool {
+	if len(route.Hosts) == 0 {
+		return true
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+
+	for _, pattern := range route.Hosts {
+		pattern = strings.ToLower(pattern)
+		switch {
+		case pattern == host:
+			return true
+		case strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:]):
+			return true
+		case strings.HasSuffix(pattern, ".*") && strings.HasPrefix(host, pattern[:len(pattern)-1]):
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package kong
+
+import "testing"
+
+func TestRouterMatch(t *testing.T) {
+	config := &Config{Services: []Service{
+		{
+			Name: "public-api",
+			URL:  "http://public:8002",
+			Routes: []Route{
+				{Name: "public", Paths: []string{"/api/v1"}},
+				{Name: "public-health", Paths: []string{"/api/v1/public/health"}, Methods: []string{"GET"}},
+			},
+		},
+		{
+			Name: "edge-case-service",
+			URL:  "http://edge:8007/internal",
+			Routes: []Route{
+				{Name: "catchall", Paths: []string{"/api/v1/catchall/(.*)"}, Methods: []string{"GET"}, Priority: 15},
+				{Name: "slug", Paths: []string{"/api/v1/catchall/(?<slug>[a-z]+)$"}, Methods: []string{"GET"}, Priority: 20},
+			},
+		},
+		{
+			Name: "subdomain-service",
+			URL:  "http://subdomain:8005",
+			Routes: []Route{
+				{Name: "admin", Paths: []string{"/admin"}, Hosts: []string{"admin.localhost", "*.admin.test"}},
+			},
+		},
+	}}
+	router := NewRouter(config)
+
+	tests := []struct {
+		name         string
+		method       string
+		host         string
+		path         string
+		route        string
+		upstreamPath string
+	}{
+		{name: "longest prefix with more attributes", method: "GET", path: "/api/v1/public/health", route: "public-health", upstreamPath: "/"},
+		{name: "method falls back to shorter prefix", method: "POST", path: "/api/v1/public/health", route: "public", upstreamPath: "/public/health"},
+		{name: "regex priority", method: "GET", path: "/api/v1/catchall/abc", route: "slug", upstreamPath: "/internal"},
+		{name: "lower priority regex", method: "GET", path: "/api/v1/catchall/a/b", route: "catchall", upstreamPath: "/internal"},
+		{name: "host with port", method: "GET", host: "admin.localhost:8000", path: "/admin/x", route: "admin", upstreamPath: "/x"},
+		{name: "wildcard host", method: "GET", host: "eu.admin.test", path: "/admin", route: "admin", upstreamPath: "/"},
+		{name: "wrong host", method: "GET", host: "localhost", path: "/admin", route: ""},
+		{name: "no route", method: "GET", path: "/nothing", route: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, ok := router.Match(tt.method, tt.host, tt.path)
+			if tt.route == "" {
+				if ok {
+					t.Errorf("expected no match, got %s", match.Route.Name)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("expected %s, got no match", tt.route)
+			}
+			if match.Route.Name != tt.route || match.UpstreamPath != tt.upstreamPath {
+				t.Errorf("Match() = %s %s, want %s %s", match.Route.Name, match.UpstreamPath, tt.route, tt.upstreamPath)
+			}
+		})
+	}
+}
+
+func TestEffectivePlugins(t *testing.T) {
+	config := &Config{Plugins: []Plugin{{Name: "cors"}, {Name: "rate-limiting", Config: map[string]interface{}{"minute": 100}}}}
+	service := Service{Plugins: []Plugin{{Name: "auth"}, {Name: "rate-limiting", Config: map[string]interface{}{"minute": 10}}}}
+	route := Route{Plugins: []Plugin{{Name: "auth", Config: map[string]interface{}{"enable_basic_auth": true}}}}
+
+	plugins := config.EffectivePlugins(service, route)
+	byName := make(map[string]Plugin)
+	for _, p := range plugins {
+		byName[p.Name] = p
+	}
+
+	if len(plugins) != 3 {
+		t.Fatalf("expected 3 plugins, got %d", len(plugins))
+	}
+	if byName["auth"].Config["enable_basic_auth"] != true {
+		t.Error("expected the route auth plugin to override the service one")
+	}
+	if byName["rate-limiting"].Config["minute"] != 10 {
+		t.Error("expected the service rate-limiting plugin to override the global one")
+	}
+}
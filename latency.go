@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// Latency budget flags
+var (
+	sloBudgets    = pflag.StringToString("slo", nil, "Latency budget per service or route name (name=250ms)")
+	sloDefault    = pflag.Duration("slo-default", 0, "Latency budget for routes without an --slo entry (0 = none)")
+	sloPercentile = pflag.Float64("slo-percentile", 95, "Percentile of a route's request durations compared against its budget")
+)
+
+// latencyBudgets holds the parsed --slo values
+var latencyBudgets map[string]time.Duration
+
+// routeLatency aggregates request durations for a single route
+type routeLatency struct {
+	Service   string
+	Route     string
+	Total     []time.Duration
+	FirstByte []time.Duration
+	Proxy     []time.Duration
+	Upstream  []time.Duration
+	Budget    time.Duration
+	Timeouts  int
+
+	// Connection phases of requests that opened a new connection
+	DNS     []time.Duration
+	Connect []time.Duration
+	TLS     []time.Duration
+}
+
+// kongLatencies reads the latency Kong reports for itself and the upstream
+func kongLatencies(header http.Header) (proxy, upstream time.Duration) {
+	parse := func(name string) time.Duration {
+		ms, err := strconv.Atoi(header.Get(name))
+		if err != nil {
+			return 0
+		}
+		return time.Duration(ms) * time.Millisecond
+	}
+	return parse("X-Kong-Proxy-Latency"), parse("X-Kong-Upstream-Latency")
+}
+
+// parseSLOBudgets validates the --slo flag values
+func parseSLOBudgets() (map[string]time.Duration, error) {
+	budgets := make(map[string]time.Duration)
+	for name, value := range *sloBudgets {
+		budget, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid latency budget for %s: %w", name, err)
+		}
+		budgets[name] = budget
+	}
+	if *sloPercentile <= 0 || *sloPercentile > 100 {
+		return nil, fmt.Errorf("--slo-percentile must be between 0 and 100")
+	}
+	return budgets, nil
+}
+
+// budgetFor returns the latency budget for a route, preferring a route name
+// budget over a service name budget over the default
+func budgetFor(service, route string) time.Duration {
+	if budget, ok := latencyBudgets[route]; ok {
+		return budget
+	}
+	if budget, ok := latencyBudgets[service]; ok {
+		return budget
+	}
+	return *sloDefault
+}
+
+// collectLatencies groups request durations by route. Only requests sent the
+// way clients send them count: auth matrix, certificate and probe requests are
+// mostly fast rejections by the gateway. Timeouts count at their elapsed time.
+func collectLatencies(results []TestResult) []*routeLatency {
+	byRoute := make(map[string]*routeLatency)
+	var routes []*routeLatency
+
+	for _, result := range results {
+		if (result.AuthMode != "" && result.AuthMode != authValid) || result.CertMode != "" || result.Probe {
+			continue
+		}
+		timedOut := result.Error != nil && errorClass(result.Error) == "timeout"
+		if result.Timings.Total == 0 || (result.Error != nil && !timedOut) {
+			continue
+		}
+
+		key := result.Service + "/" + result.Route
+		latency, ok := byRoute[key]
+		if !ok {
+			latency = &routeLatency{
+				Service: result.Service,
+				Route:   result.Route,
+				Budget:  budgetFor(result.Service, result.Route),
+			}
+			byRoute[key] = latency
+			routes = append(routes, latency)
+		}
+
+		latency.Total = append(latency.Total, result.Timings.Total)
+		if timedOut {
+			latency.Timeouts++
+			continue
+		}
+		latency.FirstByte = append(latency.FirstByte, result.Timings.FirstByte)
+		if !result.Timings.Reused {
+			latency.DNS = append(latency.DNS, result.Timings.DNS)
+			latency.Connect = append(latency.Connect, result.Timings.Connect)
+			latency.TLS = append(latency.TLS, result.Timings.TLS)
+		}
+		if result.ProxyLatency > 0 || result.UpstreamLatency > 0 {
+			latency.Proxy = append(latency.Proxy, result.ProxyLatency)
+			latency.Upstream = append(latency.Upstream, result.UpstreamLatency)
+		}
+	}
+
+	return routes
+}
+
+// percentile returns the nearest-rank percentile of a set of durations
+func percentile(durations []time.Duration, p float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// exceeded reports whether the route's latency percentile is over its budget
+func (l *routeLatency) exceeded() bool {
+	return l.Budget > 0 && percentile(l.Total, *sloPercentile) > l.Budget
+}
+
+// sloBreaches returns the routes whose latency exceeded their budget
+func sloBreaches(results []TestResult) []*routeLatency {
+	var breaches []*routeLatency
+	for _, latency := range collectLatencies(results) {
+		if latency.exceeded() {
+			breaches = append(breaches, latency)
+		}
+	}
+	return breaches
+}
+
+func printLatencySummary(results []TestResult) {
+	routes := collectLatencies(results)
+	if len(routes) == 0 {
+		return
+	}
+
+	ms := func(d time.Duration) string { return d.Round(time.Millisecond).String() }
+
+	fmt.Println("\nLatency By Route:")
+	for _, l := range routes {
+		fmt.Printf("  %-30s p50=%-7s p95=%-7s p99=%-7s",
+			truncate(l.Route, 30),
+			ms(percentile(l.Total, 50)),
+			ms(percentile(l.Total, 95)),
+			ms(percentile(l.Total, 99)))
+
+		fmt.Printf(" ttfb-p95=%-7s", ms(percentile(l.FirstByte, 95)))
+		if len(l.Connect) > 0 {
+			fmt.Printf(" dns-p95=%-7s connect-p95=%-7s", ms(percentile(l.DNS, 95)), ms(percentile(l.Connect, 95)))
+			if tls := percentile(l.TLS, 95); tls > 0 {
+				fmt.Printf(" tls-p95=%-7s", ms(tls))
+			}
+		}
+		if len(l.Upstream) > 0 {
+			fmt.Printf(" kong-p95=%-7s upstream-p95=%-7s", ms(percentile(l.Proxy, 95)), ms(percentile(l.Upstream, 95)))
+		}
+
+		if l.Timeouts > 0 {
+			fmt.Printf(" timeouts=%d", l.Timeouts)
+		}
+
+		if l.Budget > 0 {
+			status := "OK"
+			if l.exceeded() {
+				status = "EXCEEDED"
+			}
+			fmt.Printf(" budget=%s %s", ms(l.Budget), status)
+		}
+		fmt.Println()
+	}
+
+	breaches := sloBreaches(results)
+	if len(breaches) > 0 {
+		fmt.Printf("\nLatency SLO Failures (p%g over budget):\n", *sloPercentile)
+		for _, l := range breaches {
+			fmt.Printf("  - %s (%s): p%g=%s budget=%s\n", l.Route, l.Service, *sloPercentile,
+				ms(percentile(l.Total, *sloPercentile)), ms(l.Budget))
+		}
+	}
+}
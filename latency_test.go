@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	durations := []time.Duration{
+		50 * time.Millisecond, 10 * time.Millisecond, 40 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond,
+		60 * time.Millisecond, 70 * time.Millisecond, 80 * time.Millisecond, 100 * time.Millisecond, 90 * time.Millisecond,
+	}
+
+	tests := []struct {
+		name       string
+		durations  []time.Duration
+		percentile float64
+		expected   time.Duration
+	}{
+		{name: "median", durations: durations, percentile: 50, expected: 50 * time.Millisecond},
+		{name: "p95 rounds up to the highest rank", durations: durations, percentile: 95, expected: 100 * time.Millisecond},
+		{name: "p10", durations: durations, percentile: 10, expected: 10 * time.Millisecond},
+		{name: "single value", durations: []time.Duration{time.Second}, percentile: 99, expected: time.Second},
+		{name: "no values", durations: nil, percentile: 50, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := percentile(tt.durations, tt.percentile)
+			if result != tt.expected {
+				t.Errorf("percentile() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestKongLatencies(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Kong-Proxy-Latency", "3")
+	header.Set("X-Kong-Upstream-Latency", "42")
+
+	proxy, upstream := kongLatencies(header)
+	if proxy != 3*time.Millisecond || upstream != 42*time.Millisecond {
+		t.Errorf("kongLatencies() = %v, %v, want 3ms, 42ms", proxy, upstream)
+	}
+
+	proxy, upstream = kongLatencies(http.Header{})
+	if proxy != 0 || upstream != 0 {
+		t.Errorf("kongLatencies() without headers = %v, %v, want 0, 0", proxy, upstream)
+	}
+}
+
+func TestParseSLOBudgets(t *testing.T) {
+	defer func() { *sloBudgets = nil }()
+
+	*sloBudgets = map[string]string{"user-data": "250ms"}
+	budgets, err := parseSLOBudgets()
+	if err != nil {
+		t.Fatalf("parseSLOBudgets() error = %v", err)
+	}
+	if budgets["user-data"] != 250*time.Millisecond {
+		t.Errorf("expected 250ms budget, got %v", budgets["user-data"])
+	}
+
+	*sloBudgets = map[string]string{"user-data": "fast"}
+	if _, err := parseSLOBudgets(); err == nil {
+		t.Error("expected error for invalid duration, got nil")
+	}
+}
+
+func TestSLOBreaches(t *testing.T) {
+	latencyBudgets = map[string]time.Duration{
+		"protected-api":   100 * time.Millisecond,
+		"admin-endpoints": 500 * time.Millisecond,
+	}
+	defer func() { latencyBudgets = nil }()
+
+	result := func(route string, total time.Duration) TestResult {
+		return TestResult{Service: "protected-api", Route: route, StatusCode: 200, Timings: Timings{Total: total}}
+	}
+
+	results := []TestResult{
+		result("user-data", 50*time.Millisecond),
+		result("user-data", 150*time.Millisecond),
+		result("admin-endpoints", 150*time.Millisecond),
+		result("admin-endpoints", 300*time.Millisecond),
+		// Dry runs carry no timing and are ignored
+		{Service: "protected-api", Route: "user-data"},
+		// So are fast rejections of bad credentials and refused connections
+		{Service: "protected-api", Route: "admin-endpoints", AuthMode: authNone, StatusCode: 401, Timings: Timings{Total: time.Millisecond}},
+		{Service: "protected-api", Route: "admin-endpoints", CertMode: certNone, StatusCode: 400, Timings: Timings{Total: time.Millisecond}},
+		{Service: "protected-api", Route: "admin-endpoints", Error: syscall.ECONNREFUSED, Timings: Timings{Total: time.Millisecond}},
+		// A route whose requests all time out is over budget
+		{Service: "protected-api", Route: "reports", Error: context.DeadlineExceeded, Timings: Timings{Total: 30 * time.Second}},
+	}
+
+	latencies := collectLatencies(results)
+	if len(latencies) != 3 || len(latencies[0].FirstByte) != 2 || len(latencies[0].Connect) != 2 {
+		t.Errorf("expected phase timings for both user-data requests, got %+v", latencies)
+	}
+	if len(latencies) == 3 && (len(latencies[1].Total) != 2 || latencies[2].Timeouts != 1) {
+		t.Errorf("expected only admin-endpoints requests sent as clients send them and one timeout, got %+v", latencies)
+	}
+
+	breaches := sloBreaches(results)
+	if len(breaches) != 2 {
+		t.Fatalf("expected 2 breaches, got %d", len(breaches))
+	}
+	if breaches[0].Route != "user-data" || len(breaches[0].Total) != 2 {
+		t.Errorf("unexpected breach %+v", breaches[0])
+	}
+	if breaches[1].Route != "reports" {
+		t.Errorf("expected the timed out route to breach, got %+v", breaches[1])
+	}
+}
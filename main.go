@@ -2,43 +2,51 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"regexp"
+	"slices"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/danpilch/kong-route-tester/kong"
 	"github.com/spf13/pflag"
 	"go.yaml.in/yaml/v4"
 )
 
 // Kong configuration structures
-type KongConfig struct {
-	Services []Service `yaml:"services"`
-}
+type (
+	KongConfig = kong.Config
+	Service    = kong.Service
+	Route      = kong.Route
+	Plugin     = kong.Plugin
+)
 
-type Service struct {
-	Name    string   `yaml:"name"`
-	URL     string   `yaml:"url"`
-	Plugins []Plugin `yaml:"plugins"`
-	Routes  []Route  `yaml:"routes"`
-}
+// requestTarget identifies the route and concrete path a request is sent to
+type requestTarget struct {
+	Service      Service
+	Route        Route
+	Pattern      string            // Path as declared in the Kong configuration
+	Path         string            // Pattern with regexes replaced by example values
+	Params       map[string]string // Values chosen for named capture groups
+	Method       string
+	RequiresAuth bool
 
-type Route struct {
-	Name     string   `yaml:"name"`
-	Paths    []string `yaml:"paths"`
-	Methods  []string `yaml:"methods"`
-	Hosts    []string `yaml:"hosts"`
-	Plugins  []Plugin `yaml:"plugins"`
-	Priority int      `yaml:"regex_priority"`
-}
+	// Plugins that run for the route, global plugins included
+	Plugins []Plugin
+
+	// Operation is the OpenAPI operation the request exercises, if any
+	Operation *openAPIOperation
 
-type Plugin struct {
-	Name   string                 `yaml:"name"`
-	Config map[string]interface{} `yaml:"config"`
+	// Probe sends the request without credentials and with an invalid body
+	Probe bool
 }
 
 // Test result structures
@@ -48,26 +56,135 @@ type TestResult struct {
 	Path         string
 	Method       string
 	RequiresAuth bool
+	AuthMode     string
+	CertMode     string
+	CertRejected bool // TLS handshake refused the negative client certificate
+	Probe        bool // Write neutralized by --safe-mode probe-only
 	StatusCode   int
 	Error        error
 	Message      string
+	Timings      Timings
+
+	// Latency reported by Kong in X-Kong-Proxy-Latency and X-Kong-Upstream-Latency
+	ProxyLatency    time.Duration
+	UpstreamLatency time.Duration
+
+	// Attempts made under the retry policy, oldest first
+	Attempts int
+	History  []Attempt
+
+	// OpenAPI operation exercised and how the response broke its contract
+	Operation      string
+	ContractErrors []string
+
+	// URL Kong is expected to forward the request to, and how the path an
+	// echo upstream reported differed from it
+	UpstreamURL   string
+	UpstreamError string
+
+	// Status a plugin answered with in place of the upstream, and the
+	// declared plugin behaviour the response didn't show
+	ExpectedStatus      int
+	ExpectationFailures []ExpectationFailure
 }
 
 // Configuration flags
 var (
 	kongFile    = pflag.String("file", "kong.yaml", "Path to Kong configuration file")
-	baseURL     = pflag.String("url", "https://api.dev.community.com", "Base URL for testing")
+	baseURL     = pflag.String("url", "http://localhost:8000", "Base URL for testing")
 	authToken   = pflag.String("token", "", "Authentication token for testing authenticated routes")
 	testAuth    = pflag.Bool("test-auth", true, "Test authenticated routes")
 	testUnauth  = pflag.Bool("test-unauth", true, "Test unauthenticated routes")
 	verbose     = pflag.Bool("verbose", false, "Verbose output")
 	dryRun      = pflag.Bool("dry-run", false, "Dry run - show what would be tested without making requests")
 	maxRequests = pflag.Int("max", 0, "Maximum number of requests to make (0 = unlimited)")
+	deadline    = pflag.Duration("deadline", 0, "Maximum total run time; no new requests are started after it (0 = unlimited)")
+	delay       = pflag.Duration("delay", 100*time.Millisecond, "Pause between requests")
+	onlyService = pflag.StringSlice("service", nil, "Only test these services (repeatable)")
+	onlyRoute   = pflag.StringSlice("route", nil, "Only test these routes (repeatable)")
 )
 
+// subcommands run instead of the route tester when named as the first argument
+var subcommands = map[string]func(args []string) int{
+	"mock-gateway":  runMockGateway,
+	"echo-upstream": runEchoUpstream,
+	"validate-plan": runValidatePlan,
+	"compare":       runCompare,
+	"diff":          runDiff,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(run(os.Args[2:]))
+		}
+	}
+
 	pflag.Parse()
 
+	if *planFile != "" {
+		plan, err := loadPlan(*planFile)
+		if err == nil {
+			if *envName != "" {
+				plan.Environment = *envName
+			}
+			err = applyPlan(plan, pflag.CommandLine)
+		}
+		if err == nil {
+			environment, err = plan.selectedEnvironment()
+			environmentName = plan.Environment
+		}
+		if err != nil {
+			fmt.Printf("Error reading test plan: %v\n", err)
+			os.Exit(1)
+		}
+	} else if *envName != "" {
+		fmt.Println("Error: --env selects a profile from a test plan, use it with --plan")
+		os.Exit(1)
+	}
+
+	var err error
+	oauth, err = newOAuthClientFromFlags()
+	if err != nil {
+		fmt.Printf("Error configuring OAuth2: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *fixturesFile != "" {
+		fixtures, err = loadFixtures(*fixturesFile)
+		if err != nil {
+			fmt.Printf("Error reading fixtures: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	openAPISpecs, err = loadOpenAPISpecs()
+	if err != nil {
+		fmt.Printf("Error reading OpenAPI specs: %v\n", err)
+		os.Exit(1)
+	}
+
+	latencyBudgets, err = parseSLOBudgets()
+	if err != nil {
+		fmt.Printf("Error parsing latency budgets: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := parseSafetyFlags(); err != nil {
+		fmt.Printf("Error configuring safety policy: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Read the baseline up front, it may be the file this run overwrites
+	var baseline *Baseline
+	if *baselineFile != "" {
+		baseline, err = loadBaseline(*baselineFile)
+		if err != nil {
+			fmt.Printf("Error reading baseline: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Read Kong configuration
 	config, err := readKongConfig(*kongFile)
 	if err != nil {
@@ -75,11 +192,117 @@ func main() {
 		os.Exit(1)
 	}
 
+	if *changedFrom != "" {
+		previous, err := readKongConfigRevision(*changedFrom, *kongFile)
+		if err != nil {
+			fmt.Printf("Error reading previous Kong configuration: %v\n", err)
+			os.Exit(1)
+		}
+		changedRoutes = findChangedRoutes(previous, config)
+		printChangedRoutes(*changedFrom, changedRoutes)
+		if len(changedRoutes) == 0 {
+			return
+		}
+	}
+
+	if err := writeCoverageReports(config); err != nil {
+		fmt.Printf("Error writing coverage report: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *authMatrix && !hasCredentials() {
+		fmt.Println("Warning: no --token or OAuth2 settings, the auth matrix will skip valid-credential checks")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		// Restore the default behaviour so a second Ctrl-C exits immediately
+		stop()
+	}()
+
+	runCtx := ctx
+	if *deadline > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, *deadline)
+		defer cancel()
+	}
+
 	// Run tests
-	results := testRoutes(config)
+	results := testRoutes(runCtx, config)
+	var corsChecks []CORSCheck
+	if *corsMode {
+		corsChecks = testCORS(runCtx, config)
+	}
+	var bypasses []BypassProbe
+	if *bypassProbes {
+		bypasses = testBypasses(runCtx, config)
+	}
+	var hostChecks []HostProbe
+	if *hostProbes {
+		hostChecks = testHostProbes(runCtx, config)
+	}
+	var negativeChecks []NegativeProbe
+	if *negativeMode {
+		negativeChecks = testNegative(runCtx, config)
+	}
+	var rateLimitChecks []RateLimitCheck
+	if *rateLimitTest {
+		rateLimitChecks = testRateLimits(runCtx, config)
+	}
+
+	if ctx.Err() != nil {
+		fmt.Printf("\nInterrupted: reporting %d completed requests\n", len(results))
+	} else if runCtx.Err() != nil {
+		fmt.Printf("\nDeadline of %s reached: reporting %d completed requests\n", *deadline, len(results))
+	}
 
 	// Print summary
 	printSummary(results)
+	printOpenAPIReport(config, results)
+	printCORSSummary(corsChecks)
+	printBypassSummary(bypasses)
+	printHostProbeSummary(hostChecks)
+	printNegativeSummary(negativeChecks)
+	printRateLimitSummary(rateLimitChecks)
+	printSafetySummary(results)
+
+	var current *Baseline
+	if !*dryRun && (baseline != nil || *saveBaseline != "") {
+		current = newBaseline(config, results)
+	}
+	var diff BaselineDiff
+	if current != nil && baseline != nil {
+		diff = compareBaselines(baseline, current, *latencyRegression, *latencyMinimum)
+		printBaselineDiff(*baselineFile, baseline, diff)
+	}
+	if current != nil && *saveBaseline != "" && ctx.Err() == nil {
+		if err := writeBaseline(*saveBaseline, current); err != nil {
+			fmt.Printf("Error writing baseline: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("\nBaseline written to %s\n", *saveBaseline)
+	}
+
+	if ctx.Err() != nil {
+		os.Exit(130)
+	}
+
+	// Security findings fail the run even when they are already in the baseline
+	if acceptedBadCredentials(results) || bypassFound(bypasses) || hostBypassFound(hostChecks) {
+		os.Exit(1)
+	}
+	if baseline != nil {
+		// Failures and slow routes already in the baseline don't fail the run
+		if diff.Regressions() > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+	if len(sloBreaches(results)) > 0 {
+		os.Exit(1)
+	}
 }
 
 func readKongConfig(filename string) (*KongConfig, error) {
@@ -87,12 +310,15 @@ func readKongConfig(filename string) (*KongConfig, error) {
 	if err != nil {
 		return nil, err
 	}
+	return parseKongConfig(data)
+}
 
+func parseKongConfig(data []byte) (*KongConfig, error) {
 	// Handle environment variable substitution (basic sigil templating)
 	data = handleTemplating(data)
 
 	var config KongConfig
-	err = yaml.Unmarshal(data, &config)
+	err := yaml.Unmarshal(data, &config)
 	if err != nil {
 		return nil, err
 	}
@@ -124,16 +350,12 @@ func handleTemplating(data []byte) []byte {
 	return result
 }
 
-func testRoutes(config *KongConfig) []TestResult {
+func testRoutes(ctx context.Context, config *KongConfig) []TestResult {
 	var results []TestResult
 	requestCount := 0
 
 	for _, service := range config.Services {
-		// Skip certain test services
-		if strings.Contains(service.Name, "test") ||
-			strings.Contains(service.Name, "health-check") ||
-			service.Name == "atlantis" ||
-			service.Name == "atlantis-legacy" {
+		if skipService(service) {
 			if *verbose {
 				fmt.Printf("Skipping test service: %s\n", service.Name)
 			}
@@ -141,6 +363,9 @@ func testRoutes(config *KongConfig) []TestResult {
 		}
 
 		for _, route := range service.Routes {
+			if !routeSelected(service, route) {
+				continue
+			}
 			hasAuth := hasAuthPlugin(route, service)
 
 			// Check if we should test this route
@@ -151,31 +376,50 @@ func testRoutes(config *KongConfig) []TestResult {
 				continue
 			}
 
-			// Determine methods to test
-			methods := route.Methods
-			if len(methods) == 0 {
-				// No methods specified means all methods in Kong 3.x
-				methods = []string{"GET", "POST", "PUT", "DELETE", "PATCH"}
-			}
-
 			// Test each path/method combination
-			for _, path := range route.Paths {
-				// Skip regex patterns for now unless we have specific test cases
-				if strings.Contains(path, "(?<") {
-					path = expandRegexPath(path)
-				}
-
-				for _, method := range methods {
-					if *maxRequests > 0 && requestCount >= *maxRequests {
-						return results
+			for _, pattern := range route.Paths {
+				// Regex paths are sent with example values for each capture group
+				path, params := expandRegexPathParams(pattern)
+
+				for _, method := range routeMethods(route) {
+					action := safetyAction("routes", service.Name, route.Name, path, method)
+					if action == safetySkip {
+						continue
+					}
+					target := requestTarget{
+						Service:      service,
+						Route:        route,
+						Pattern:      pattern,
+						Path:         path,
+						Params:       params,
+						Method:       method,
+						RequiresAuth: hasAuth,
+						Plugins:      config.EffectivePlugins(service, route),
+						Probe:        action == safetyProbe,
+					}
+					variants := credentialVariants(route, service, hasAuth)
+					if target.Probe {
+						variants = []credentialVariant{probeCredentials(hasAuth)}
 					}
 
-					result := testEndpoint(service.Name, route.Name, path, method, hasAuth)
-					results = append(results, result)
-					requestCount++
-
-					// Rate limiting
-					time.Sleep(100 * time.Millisecond)
+					for _, target := range withOperations(target) {
+						for _, creds := range variants {
+							if *maxRequests > 0 && requestCount >= *maxRequests {
+								return results
+							}
+							if ctx.Err() != nil {
+								// Interrupted or out of time, stop scheduling requests
+								return results
+							}
+
+							result := testEndpoint(ctx, target, creds)
+							results = append(results, result)
+							requestCount++
+
+							// Rate limiting
+							sleepContext(ctx, *delay)
+						}
+					}
 				}
 			}
 		}
@@ -184,17 +428,82 @@ func testRoutes(config *KongConfig) []TestResult {
 	return results
 }
 
+// skipService reports whether a service is for gateway self-tests rather
+// than real traffic
+func skipService(service Service) bool {
+	return strings.Contains(service.Name, "test") ||
+		strings.Contains(service.Name, "health-check") ||
+		service.Name == "atlantis" ||
+		service.Name == "atlantis-legacy"
+}
+
+// routeSelected reports whether a route passes the --service, --route and
+// --changed-from filters
+func routeSelected(service Service, route Route) bool {
+	if len(*onlyService) > 0 && !slices.Contains(*onlyService, service.Name) {
+		return false
+	}
+	if changedRoutes != nil && changedRoutes[routeKey(service, route)] == nil {
+		return false
+	}
+	return len(*onlyRoute) == 0 || slices.Contains(*onlyRoute, route.Name)
+}
+
+// routeMethods returns the methods to test on a route
+func routeMethods(route Route) []string {
+	if len(route.Methods) == 0 {
+		// No methods specified means all methods in Kong 3.x
+		return []string{"GET", "POST", "PUT", "DELETE", "PATCH"}
+	}
+	return route.Methods
+}
+
+// preferredMethod returns the method for checks that send one request per
+// route, preferring GET so they have no side effects upstream
+func preferredMethod(route Route) string {
+	methods := routeMethods(route)
+	if slices.Contains(methods, http.MethodGet) {
+		return http.MethodGet
+	}
+	return methods[0]
+}
+
+// withOperations expands a target into one target per OpenAPI operation
+// reachable through the route path, or returns it unchanged when the service
+// has no spec or no operation matches
+func withOperations(target requestTarget) []requestTarget {
+	operations := routeOperations(target.Service, target.Route, target.Pattern, target.Method)
+	if len(operations) == 0 {
+		return []requestTarget{target}
+	}
+
+	targets := make([]requestTarget, 0, len(operations))
+	for _, op := range operations {
+		t := target
+		t.Path = op.Path
+		t.Params = op.Params
+		t.Operation = op.Operation
+		targets = append(targets, t)
+	}
+	return targets
+}
+
 func hasAuthPlugin(route Route, service Service) bool {
+	return hasPlugin(route, service, "auth")
+}
+
+// hasPlugin reports whether a plugin is enabled on the route or its service
+func hasPlugin(route Route, service Service, name string) bool {
 	// Check route plugins
 	for _, plugin := range route.Plugins {
-		if plugin.Name == "auth" {
+		if plugin.Name == name {
 			return true
 		}
 	}
 
 	// Check service plugins
 	for _, plugin := range service.Plugins {
-		if plugin.Name == "auth" {
+		if plugin.Name == name {
 			return true
 		}
 	}
@@ -202,91 +511,233 @@ func hasAuthPlugin(route Route, service Service) bool {
 	return false
 }
 
+// Example values for named capture groups with well-known meanings
+var namedGroupSamples = map[string]string{
+	`(?<client_id>[0-9a-fA-F-]+)`:    "3a45625e-fd29-47a5-8294-e30fe2d3d391",
+	`(?<seat_id>[0-9a-fA-F-]+)`:      "123e4567-e89b-12d3-a456-426614174000",
+	`(?<invite_token>[0-9a-fA-F-]+)`: "987fcdeb-51a2-43e1-b210-0123456789ab",
+	`(?<test_id>[0-9a-fA-F-]+)`:      "test-id-123",
+	`(?<user_id>[^/]+)`:              "user123",
+	`(?<embed_id>[0-9a-fA-F-]+)`:     "embed-456",
+}
+
+// Example values for common regex fragments, applied in order
+var patternSamples = []struct {
+	pattern string
+	sample  string
+}{
+	{`[0-9a-fA-F-]+`, "abc123def456"},
+	{`[a-zA-Z0-9_-]+`, "test-value"},
+	{`[^/]+`, "example"},
+	{`(.*)`, "path"},
+}
+
 func expandRegexPath(path string) string {
-	// Convert regex patterns to example paths for testing
-	replacements := map[string]string{
-		`(?<client_id>[0-9a-fA-F-]+)`:    "3a45625e-fd29-47a5-8294-e30fe2d3d391",
-		`(?<seat_id>[0-9a-fA-F-]+)`:      "123e4567-e89b-12d3-a456-426614174000",
-		`(?<invite_token>[0-9a-fA-F-]+)`: "987fcdeb-51a2-43e1-b210-0123456789ab",
-		`(?<test_id>[0-9a-fA-F-]+)`:      "test-id-123",
-		`(?<user_id>[^/]+)`:              "user123",
-		`(?<embed_id>[0-9a-fA-F-]+)`:     "embed-456",
-		`[0-9a-fA-F-]+`:                  "abc123def456",
-		`[a-zA-Z0-9_-]+`:                 "test-value",
-		`[^/]+`:                          "example",
-		`(.*)`:                           "path",
+	expanded, _ := expandRegexPathParams(path)
+	return expanded
+}
+
+// expandRegexPathParams converts a Kong regex path to an example path for
+// testing, returning the value chosen for each named capture group
+func expandRegexPathParams(path string) (string, map[string]string) {
+	params := make(map[string]string)
+
+	var b strings.Builder
+	rest := path
+	for {
+		start := strings.Index(rest, "(?<")
+		if start < 0 {
+			break
+		}
+		end, name, inner := scanNamedGroup(rest[start:])
+		if end < 0 {
+			break
+		}
+
+		group := rest[start : start+end]
+		value, ok := namedGroupSamples[group]
+		if !ok {
+			value = sampleFragment(inner)
+		}
+		params[name] = value
+
+		b.WriteString(rest[:start])
+		b.WriteString(value)
+		rest = rest[start+end:]
 	}
+	b.WriteString(rest)
 
-	result := path
-	for pattern, replacement := range replacements {
-		result = strings.ReplaceAll(result, pattern, replacement)
+	result := b.String()
+	for _, p := range patternSamples {
+		result = strings.ReplaceAll(result, p.pattern, p.sample)
 	}
 
-	return result
+	return result, params
 }
 
-func testEndpoint(service, route, path, method string, requiresAuth bool) TestResult {
+// sampleFragment returns an example value for the pattern inside a capture group
+func sampleFragment(pattern string) string {
+	for _, p := range patternSamples {
+		if p.pattern == pattern {
+			return p.sample
+		}
+	}
+	return sampleRegex(pattern)
+}
+
+// scanNamedGroup parses the named capture group at the start of s, returning
+// its length, name and inner pattern, or -1 if the group is not terminated
+func scanNamedGroup(s string) (int, string, string) {
+	nameEnd := strings.Index(s, ">")
+	if nameEnd < 0 {
+		return -1, "", ""
+	}
+	name := s[3:nameEnd]
+
+	depth := 1
+	inClass := false
+	for i := nameEnd + 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			i++
+		case inClass:
+			if c == ']' {
+				inClass = false
+			}
+		case c == '[':
+			inClass = true
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return i + 1, name, s[nameEnd+1 : i]
+			}
+		}
+	}
+	return -1, "", ""
+}
+
+// testEndpoint sends a request under the retry policy. Once started, a request
+// is allowed to finish or time out even if ctx is cancelled, but no further
+// retries are attempted.
+func testEndpoint(ctx context.Context, target requestTarget, creds credentialVariant) TestResult {
 	result := TestResult{
-		Service:      service,
-		Route:        route,
-		Path:         path,
-		Method:       method,
-		RequiresAuth: requiresAuth,
+		Service:      target.Service.Name,
+		Route:        target.Route.Name,
+		Path:         target.Path,
+		Method:       target.Method,
+		RequiresAuth: target.RequiresAuth,
+		AuthMode:     creds.authMode,
+		CertMode:     creds.certMode,
+		Probe:        target.Probe,
+	}
+	if target.Operation != nil {
+		result.Operation = target.Operation.Name()
 	}
 
 	if *dryRun {
+		var query url.Values
+		if tmpl, err := buildRequestTemplate(target); err == nil {
+			query = tmpl.Query
+		}
+		result.UpstreamURL = upstreamURL(target, query)
 		result.Message = "DRY RUN"
 		printResult(result)
 		return result
 	}
 
-	url := *baseURL + path
+	for {
+		result = sendRequest(context.WithoutCancel(ctx), target, result, creds)
+		result.Attempts++
+		result.History = append(result.History, Attempt{
+			StatusCode: result.StatusCode,
+			Error:      result.Error,
+			Duration:   result.Timings.Total,
+		})
+
+		if result.Attempts >= *maxAttempts || !shouldRetry(result) {
+			break
+		}
+		if !sleepContext(ctx, retryBackoff(result.Attempts)) {
+			break
+		}
+	}
 
-	// Create request
-	var req *http.Request
-	var err error
+	printResult(result)
+	return result
+}
 
-	// Add sample body for POST/PUT requests
-	if method == "POST" || method == "PUT" || method == "PATCH" {
-		body := bytes.NewBuffer([]byte(`{"test": "data"}`))
-		req, err = http.NewRequest(method, url, body)
-		req.Header.Set("Content-Type", "application/json")
-	} else {
-		req, err = http.NewRequest(method, url, nil)
+// sendRequest makes a single attempt at a request, replacing the outcome of
+// any previous attempt recorded in the result
+func sendRequest(ctx context.Context, target requestTarget, result TestResult, creds credentialVariant) TestResult {
+	result.StatusCode = 0
+	result.Error = nil
+	result.Message = ""
+	result.ContractErrors = nil
+	result.UpstreamError = ""
+	result.ExpectedStatus = 0
+	result.ExpectationFailures = nil
+
+	// Build the body, headers and query parameters from fixtures
+	tmpl, err := buildRequestTemplate(target)
+	if err != nil {
+		result.Error = fmt.Errorf("rendering fixture: %w", err)
+		return result
+	}
+	if target.Probe {
+		neutralizeTemplate(tmpl)
 	}
 
+	result.UpstreamURL = upstreamURL(target, tmpl.Query)
+
+	req, err := newTargetRequest(ctx, target, tmpl, creds.authMode)
 	if err != nil {
 		result.Error = err
-		printResult(result)
 		return result
 	}
 
-	// Add auth header if required
-	if requiresAuth && *authToken != "" {
-		req.Header.Set("Authorization", "Bearer "+*authToken)
+	client, err := clientFor(result.Service, result.Route, creds.certMode)
+	if err != nil {
+		result.Error = err
+		return result
 	}
 
 	// Make request
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse // Don't follow redirects
-		},
-	}
-
+	req, timer := traceRequest(req)
 	resp, err := client.Do(req)
 	if err != nil {
+		result.Timings = timer.done()
 		result.Error = err
-		printResult(result)
+		result.CertRejected = creds.certMode != "" && handshakeRejected(err, timer.handshakeFailed())
 		return result
 	}
-	defer resp.Body.Close()
+	defer func() {
+		// Drain the body so the connection can be reused
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+		resp.Body.Close()
+	}()
 
 	result.StatusCode = resp.StatusCode
+	result.ProxyLatency, result.UpstreamLatency = kongLatencies(resp.Header)
+
+	// Read response body for error messages and contract checks
+	var respBody []byte
+	if resp.StatusCode >= 400 || target.Operation != nil || *assertUpstreamPath || len(expectedPlugins(target)) > 0 {
+		respBody, _ = io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	}
+
+	if target.Operation != nil && !expectsRejection(result) && !target.Probe {
+		result.ContractErrors = checkContract(target.Operation, resp.StatusCode, resp.Header.Get("Content-Type"), respBody)
+	}
+	result.ExpectedStatus, result.ExpectationFailures = checkPluginExpectations(target, result, resp, respBody)
+	if *assertUpstreamPath {
+		expected := kong.UpstreamPath(target.Service, target.Route, target.Pattern, target.Path)
+		result.UpstreamError = checkUpstreamPath(expected, resp.StatusCode, respBody)
+	}
 
-	// Read response body for error messages
 	if resp.StatusCode >= 400 {
-		body, _ := io.ReadAll(resp.Body)
+		body := respBody
 		if len(body) > 0 {
 			var errorResp map[string]interface{}
 			if err := json.Unmarshal(body, &errorResp); err == nil {
@@ -305,26 +756,109 @@ func testEndpoint(service, route, path, method string, requiresAuth bool) TestRe
 		}
 	}
 
-	printResult(result)
+	result.Timings = timer.done()
 	return result
 }
 
+// newTargetRequest builds the request for a target from its request template,
+// with credentials for the auth mode when the route requires them
+func newTargetRequest(ctx context.Context, target requestTarget, tmpl *requestTemplate, authMode string) (*http.Request, error) {
+	url := *baseURL + target.Path
+	if len(tmpl.Query) > 0 {
+		url += "?" + tmpl.Query.Encode()
+	}
+
+	var body io.Reader
+	if tmpl.Body != nil {
+		body = bytes.NewReader(tmpl.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, target.Method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if tmpl.ContentType != "" {
+		req.Header.Set("Content-Type", tmpl.ContentType)
+	}
+	for name, value := range tmpl.Headers {
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+
+	// Add auth header if required
+	if target.RequiresAuth {
+		token, err := bearerFor(target.Service.Name, target.Route.Name, authMode)
+		if err != nil {
+			return nil, fmt.Errorf("acquiring token: %w", err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+
+	preparePluginRequest(target, req)
+	return req, nil
+}
+
+// sleepContext waits for the duration, returning false if ctx is done first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// resultPassed reports whether a result met every expectation
+func resultPassed(result TestResult) bool {
+	violation, _ := authViolation(result)
+	if expectsRejection(result) {
+		// Rejections are the expected outcome when sending bad credentials
+		return (result.StatusCode != 0 || result.CertRejected) && violation == ""
+	}
+	if result.Probe {
+		// An invalid body is meant to be rejected, probes only need to be routed
+		return result.StatusCode != 0 && !noRouteMatched(result.StatusCode, []byte(result.Message))
+	}
+	answered := result.ExpectedStatus != 0 && result.StatusCode == result.ExpectedStatus
+	return (result.StatusCode >= 200 && result.StatusCode < 400 || answered) && violation == "" &&
+		len(result.ContractErrors) == 0 && result.UpstreamError == "" && len(result.ExpectationFailures) == 0
+}
+
 func printResult(result TestResult) {
-	if !*verbose && result.StatusCode >= 200 && result.StatusCode < 400 {
+	violation, _ := authViolation(result)
+	passed := resultPassed(result)
+
+	if !*verbose && passed {
 		return // Only show errors in non-verbose mode
 	}
 
 	status := "✓"
-	if result.StatusCode >= 400 || result.Error != nil {
+	if !passed && (result.Error != nil || result.StatusCode != 0) {
 		status = "✗"
-	} else if result.StatusCode == 0 {
+	} else if !passed {
 		status = "○"
 	}
 
 	authStr := ""
-	if result.RequiresAuth {
+	if result.RequiresAuth && result.AuthMode != "" {
+		authStr = " [AUTH:" + result.AuthMode + "]"
+	} else if result.RequiresAuth {
 		authStr = " [AUTH]"
 	}
+	if result.CertMode != "" {
+		authStr += " [CERT:" + result.CertMode + "]"
+	}
+	if result.Probe {
+		authStr += " [PROBE]"
+	}
 
 	fmt.Printf("%s %-30s %-40s %-6s %3d%s",
 		status,
@@ -334,13 +868,33 @@ func printResult(result TestResult) {
 		result.StatusCode,
 		authStr)
 
+	if *verbose && result.Timings.Total > 0 {
+		fmt.Printf(" (%s)", result.Timings)
+	}
+	if result.Attempts > 1 {
+		fmt.Printf(" [%d attempts]", result.Attempts)
+	}
+
 	if result.Error != nil {
 		fmt.Printf(" ERROR: %v", result.Error)
+	} else if violation != "" {
+		fmt.Printf(" - %s", violation)
+	} else if result.UpstreamError != "" {
+		fmt.Printf(" - %s", result.UpstreamError)
+	} else if len(result.ExpectationFailures) > 0 {
+		failure := result.ExpectationFailures[0]
+		fmt.Printf(" - %s: %s", failure.Plugin, truncate(failure.Message, 60))
+	} else if len(result.ContractErrors) > 0 {
+		fmt.Printf(" - contract: %s", truncate(result.ContractErrors[0], 60))
 	} else if result.Message != "" {
 		fmt.Printf(" - %s", truncate(result.Message, 50))
 	}
 
 	fmt.Println()
+
+	if (*dryRun || *verbose) && result.UpstreamURL != "" {
+		fmt.Printf("    → upstream %s\n", result.UpstreamURL)
+	}
 }
 
 func truncate(s string, length int) string {
@@ -397,4 +951,10 @@ func printSummary(results []TestResult) {
 			fmt.Printf("  - %s %s (%s)\n", result.Method, result.Path, result.Service)
 		}
 	}
+
+	printAuthMatrixSummary(results)
+	printLatencySummary(results)
+	printRetrySummary(results)
+	printUpstreamSummary(results)
+	printExpectationSummary(results)
 }
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestHandleTemplating(t *testing.T) {
@@ -417,4 +421,118 @@ func TestTruncate(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestTestRoutesStopsWhenCancelled(t *testing.T) {
+	*dryRun = true
+	defer func() { *dryRun = false }()
+
+	config := &KongConfig{
+		Services: []Service{
+			{
+				Name: "public-api",
+				Routes: []Route{
+					{Name: "public-endpoints", Paths: []string{"/api/v1/public/health", "/api/v1/public/status"}, Methods: []string{"GET"}},
+				},
+			},
+		},
+	}
+
+	results := testRoutes(context.Background(), config)
+	if len(results) != 2 {
+		t.Errorf("Expected 2 results, got %d", len(results))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results = testRoutes(ctx, config)
+	if len(results) != 0 {
+		t.Errorf("Expected no results after cancellation, got %d", len(results))
+	}
+}
+
+func TestTestEndpointFinishesInFlightRequest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Cancel the run while this request is in flight
+		cancel()
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	originalURL := *baseURL
+	*baseURL = server.URL
+	defer func() { *baseURL = originalURL }()
+
+	result := testEndpoint(ctx, publicHealthTarget, credentialVariant{})
+	if result.Error != nil || result.StatusCode != 200 {
+		t.Errorf("Expected in-flight request to complete, got status %d error %v", result.StatusCode, result.Error)
+	}
+}
+
+// publicHealthTarget is an unauthenticated GET used by tests that send requests
+var publicHealthTarget = requestTarget{
+	Service: Service{Name: "public-api"},
+	Route:   Route{Name: "public-endpoints"},
+	Pattern: "/api/v1/public/health",
+	Path:    "/api/v1/public/health",
+	Method:  "GET",
+}
+
+func TestExpandRegexPathParams(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		expectedPath   string
+		expectedParams map[string]string
+	}{
+		{
+			name:           "well-known capture group",
+			input:          "/auth/v1/seats/(?<seat_id>[0-9a-fA-F-]+)/activate",
+			expectedPath:   "/auth/v1/seats/123e4567-e89b-12d3-a456-426614174000/activate",
+			expectedParams: map[string]string{"seat_id": "123e4567-e89b-12d3-a456-426614174000"},
+		},
+		{
+			name:           "generic capture group",
+			input:          "/api/v1/callbacks/(?<provider>[a-zA-Z0-9_-]+)",
+			expectedPath:   "/api/v1/callbacks/test-value",
+			expectedParams: map[string]string{"provider": "test-value"},
+		},
+		{
+			name:           "capture group with quantifiers",
+			input:          "/api/v1/uuid/(?<id>[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})",
+			expectedPath:   "/api/v1/uuid/aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa",
+			expectedParams: map[string]string{"id": "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"},
+		},
+		{
+			name:         "multiple groups",
+			input:        "/clients/(?<client_id>[0-9a-fA-F-]+)/tasks/(?<task_id>[0-9a-fA-F-]+)",
+			expectedPath: "/clients/3a45625e-fd29-47a5-8294-e30fe2d3d391/tasks/abc123def456",
+			expectedParams: map[string]string{
+				"client_id": "3a45625e-fd29-47a5-8294-e30fe2d3d391",
+				"task_id":   "abc123def456",
+			},
+		},
+		{
+			name:           "unnamed patterns",
+			input:          "/media/v1/assets/(.*)",
+			expectedPath:   "/media/v1/assets/path",
+			expectedParams: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, params := expandRegexPathParams(tt.input)
+			if path != tt.expectedPath {
+				t.Errorf("expandRegexPathParams() path = %q, want %q", path, tt.expectedPath)
+			}
+			if !reflect.DeepEqual(params, tt.expectedParams) {
+				t.Errorf("expandRegexPathParams() params = %v, want %v", params, tt.expectedParams)
+			}
+		})
+	}
+}
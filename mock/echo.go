@@ -0,0 +1,39 @@
+package mock
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"unicode/utf8"
+)
+
+// maxEchoBody is the largest request body echoed back as text
+const maxEchoBody = 64 << 10
+
+// Echo describes the request an upstream received
+type Echo struct {
+	Service    string      `json:"service"`
+	Route      string      `json:"route,omitempty"`
+	Method     string      `json:"method"`
+	Path       string      `json:"path"`
+	Query      url.Values  `json:"query"`
+	Host       string      `json:"host"`
+	Headers    http.Header `json:"headers"`
+	Body       string      `json:"body,omitempty"`
+	BodySize   int         `json:"body_size"`
+	BodySHA256 string      `json:"body_sha256,omitempty"`
+}
+
+// setBody records the body's size and hash, and the body itself when it is
+// small enough to echo as text
+func (e *Echo) setBody(body []byte) {
+	e.BodySize = len(body)
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		e.BodySHA256 = hex.EncodeToString(sum[:])
+	}
+	if len(body) <= maxEchoBody && utf8.Valid(body) {
+		e.Body = string(body)
+	}
+}
@@ -0,0 +1,206 @@
+// Package mock provides a local stand-in for a Kong gateway and its upstream
+// services, so the route tester can be exercised without a real deployment.
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/danpilch/kong-route-tester/kong"
+)
+
+// Options configures the credentials the emulated plugins accept
+type Options struct {
+	// Tokens accepted by the auth plugin; when empty any bearer token that
+	// isn't an expired JWT is accepted
+	Tokens []string
+
+	// Keys accepted by the key-auth plugin; when empty any key is accepted
+	APIKeys []string
+
+	// Logger receives one line per request when set
+	Logger *log.Logger
+}
+
+// Gateway routes requests with Kong semantics, runs the supported plugins and
+// answers proxied requests with an echo of what the upstream would receive
+type Gateway struct {
+	config  *kong.Config
+	router  *kong.Router
+	options Options
+
+	mu       sync.Mutex
+	counters map[string]*rateWindow
+}
+
+// NewGateway builds a gateway for a Kong configuration
+func NewGateway(config *kong.Config, options Options) *Gateway {
+	return &Gateway{
+		config:   config,
+		router:   kong.NewRouter(config),
+		options:  options,
+		counters: make(map[string]*rateWindow),
+	}
+}
+
+// exchange is a request as it passes through the plugin chain
+type exchange struct {
+	w     http.ResponseWriter
+	r     *http.Request
+	match *kong.Match
+
+	// Request as the upstream will receive it, after plugin changes
+	method  string
+	header  http.Header
+	query   url.Values
+	body    []byte
+	headers http.Header // Headers plugins add to the response
+}
+
+// pluginHandler runs a plugin, returning true if it responded to the request
+type pluginHandler func(g *Gateway, x *exchange, config map[string]interface{}) bool
+
+// plugins lists the emulated plugins with their Kong execution priority
+var plugins = map[string]struct {
+	priority int
+	handle   pluginHandler
+}{
+	"cors":                {2000, (*Gateway).cors},
+	"key-auth":            {1250, (*Gateway).keyAuth},
+	"auth":                {1000, (*Gateway).auth},
+	"ip-restriction":      {990, (*Gateway).restrictIP},
+	"rate-limiting":       {910, (*Gateway).rateLimit},
+	"request-transformer": {801, (*Gateway).transformRequest},
+	"request-termination": {2, (*Gateway).terminate},
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	match, ok := g.router.Match(r.Method, r.Host, r.URL.Path)
+	if !ok {
+		if r.URL.Path == "/health" {
+			g.log(r, "health check")
+			writeJSON(w, http.StatusOK, map[string]string{"status": "healthy"})
+			return
+		}
+		g.log(r, "no route matched")
+		respond(w, nil, http.StatusNotFound, "no Route matched with those values")
+		return
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(r.Body, 10<<20))
+	x := &exchange{
+		w:       w,
+		r:       r,
+		match:   match,
+		method:  r.Method,
+		header:  r.Header.Clone(),
+		query:   r.URL.Query(),
+		body:    body,
+		headers: make(http.Header),
+	}
+
+	for _, plugin := range g.orderedPlugins(match) {
+		if plugins[plugin.Name].handle(g, x, plugin.Config) {
+			g.log(r, match.Route.Name+" answered by "+plugin.Name)
+			return
+		}
+	}
+
+	g.log(r, match.Route.Name+" proxied to "+match.Service.Name)
+	g.proxy(x)
+}
+
+// orderedPlugins returns the emulated plugins for a route in execution order
+func (g *Gateway) orderedPlugins(match *kong.Match) []kong.Plugin {
+	var enabled []kong.Plugin
+	for _, plugin := range g.config.EffectivePlugins(match.Service, match.Route) {
+		if _, ok := plugins[plugin.Name]; ok {
+			enabled = append(enabled, plugin)
+		}
+	}
+	sort.SliceStable(enabled, func(i, j int) bool {
+		return plugins[enabled[i].Name].priority > plugins[enabled[j].Name].priority
+	})
+	return enabled
+}
+
+// proxy answers in place of the upstream with what it would have received
+func (g *Gateway) proxy(x *exchange) {
+	upstream := Echo{
+		Service: x.match.Service.Name,
+		Route:   x.match.Route.Name,
+		Method:  x.method,
+		Path:    x.match.UpstreamPath,
+		Query:   x.query,
+		Host:    upstreamHost(x),
+		Headers: x.header,
+	}
+	upstream.setBody(x.body)
+
+	copyHeaders(x.w.Header(), x.headers)
+	x.w.Header().Set("X-Kong-Upstream-Latency", "0")
+	x.w.Header().Set("X-Kong-Proxy-Latency", "0")
+	writeJSON(x.w, http.StatusOK, upstream)
+}
+
+// upstreamHost is the Host header Kong sends upstream
+func upstreamHost(x *exchange) string {
+	if x.match.Route.PreserveHost {
+		return x.r.Host
+	}
+	if u, err := url.Parse(kong.ServiceOrigin(x.match.Service)); err == nil {
+		return u.Host
+	}
+	return ""
+}
+
+// respond sends a response generated by Kong itself
+func respond(w http.ResponseWriter, headers http.Header, status int, message string) {
+	copyHeaders(w.Header(), headers)
+	w.Header().Set("X-Kong-Response-Latency", "0")
+	writeJSON(w, status, map[string]string{"message": message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	var b bytes.Buffer
+	json.NewEncoder(&b).Encode(value)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(b.Bytes())
+}
+
+func copyHeaders(dst, src http.Header) {
+	for name, values := range src {
+		for _, value := range values {
+			dst.Add(name, value)
+		}
+	}
+}
+
+// clientIP returns the caller's address, trusting X-Forwarded-For so tests
+// can vary it
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		first, _, _ := strings.Cut(forwarded, ",")
+		return strings.TrimSpace(first)
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+func (g *Gateway) log(r *http.Request, outcome string) {
+	if g.options.Logger != nil {
+		g.options.Logger.Printf("%s %s%s -> %s", r.Method, r.Host, r.URL.RequestURI(), outcome)
+	}
+}
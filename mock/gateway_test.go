@@ -0,0 +1,254 @@
+package mock
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/danpilch/kong-route-tester/kong"
+)
+
+func testGateway(options Options) *Gateway {
+	config := &kong.Config{Services: []kong.Service{
+		{
+			Name: "users",
+			URL:  "http://users:8001/internal",
+			Routes: []kong.Route{
+				{Name: "users", Paths: []string{"/api/v1/users"}, Plugins: []kong.Plugin{{Name: "auth"}}},
+				{Name: "basic", Paths: []string{"/api/v1/basic"}, Plugins: []kong.Plugin{{Name: "auth", Config: map[string]interface{}{"enable_basic_auth": true}}}},
+				{Name: "keys", Paths: []string{"/api/v1/keys"}, Plugins: []kong.Plugin{{Name: "key-auth", Config: map[string]interface{}{"hide_credentials": true}}}},
+			},
+		},
+		{
+			Name: "public",
+			URL:  "http://public:8002",
+			Plugins: []kong.Plugin{
+				{Name: "cors", Config: map[string]interface{}{"origins": []interface{}{"https://app.example.com", `https://.*\.example\.org`}, "max_age": 600}},
+			},
+			Routes: []kong.Route{
+				{Name: "public", Paths: []string{"/api/v1/public"}},
+				{Name: "limited", Paths: []string{"/api/v1/limited"}, Plugins: []kong.Plugin{{Name: "rate-limiting", Config: map[string]interface{}{"minute": 2, "limit_by": "ip"}}}},
+				{Name: "internal", Paths: []string{"/api/v1/internal"}, Plugins: []kong.Plugin{{Name: "ip-restriction", Config: map[string]interface{}{"allow": []interface{}{"10.0.0.0/8"}, "deny": []interface{}{"10.0.0.9"}}}}},
+				{Name: "maintenance", Paths: []string{"/api/v1/maintenance"}, Plugins: []kong.Plugin{{Name: "request-termination", Config: map[string]interface{}{"status_code": 503, "message": "Down for maintenance"}}}},
+				{Name: "transformed", Paths: []string{"/api/v1/transformed"}, Plugins: []kong.Plugin{{Name: "request-transformer", Config: map[string]interface{}{
+					"remove":  map[string]interface{}{"headers": []interface{}{"X-Debug"}},
+					"rename":  map[string]interface{}{"querystring": []interface{}{"q:search"}},
+					"add":     map[string]interface{}{"headers": []interface{}{"X-Source:gateway"}, "body": []interface{}{"source:gateway"}},
+					"replace": map[string]interface{}{"body": []interface{}{"name:replaced"}},
+				}}}},
+			},
+		},
+	}}
+	return NewGateway(config, options)
+}
+
+func serve(h http.Handler, r *http.Request) (*httptest.ResponseRecorder, Echo) {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	var echo Echo
+	json.Unmarshal(w.Body.Bytes(), &echo)
+	return w, echo
+}
+
+func TestGatewayRouting(t *testing.T) {
+	g := testGateway(Options{})
+
+	w, echo := serve(g, httptest.NewRequest("GET", "/api/v1/public/status?verbose=1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if echo.Service != "public" || echo.Route != "public" || echo.Path != "/status" || echo.Query.Get("verbose") != "1" {
+		t.Errorf("unexpected echo %+v", echo)
+	}
+	if echo.Host != "public:8002" {
+		t.Errorf("expected the service host upstream, got %s", echo.Host)
+	}
+
+	w, _ = serve(g, httptest.NewRequest("GET", "/unknown", nil))
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "no Route matched") {
+		t.Errorf("expected Kong's 404, got %d %s", w.Code, w.Body)
+	}
+
+	w, _ = serve(g, httptest.NewRequest("GET", "/health", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected health check to succeed, got %d", w.Code)
+	}
+}
+
+func TestGatewayAuth(t *testing.T) {
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:secret"))
+	expired := "e30." + base64.RawURLEncoding.EncodeToString([]byte(`{"exp":1}`)) + ".sig"
+
+	tests := []struct {
+		name          string
+		options       Options
+		path          string
+		authorization string
+		status        int
+	}{
+		{name: "missing token", path: "/api/v1/users", status: 401},
+		{name: "any token", path: "/api/v1/users", authorization: "Bearer anything", status: 200},
+		{name: "expired jwt", path: "/api/v1/users", authorization: "Bearer " + expired, status: 401},
+		{name: "configured token", options: Options{Tokens: []string{"good"}}, path: "/api/v1/users", authorization: "Bearer good", status: 200},
+		{name: "unknown token", options: Options{Tokens: []string{"good"}}, path: "/api/v1/users", authorization: "Bearer bad", status: 401},
+		{name: "basic disabled", path: "/api/v1/users", authorization: basic, status: 401},
+		{name: "basic enabled", path: "/api/v1/basic", authorization: basic, status: 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.path, nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w, _ := serve(testGateway(tt.options), r)
+			if w.Code != tt.status {
+				t.Errorf("expected %d, got %d", tt.status, w.Code)
+			}
+			if w.Code == 401 && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected a WWW-Authenticate challenge")
+			}
+		})
+	}
+}
+
+func TestGatewayKeyAuth(t *testing.T) {
+	g := testGateway(Options{APIKeys: []string{"k1"}})
+
+	w, _ := serve(g, httptest.NewRequest("GET", "/api/v1/keys", nil))
+	if w.Code != 401 || !strings.Contains(w.Body.String(), "No API key found") {
+		t.Errorf("expected missing key rejection, got %d %s", w.Code, w.Body)
+	}
+
+	w, _ = serve(g, httptest.NewRequest("GET", "/api/v1/keys?apikey=k2", nil))
+	if w.Code != 401 || !strings.Contains(w.Body.String(), "Invalid authentication credentials") {
+		t.Errorf("expected invalid key rejection, got %d %s", w.Code, w.Body)
+	}
+
+	w, echo := serve(g, httptest.NewRequest("GET", "/api/v1/keys?apikey=k1", nil))
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if echo.Query.Has("apikey") {
+		t.Error("expected hide_credentials to strip the key before proxying")
+	}
+}
+
+func TestGatewayRateLimiting(t *testing.T) {
+	g := testGateway(Options{})
+
+	var statuses []int
+	for range 3 {
+		r := httptest.NewRequest("GET", "/api/v1/limited", nil)
+		r.Header.Set("X-Forwarded-For", "10.0.0.1")
+		w, _ := serve(g, r)
+		statuses = append(statuses, w.Code)
+
+		if w.Code == 429 {
+			if w.Header().Get("Retry-After") == "" || w.Header().Get("X-RateLimit-Remaining-Minute") != "0" {
+				t.Errorf("expected rate limit headers, got %v", w.Header())
+			}
+		} else if w.Header().Get("X-RateLimit-Limit-Minute") != "2" {
+			t.Errorf("expected X-RateLimit-Limit-Minute 2, got %q", w.Header().Get("X-RateLimit-Limit-Minute"))
+		}
+	}
+	if statuses[0] != 200 || statuses[1] != 200 || statuses[2] != 429 {
+		t.Errorf("expected 200 200 429, got %v", statuses)
+	}
+
+	// Another client has its own window
+	r := httptest.NewRequest("GET", "/api/v1/limited", nil)
+	r.Header.Set("X-Forwarded-For", "10.0.0.2")
+	if w, _ := serve(g, r); w.Code != 200 {
+		t.Errorf("expected a separate limit per IP, got %d", w.Code)
+	}
+}
+
+func TestGatewayCORS(t *testing.T) {
+	g := testGateway(Options{})
+
+	tests := []struct {
+		name   string
+		origin string
+		allow  string
+	}{
+		{name: "exact origin", origin: "https://app.example.com", allow: "https://app.example.com"},
+		{name: "regex origin", origin: "https://eu.example.org", allow: "https://eu.example.org"},
+		{name: "disallowed origin", origin: "https://evil.test", allow: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("OPTIONS", "/api/v1/public", nil)
+			r.Header.Set("Origin", tt.origin)
+			r.Header.Set("Access-Control-Request-Method", "POST")
+			r.Header.Set("Access-Control-Request-Headers", "Content-Type")
+			w, _ := serve(g, r)
+
+			if w.Code != 200 {
+				t.Fatalf("expected preflight 200, got %d", w.Code)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.allow {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.allow)
+			}
+			if w.Header().Get("Access-Control-Max-Age") != "600" || w.Header().Get("Access-Control-Allow-Headers") != "Content-Type" {
+				t.Errorf("unexpected preflight headers %v", w.Header())
+			}
+		})
+	}
+
+	r := httptest.NewRequest("GET", "/api/v1/public", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	w, echo := serve(g, r)
+	if echo.Service != "public" || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Errorf("expected a proxied response with CORS headers, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestGatewayIPRestriction(t *testing.T) {
+	g := testGateway(Options{})
+
+	for ip, status := range map[string]int{"10.1.2.3": 200, "10.0.0.9": 403, "192.168.1.1": 403} {
+		r := httptest.NewRequest("GET", "/api/v1/internal", nil)
+		r.Header.Set("X-Forwarded-For", ip)
+		if w, _ := serve(g, r); w.Code != status {
+			t.Errorf("%s: expected %d, got %d", ip, status, w.Code)
+		}
+	}
+}
+
+func TestGatewayTermination(t *testing.T) {
+	w, _ := serve(testGateway(Options{}), httptest.NewRequest("GET", "/api/v1/maintenance", nil))
+	if w.Code != 503 || !strings.Contains(w.Body.String(), "Down for maintenance") {
+		t.Errorf("expected terminated response, got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestGatewayRequestTransformer(t *testing.T) {
+	r := httptest.NewRequest("POST", "/api/v1/transformed?q=kong", strings.NewReader(`{"name":"original"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-Debug", "1")
+	w, echo := serve(testGateway(Options{}), r)
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	if echo.Headers.Get("X-Debug") != "" || echo.Headers.Get("X-Source") != "gateway" {
+		t.Errorf("unexpected upstream headers %v", echo.Headers)
+	}
+	if echo.Query.Get("search") != "kong" || echo.Query.Has("q") {
+		t.Errorf("expected q renamed to search, got %v", echo.Query)
+	}
+
+	var body map[string]string
+	if err := json.Unmarshal([]byte(echo.Body), &body); err != nil {
+		t.Fatalf("upstream body is not JSON: %v", err)
+	}
+	if body["name"] != "replaced" || body["source"] != "gateway" {
+		t.Errorf("unexpected upstream body %v", body)
+	}
+}
@@ -0,0 +1,370 @@
+package mock
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danpilch/kong-route-tester/kong"
+)
+
+// cors answers preflight requests and adds CORS headers to other responses
+func (g *Gateway) cors(x *exchange, config map[string]interface{}) bool {
+	origin := x.r.Header.Get("Origin")
+	preflight := x.r.Method == http.MethodOptions && x.r.Header.Get("Access-Control-Request-Method") != ""
+
+	if allowed, ok := kong.AllowedOrigin(config, origin); ok {
+		x.headers.Set("Access-Control-Allow-Origin", allowed)
+		if allowed != "*" {
+			x.headers.Add("Vary", "Origin")
+		}
+		if kong.BoolValue(config, "credentials", false) {
+			x.headers.Set("Access-Control-Allow-Credentials", "true")
+		}
+	}
+
+	if !preflight {
+		if exposed := kong.StringList(config, "exposed_headers"); len(exposed) > 0 {
+			x.headers.Set("Access-Control-Expose-Headers", strings.Join(exposed, ","))
+		}
+		return false
+	}
+	if kong.BoolValue(config, "preflight_continue", false) {
+		return false
+	}
+
+	methods := kong.StringList(config, "methods")
+	if len(methods) == 0 {
+		methods = []string{"GET", "HEAD", "PUT", "PATCH", "POST", "DELETE", "OPTIONS", "TRACE", "CONNECT"}
+	}
+	x.headers.Set("Access-Control-Allow-Methods", strings.Join(methods, ","))
+
+	if headers := kong.StringList(config, "headers"); len(headers) > 0 {
+		x.headers.Set("Access-Control-Allow-Headers", strings.Join(headers, ","))
+	} else if requested := x.r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+		x.headers.Set("Access-Control-Allow-Headers", requested)
+	}
+	if maxAge, ok := kong.NumberValue(config, "max_age"); ok {
+		x.headers.Set("Access-Control-Max-Age", strconv.Itoa(maxAge))
+	}
+
+	copyHeaders(x.w.Header(), x.headers)
+	x.w.Header().Set("X-Kong-Response-Latency", "0")
+	x.w.WriteHeader(http.StatusOK)
+	return true
+}
+
+// keyAuth requires an API key in a header or query parameter
+func (g *Gateway) keyAuth(x *exchange, config map[string]interface{}) bool {
+	names := kong.StringList(config, "key_names")
+	if len(names) == 0 {
+		names = []string{"apikey"}
+	}
+
+	key := ""
+	for _, name := range names {
+		if kong.BoolValue(config, "key_in_header", true) && x.header.Get(name) != "" {
+			key = x.header.Get(name)
+			if kong.BoolValue(config, "hide_credentials", false) {
+				x.header.Del(name)
+			}
+			break
+		}
+		if kong.BoolValue(config, "key_in_query", true) && x.query.Get(name) != "" {
+			key = x.query.Get(name)
+			if kong.BoolValue(config, "hide_credentials", false) {
+				x.query.Del(name)
+			}
+			break
+		}
+	}
+
+	switch {
+	case key == "":
+		x.w.Header().Set("WWW-Authenticate", `Key realm="kong"`)
+		respond(x.w, x.headers, http.StatusUnauthorized, "No API key found in request")
+		return true
+	case len(g.options.APIKeys) > 0 && !slices.Contains(g.options.APIKeys, key):
+		respond(x.w, x.headers, http.StatusUnauthorized, "Invalid authentication credentials")
+		return true
+	}
+	return false
+}
+
+// auth emulates the custom bearer token plugin, optionally accepting basic
+// credentials when enable_basic_auth is set
+func (g *Gateway) auth(x *exchange, config map[string]interface{}) bool {
+	scheme, credentials, _ := strings.Cut(x.r.Header.Get("Authorization"), " ")
+
+	valid := false
+	switch {
+	case strings.EqualFold(scheme, "Bearer") && credentials != "":
+		valid = g.validToken(credentials)
+	case strings.EqualFold(scheme, "Basic") && kong.BoolValue(config, "enable_basic_auth", false):
+		if decoded, err := base64.StdEncoding.DecodeString(credentials); err == nil {
+			_, password, ok := strings.Cut(string(decoded), ":")
+			valid = ok && (len(g.options.Tokens) == 0 || slices.Contains(g.options.Tokens, password))
+		}
+	}
+
+	if !valid {
+		x.w.Header().Set("WWW-Authenticate", "Bearer")
+		respond(x.w, x.headers, http.StatusUnauthorized, "Unauthorized")
+		return true
+	}
+	return false
+}
+
+func (g *Gateway) validToken(token string) bool {
+	if len(g.options.Tokens) > 0 {
+		return slices.Contains(g.options.Tokens, token)
+	}
+	return !kong.JWTExpired(token)
+}
+
+// restrictIP rejects client addresses on the deny list, or missing from the
+// allow list when one is set
+func (g *Gateway) restrictIP(x *exchange, config map[string]interface{}) bool {
+	ip, err := netip.ParseAddr(clientIP(x.r))
+	if err != nil {
+		return false
+	}
+
+	if !kong.IPDenied(config, ip) {
+		return false
+	}
+
+	status := kong.IntValue(config, "status", http.StatusForbidden)
+	respond(x.w, x.headers, status, kong.StringValue(config, "message", "Your IP address is not allowed"))
+	return true
+}
+
+// rateWindow counts requests in a fixed window
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// rateLimit counts requests per identity in fixed windows aligned to the
+// period, like Kong's local policy
+func (g *Gateway) rateLimit(x *exchange, config map[string]interface{}) bool {
+	identity := rateIdentity(x, config)
+	now := time.Now()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	exceeded := false
+	stopRemaining, stopLimit := -1, 0
+	var stopReset time.Duration
+	type usage struct {
+		window *rateWindow
+		limit  int
+		header string
+	}
+	var usages []usage
+
+	for _, period := range kong.RatePeriods {
+		limit, ok := kong.NumberValue(config, period.Name)
+		if !ok || limit <= 0 {
+			continue
+		}
+
+		start := now.Truncate(period.Length)
+		key := x.match.Service.Name + "|" + x.match.Route.Name + "|" + identity + "|" + period.Name
+		window := g.counters[key]
+		if window == nil || !window.start.Equal(start) {
+			window = &rateWindow{start: start}
+			g.counters[key] = window
+		}
+		usages = append(usages, usage{window, limit, period.Header})
+
+		remaining := limit - window.count
+		if remaining <= 0 {
+			exceeded = true
+		}
+		if stopRemaining < 0 || remaining < stopRemaining {
+			stopRemaining, stopLimit = remaining, limit
+			stopReset = start.Add(period.Length).Sub(now)
+		}
+	}
+	if len(usages) == 0 {
+		return false
+	}
+
+	if !exceeded {
+		for _, u := range usages {
+			u.window.count++
+		}
+		stopRemaining--
+	}
+
+	if !kong.BoolValue(config, "hide_client_headers", false) {
+		for _, u := range usages {
+			x.headers.Set("X-RateLimit-Limit-"+u.header, strconv.Itoa(u.limit))
+			x.headers.Set("X-RateLimit-Remaining-"+u.header, strconv.Itoa(max(u.limit-u.window.count, 0)))
+		}
+		x.headers.Set("RateLimit-Limit", strconv.Itoa(stopLimit))
+		x.headers.Set("RateLimit-Remaining", strconv.Itoa(max(stopRemaining, 0)))
+		x.headers.Set("RateLimit-Reset", strconv.Itoa(int(stopReset.Seconds()+0.999)))
+	}
+
+	if exceeded {
+		x.headers.Set("Retry-After", strconv.Itoa(int(stopReset.Seconds()+0.999)))
+		respond(x.w, x.headers, http.StatusTooManyRequests, "API rate limit exceeded")
+		return true
+	}
+	return false
+}
+
+// rateIdentity returns who a request is counted against under limit_by.
+// The mock has no consumers, so consumer and credential fall back to the
+// client IP like Kong does for anonymous requests.
+func rateIdentity(x *exchange, config map[string]interface{}) string {
+	switch kong.StringValue(config, "limit_by", "consumer") {
+	case "service":
+		return "service"
+	case "header":
+		return "header:" + x.r.Header.Get(kong.StringValue(config, "header_name", ""))
+	case "path":
+		return "path:" + x.r.URL.Path
+	}
+	return "ip:" + clientIP(x.r)
+}
+
+// transformRequest applies request-transformer changes in Kong's order:
+// remove, rename, replace, add, append
+func (g *Gateway) transformRequest(x *exchange, config map[string]interface{}) bool {
+	if method := kong.StringValue(config, "http_method", ""); method != "" {
+		x.method = strings.ToUpper(method)
+	}
+
+	headers := headerValues{x.header}
+	query := queryValues{x.query}
+	body, isJSON := jsonBody(x)
+
+	for _, operation := range []string{"remove", "rename", "replace", "add", "append"} {
+		ops := kong.Section(config, operation)
+		if ops == nil {
+			continue
+		}
+		applyTransform(headers, operation, kong.StringList(ops, "headers"))
+		applyTransform(query, operation, kong.StringList(ops, "querystring"))
+		if isJSON {
+			applyTransform(body, operation, kong.StringList(ops, "body"))
+		}
+	}
+
+	if isJSON {
+		x.body, _ = json.Marshal(body.values)
+		x.header.Set("Content-Length", strconv.Itoa(len(x.body)))
+	}
+	return false
+}
+
+// transformTarget is a set of name/value pairs a transformation edits
+type transformTarget interface {
+	has(name string) bool
+	get(name string) string
+	set(name, value string)
+	add(name, value string)
+	del(name string)
+}
+
+func applyTransform(target transformTarget, operation string, entries []string) {
+	for _, entry := range entries {
+		name, value, _ := strings.Cut(entry, ":")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+
+		switch operation {
+		case "remove":
+			target.del(name)
+		case "rename":
+			if target.has(name) {
+				current := target.get(name)
+				target.del(name)
+				target.set(value, current)
+			}
+		case "replace":
+			if target.has(name) {
+				target.set(name, value)
+			}
+		case "add":
+			if !target.has(name) {
+				target.set(name, value)
+			}
+		case "append":
+			target.add(name, value)
+		}
+	}
+}
+
+type headerValues struct{ http.Header }
+
+func (h headerValues) has(name string) bool   { return h.Header.Get(name) != "" }
+func (h headerValues) get(name string) string { return h.Header.Get(name) }
+func (h headerValues) set(name, value string) { h.Header.Set(name, value) }
+func (h headerValues) add(name, value string) { h.Header.Add(name, value) }
+func (h headerValues) del(name string)        { h.Header.Del(name) }
+
+type queryValues struct{ url.Values }
+
+func (q queryValues) has(name string) bool   { return q.Values.Has(name) }
+func (q queryValues) get(name string) string { return q.Values.Get(name) }
+func (q queryValues) set(name, value string) { q.Values.Set(name, value) }
+func (q queryValues) add(name, value string) { q.Values.Add(name, value) }
+func (q queryValues) del(name string)        { q.Values.Del(name) }
+
+type bodyValues struct{ values map[string]interface{} }
+
+func (b bodyValues) has(name string) bool   { _, ok := b.values[name]; return ok }
+func (b bodyValues) get(name string) string { return fmt.Sprint(b.values[name]) }
+func (b bodyValues) set(name, value string) { b.values[name] = value }
+func (b bodyValues) del(name string)        { delete(b.values, name) }
+
+func (b bodyValues) add(name, value string) {
+	switch current := b.values[name].(type) {
+	case nil:
+		b.values[name] = value
+	case []interface{}:
+		b.values[name] = append(current, value)
+	default:
+		b.values[name] = []interface{}{current, value}
+	}
+}
+
+// jsonBody decodes a JSON object request body for transformation
+func jsonBody(x *exchange) (bodyValues, bool) {
+	if !strings.Contains(x.header.Get("Content-Type"), "json") {
+		return bodyValues{}, false
+	}
+	values := make(map[string]interface{})
+	if len(x.body) > 0 && json.Unmarshal(x.body, &values) != nil {
+		return bodyValues{}, false
+	}
+	return bodyValues{values}, true
+}
+
+// terminate answers with the configured status and message or body
+func (g *Gateway) terminate(x *exchange, config map[string]interface{}) bool {
+	status := kong.IntValue(config, "status_code", http.StatusServiceUnavailable)
+
+	if body, ok := config["body"]; ok && body != nil {
+		copyHeaders(x.w.Header(), x.headers)
+		x.w.Header().Set("Content-Type", kong.StringValue(config, "content_type", "application/json; charset=utf-8"))
+		x.w.Header().Set("X-Kong-Response-Latency", "0")
+		x.w.WriteHeader(status)
+		fmt.Fprint(x.w, body)
+		return true
+	}
+
+	respond(x.w, x.headers, status, kong.StringValue(config, "message", http.StatusText(status)))
+	return true
+}
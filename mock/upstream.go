@@ -0,0 +1,101 @@
+package mock
+
+import (
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/danpilch/kong-route-tester/kong"
+)
+
+// Upstream stands in for one or more services listening on the same port.
+// It answers every request with an Echo of what it received, so the effect of
+// a real Kong's routing and plugins can be checked from the client side.
+type Upstream struct {
+	services []kong.Service
+	logger   *log.Logger
+}
+
+// NewUpstream returns an echo upstream for services sharing a listener
+func NewUpstream(services []kong.Service, logger *log.Logger) *Upstream {
+	return &Upstream{services: services, logger: logger}
+}
+
+func (u *Upstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(io.LimitReader(r.Body, 10<<20))
+	echo := Echo{
+		Service: u.service(r.Host),
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Query:   r.URL.Query(),
+		Host:    r.Host,
+		Headers: r.Header,
+	}
+	echo.setBody(body)
+
+	if u.logger != nil {
+		u.logger.Printf("%s %s%s -> %s", r.Method, r.Host, r.URL.RequestURI(), echo.Service)
+	}
+	writeJSON(w, http.StatusOK, echo)
+}
+
+// service names the service a request was meant for. Services sharing a port
+// are told apart by the Host header Kong sends, which is the service host
+// unless the route preserves the client's host.
+func (u *Upstream) service(host string) string {
+	if len(u.services) == 1 {
+		return u.services[0].Name
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	var names []string
+	for _, service := range u.services {
+		if serviceHost, _, ok := ServiceAddress(service); ok && strings.EqualFold(serviceHost, host) {
+			names = append(names, service.Name)
+		}
+	}
+	if len(names) == 0 {
+		for _, service := range u.services {
+			names = append(names, service.Name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// ServiceAddress returns the host and port a service's upstream listens on.
+// Only plain HTTP services can be served by an echo upstream.
+func ServiceAddress(service kong.Service) (string, int, bool) {
+	u, err := url.Parse(kong.ServiceOrigin(service))
+	if err != nil || u.Scheme != "http" || u.Hostname() == "" {
+		return "", 0, false
+	}
+
+	port := 80
+	if p := u.Port(); p != "" {
+		if port, err = strconv.Atoi(p); err != nil {
+			return "", 0, false
+		}
+	}
+	return u.Hostname(), port, true
+}
+
+// UpstreamPorts groups services by the port their upstream listens on, and
+// returns the services that can't be served over plain HTTP
+func UpstreamPorts(services []kong.Service) (map[int][]kong.Service, []kong.Service) {
+	ports := make(map[int][]kong.Service)
+	var skipped []kong.Service
+	for _, service := range services {
+		if _, port, ok := ServiceAddress(service); ok {
+			ports[port] = append(ports[port], service)
+		} else {
+			skipped = append(skipped, service)
+		}
+	}
+	return ports, skipped
+}
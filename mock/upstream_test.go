@@ -0,0 +1,66 @@
+package mock
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/danpilch/kong-route-tester/kong"
+)
+
+func TestUpstreamPorts(t *testing.T) {
+	services := []kong.Service{
+		{Name: "users", URL: "http://users:8001/api"},
+		{Name: "orders", URL: "http://orders:8001"},
+		{Name: "media", Host: "media", Port: 8002},
+		{Name: "legacy", URL: "http://legacy"},
+		{Name: "secure", URL: "https://secure:8443"},
+	}
+
+	ports, skipped := UpstreamPorts(services)
+	if len(ports[8001]) != 2 || len(ports[8002]) != 1 || len(ports[80]) != 1 {
+		t.Errorf("unexpected ports %v", ports)
+	}
+	if len(skipped) != 1 || skipped[0].Name != "secure" {
+		t.Errorf("expected the https service to be skipped, got %v", skipped)
+	}
+}
+
+func TestUpstreamEcho(t *testing.T) {
+	upstream := NewUpstream([]kong.Service{
+		{Name: "users", URL: "http://users:8001"},
+		{Name: "orders", URL: "http://orders:8001"},
+	}, nil)
+
+	tests := []struct {
+		name    string
+		host    string
+		service string
+	}{
+		{name: "service host", host: "orders:8001", service: "orders"},
+		{name: "preserved client host", host: "api.example.com", service: "users,orders"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/v1/orders?page=2", strings.NewReader(`{"id":1}`))
+			r.Host = tt.host
+			r.Header.Set("X-Added", "yes")
+			w, echo := serve(upstream, r)
+
+			if w.Code != 200 {
+				t.Fatalf("expected 200, got %d", w.Code)
+			}
+			if echo.Service != tt.service {
+				t.Errorf("Service = %q, want %q", echo.Service, tt.service)
+			}
+			sum := sha256.Sum256([]byte(`{"id":1}`))
+			if echo.Method != "POST" || echo.Path != "/v1/orders" || echo.Query.Get("page") != "2" ||
+				echo.Headers.Get("X-Added") != "yes" || echo.BodySHA256 != hex.EncodeToString(sum[:]) {
+				t.Errorf("unexpected echo %+v", echo)
+			}
+		})
+	}
+}
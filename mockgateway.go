@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/danpilch/kong-route-tester/mock"
+	"github.com/spf13/pflag"
+)
+
+// runMockGateway serves a Kong configuration locally with the mock gateway
+func runMockGateway(args []string) int {
+	flags := pflag.NewFlagSet("mock-gateway", pflag.ContinueOnError)
+	file := flags.String("file", "kong.yaml", "Path to Kong configuration file")
+	port := flags.Int("port", 8080, "Port to listen on")
+	tokens := flags.StringSlice("auth-token", nil, "Bearer token accepted by the auth plugin (repeatable; default accepts any unexpired token)")
+	apiKeys := flags.StringSlice("api-key", nil, "Key accepted by the key-auth plugin (repeatable; default accepts any key)")
+	logRequests := flags.Bool("verbose", false, "Log every request")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	config, err := readKongConfig(*file)
+	if err != nil {
+		fmt.Printf("Error reading Kong configuration: %v\n", err)
+		return 1
+	}
+
+	options := mock.Options{Tokens: *tokens, APIKeys: *apiKeys}
+	if *logRequests {
+		options.Logger = log.New(os.Stdout, "", log.LstdFlags)
+	}
+
+	server := &http.Server{
+		Addr:              fmt.Sprintf("127.0.0.1:%d", *port),
+		Handler:           mock.NewGateway(config, options),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+
+	fmt.Printf("Mock Kong gateway listening on http://%s (%d services)\n", server.Addr, len(config.Services))
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("Error running mock gateway: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// TLS and client certificate flags
+var (
+	clientCert         = pflag.String("client-cert", "", "PEM client certificate presented to mtls-auth routes")
+	clientKey          = pflag.String("client-key", "", "PEM private key for --client-cert")
+	clientCertMap      = pflag.StringToString("client-cert-map", nil, "Client certificate per service or route name (name=cert.pem)")
+	clientKeyMap       = pflag.StringToString("client-key-map", nil, "Client key per service or route name (name=key.pem)")
+	caCert             = pflag.String("ca-cert", "", "PEM CA bundle trusted in addition to the system roots")
+	serverName         = pflag.String("server-name", "", "Override the TLS server name used for SNI and verification")
+	insecureSkipVerify = pflag.Bool("insecure-skip-verify", false, "Skip TLS certificate verification (unsafe)")
+)
+
+// Client certificate modes used when testing mtls-auth routes. An empty mode
+// presents the configured certificate when there is one.
+const (
+	certNone      = "none"
+	certUntrusted = "untrusted"
+)
+
+// certModes returns the negative client certificate modes to exercise for a
+// route, which only apply to mtls-auth routes when the auth matrix is enabled
+func certModes(route Route, service Service) []string {
+	if !*authMatrix || !hasPlugin(route, service, "mtls-auth") {
+		return nil
+	}
+	return []string{certNone, certUntrusted}
+}
+
+// handshakeRejected reports whether a request error is the gateway refusing a
+// client certificate: a TLS alert from the gateway, or the connection being
+// dropped while the handshake was in progress. Failing to verify the gateway's
+// own certificate is not a rejection, nor is any error before the handshake.
+func handshakeRejected(err error, duringHandshake bool) bool {
+	var alert tls.AlertError
+	if errors.As(err, &alert) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "remote error" {
+		// crypto/tls reports alerts such as "certificate required" this way
+		return true
+	}
+	return duringHandshake && (errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF))
+}
+
+var (
+	caPoolOnce sync.Once
+	caPool     *x509.CertPool
+	caPoolErr  error
+
+	keyPairMu sync.Mutex
+	keyPairs  = make(map[string]tls.Certificate)
+
+	untrustedOnce sync.Once
+	untrustedCert tls.Certificate
+	untrustedErr  error
+)
+
+// tlsConfigFor builds the TLS client configuration for a request to a route,
+// presenting the route's client certificate according to the cert mode
+func tlsConfigFor(service, route, certMode string) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         *serverName,
+		InsecureSkipVerify: *insecureSkipVerify,
+	}
+
+	if *caCert != "" {
+		caPoolOnce.Do(func() { caPool, caPoolErr = loadCAPool(*caCert) })
+		if caPoolErr != nil {
+			return nil, caPoolErr
+		}
+		config.RootCAs = caPool
+	}
+
+	switch certMode {
+	case certNone:
+		// Present nothing even if the server asks for a certificate
+	case certUntrusted:
+		untrustedOnce.Do(func() { untrustedCert, untrustedErr = selfSignedCert() })
+		if untrustedErr != nil {
+			return nil, untrustedErr
+		}
+		config.Certificates = []tls.Certificate{untrustedCert}
+	default:
+		certFile, keyFile := clientCertFor(service, route)
+		if certFile == "" {
+			break
+		}
+		cert, err := loadKeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// clientCertFor resolves the certificate and key files for a route, preferring
+// a route name mapping over a service name mapping over the defaults
+func clientCertFor(service, route string) (certFile, keyFile string) {
+	certFile, keyFile = *clientCert, *clientKey
+
+	for _, name := range []string{service, route} {
+		if cert, ok := (*clientCertMap)[name]; ok {
+			certFile = cert
+			keyFile = (*clientKeyMap)[name]
+		}
+	}
+
+	if keyFile == "" {
+		// Allow a combined PEM file holding both certificate and key
+		keyFile = certFile
+	}
+	return certFile, keyFile
+}
+
+func loadKeyPair(certFile, keyFile string) (tls.Certificate, error) {
+	keyPairMu.Lock()
+	defer keyPairMu.Unlock()
+
+	id := certFile + "\x00" + keyFile
+	if cert, ok := keyPairs[id]; ok {
+		return cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("loading client certificate %s: %w", certFile, err)
+	}
+	keyPairs[id] = cert
+	return cert, nil
+}
+
+func loadCAPool(filename string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA bundle: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", filename)
+	}
+	return pool, nil
+}
+
+// selfSignedCert generates a throwaway client certificate that no gateway
+// should trust, used to check that mtls-auth verifies the issuer
+func selfSignedCert() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "kong-route-tester-untrusted"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
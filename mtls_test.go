@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestClientCert creates a CA and a client certificate signed by it,
+// writes the client certificate and key to dir and returns the CA pool
+func writeTestClientCert(t *testing.T, dir string) (*x509.CertPool, string, string) {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate: %v", err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate client key: %v", err)
+	}
+	clientTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "route-tester"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTemplate, ca, &clientKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create client certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		t.Fatalf("failed to marshal client key: %v", err)
+	}
+
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: clientDER}), 0600); err != nil {
+		t.Fatalf("failed to write client certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("failed to write client key: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	return pool, certFile, keyFile
+}
+
+func TestTLSConfigForClientCertificates(t *testing.T) {
+	pool, certFile, keyFile := writeTestClientCert(t, t.TempDir())
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	server.StartTLS()
+	defer server.Close()
+
+	*insecureSkipVerify = true
+	*clientCertMap = map[string]string{"mtls-route": certFile}
+	*clientKeyMap = map[string]string{"mtls-route": keyFile}
+	defer func() {
+		*insecureSkipVerify = false
+		*clientCertMap = nil
+		*clientKeyMap = nil
+	}()
+
+	tests := []struct {
+		name      string
+		certMode  string
+		expectErr bool
+	}{
+		{name: "configured certificate is accepted", certMode: ""},
+		{name: "missing certificate is rejected", certMode: certNone, expectErr: true},
+		{name: "untrusted certificate is rejected", certMode: certUntrusted, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := tlsConfigFor("mtls-service", "mtls-route", tt.certMode)
+			if err != nil {
+				t.Fatalf("tlsConfigFor() error = %v", err)
+			}
+
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+			resp, err := client.Get(server.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if (err != nil) != tt.expectErr {
+				t.Errorf("request error = %v, want error %v", err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestClientCertFor(t *testing.T) {
+	*clientCert = "default.pem"
+	*clientKey = "default-key.pem"
+	*clientCertMap = map[string]string{"payments": "payments.pem", "refunds": "refunds-combined.pem"}
+	*clientKeyMap = map[string]string{"payments": "payments-key.pem"}
+	defer func() {
+		*clientCert = ""
+		*clientKey = ""
+		*clientCertMap = nil
+		*clientKeyMap = nil
+	}()
+
+	tests := []struct {
+		name         string
+		service      string
+		route        string
+		expectedCert string
+		expectedKey  string
+	}{
+		{
+			name:         "defaults when unmapped",
+			service:      "media-service",
+			route:        "media-upload",
+			expectedCert: "default.pem",
+			expectedKey:  "default-key.pem",
+		},
+		{
+			name:         "service mapping",
+			service:      "payments",
+			route:        "charges",
+			expectedCert: "payments.pem",
+			expectedKey:  "payments-key.pem",
+		},
+		{
+			name:         "route mapping with combined PEM",
+			service:      "payments",
+			route:        "refunds",
+			expectedCert: "refunds-combined.pem",
+			expectedKey:  "refunds-combined.pem",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert, key := clientCertFor(tt.service, tt.route)
+			if cert != tt.expectedCert || key != tt.expectedKey {
+				t.Errorf("clientCertFor() = %q, %q, want %q, %q", cert, key, tt.expectedCert, tt.expectedKey)
+			}
+		})
+	}
+}
+
+func TestCertModes(t *testing.T) {
+	defer func() { *authMatrix = true }()
+
+	mtlsRoute := Route{Name: "mtls", Plugins: []Plugin{{Name: "mtls-auth"}}}
+
+	*authMatrix = false
+	if modes := certModes(mtlsRoute, Service{}); len(modes) != 0 {
+		t.Errorf("certModes() without auth matrix = %v, want none", modes)
+	}
+
+	*authMatrix = true
+	if modes := certModes(Route{Name: "plain"}, Service{}); len(modes) != 0 {
+		t.Errorf("certModes() for route without mtls-auth = %v, want none", modes)
+	}
+	if modes := certModes(mtlsRoute, Service{}); len(modes) != 2 {
+		t.Errorf("certModes() for mtls-auth route = %v, want none and untrusted", modes)
+	}
+}
+
+func TestTestRoutesNegativeCertificates(t *testing.T) {
+	pool, certFile, keyFile := writeTestClientCert(t, t.TempDir())
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	server.StartTLS()
+	defer server.Close()
+
+	originalURL := *baseURL
+	*baseURL = server.URL
+	*authMatrix, *insecureSkipVerify = true, true
+	*clientCert, *clientKey = certFile, keyFile
+	resetClients()
+	defer func() {
+		*baseURL = originalURL
+		*authMatrix, *insecureSkipVerify = true, false
+		*clientCert, *clientKey = "", ""
+		resetClients()
+	}()
+
+	config := &KongConfig{Services: []Service{{
+		Name:   "payments",
+		Routes: []Route{{Name: "charges", Paths: []string{"/charges"}, Methods: []string{"GET"}, Plugins: []Plugin{{Name: "mtls-auth"}}}},
+	}}}
+
+	for _, result := range testRoutes(context.Background(), config) {
+		if !resultPassed(result) {
+			t.Errorf("cert mode %q: expected the handshake to reject only negative certificates, got %+v", result.CertMode, result)
+		}
+	}
+
+	// A gateway that is down has not rejected anything
+	server.Close()
+	for _, result := range testRoutes(context.Background(), config) {
+		if result.CertMode != "" && (resultPassed(result) || result.CertRejected) {
+			t.Errorf("cert mode %q: expected a failure when the gateway is down, got %+v", result.CertMode, result)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/danpilch/kong-route-tester/kong"
+	"github.com/spf13/pflag"
+)
+
+// Negative routing flags
+var (
+	negativeMode = pflag.Bool("negative", false, "Send undeclared methods, paths just outside each route and a random path, expecting no route to match")
+)
+
+// negativeMethods are the methods sent to routes that don't declare them
+var negativeMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// Kinds of negative probe
+const (
+	negativeMethod   = "method"
+	negativeBoundary = "boundary"
+	negativeRandom   = "random"
+)
+
+// NegativeProbe is a request no route should match
+type NegativeProbe struct {
+	Service    string // Service and route the probe was derived from, empty for random paths
+	Route      string
+	Path       string
+	Method     string
+	Kind       string
+	Reason     string // How the probe differs from the route
+	StatusCode int
+	Error      error
+
+	// Unrouted is true when the gateway answered "no Route matched"
+	Unrouted bool
+
+	// MatchedBy names the route the configuration says matches the probe,
+	// empty when none does
+	MatchedBy string
+}
+
+// Unexpected reports whether a route answered the probe
+func (p NegativeProbe) Unexpected() bool {
+	return p.Error == nil && p.StatusCode != 0 && !p.Unrouted
+}
+
+// boundaryPaths returns variations of a route's sample path that the route
+// pattern does not match, keyed by how they were made
+func boundaryPaths(pattern, sample string, params map[string]string) [][2]string {
+	candidates := [][2]string{
+		{"truncated", sample[:len(sample)-1]},
+		{"suffixed", sample + "/kong-route-tester"},
+		{"case changed", swapLastSegmentCase(sample)},
+	}
+	if i := strings.LastIndex(strings.TrimSuffix(sample, "/"), "/"); i > 0 {
+		candidates = append(candidates, [2]string{"parent", sample[:i]})
+	}
+	for name, value := range params {
+		if value != "" {
+			candidates = append(candidates, [2]string{name + " invalid", strings.Replace(sample, value, "kong-route-tester!", 1)})
+		}
+	}
+
+	var paths [][2]string
+	seen := map[string]bool{sample: true}
+	for _, c := range candidates {
+		if c[1] == "" || seen[c[1]] {
+			continue
+		}
+		seen[c[1]] = true
+		if _, ok := kong.MatchPath(pattern, c[1]); !ok {
+			paths = append(paths, c)
+		}
+	}
+	slices.SortFunc(paths, func(a, b [2]string) int { return strings.Compare(a[0], b[0]) })
+	return paths
+}
+
+// negativeProbes lists the probes for every route plus one random path
+func negativeProbes(config *KongConfig, router *kong.Router) []NegativeProbe {
+	var probes []NegativeProbe
+	for _, service := range config.Services {
+		if skipService(service) {
+			continue
+		}
+		for _, route := range service.Routes {
+			if !routeSelected(service, route) {
+				continue
+			}
+			host := routeHost(route)
+			for _, pattern := range route.Paths {
+				sample, params := expandRegexPathParams(pattern)
+				probe := NegativeProbe{Service: service.Name, Route: route.Name, Path: sample}
+
+				if len(route.Methods) > 0 {
+					for _, method := range negativeMethods {
+						if kong.MatchMethod(route, method) {
+							continue
+						}
+						// Another route may declare the same path for this method
+						if match, ok := router.Match(method, host, sample); ok && match.Path == pattern {
+							continue
+						}
+						p := probe
+						p.Method, p.Kind, p.Reason = method, negativeMethod, "undeclared method"
+						probes = append(probes, p)
+					}
+				}
+
+				for _, boundary := range boundaryPaths(pattern, sample, params) {
+					p := probe
+					p.Path, p.Method, p.Kind, p.Reason = boundary[1], preferredMethod(route), negativeBoundary, boundary[0]
+					probes = append(probes, p)
+				}
+			}
+		}
+	}
+
+	random := fmt.Sprintf("/kong-route-tester-%016x", rand.Uint64())
+	probes = append(probes, NegativeProbe{Path: random, Method: http.MethodGet, Kind: negativeRandom, Reason: "random path"})
+	return probes
+}
+
+// testNegative sends requests that no route should match
+func testNegative(ctx context.Context, config *KongConfig) []NegativeProbe {
+	router := kong.NewRouter(config)
+	routes := make(map[string]Route)
+	for _, service := range config.Services {
+		for _, route := range service.Routes {
+			routes[route.Name] = route
+		}
+	}
+
+	var probes []NegativeProbe
+	for _, probe := range negativeProbes(config, router) {
+		if ctx.Err() != nil {
+			break
+		}
+		if safetyAction("negative", probe.Service, probe.Route, probe.Path, probe.Method) == safetySkip {
+			continue
+		}
+
+		host := routeHost(routes[probe.Route])
+		if match, ok := router.Match(probe.Method, host, normalizePath(probe.Path)); ok {
+			probe.MatchedBy = fmt.Sprintf("%s (%s)", match.Route.Name, match.Service.Name)
+			if match.Path != "" {
+				probe.MatchedBy = fmt.Sprintf("%s %s (%s)", match.Route.Name, match.Path, match.Service.Name)
+			}
+		}
+		if !*dryRun {
+			probe = sendNegativeProbe(ctx, host, probe)
+		}
+		printNegativeProbe(probe)
+		probes = append(probes, probe)
+
+		sleepContext(ctx, *delay)
+	}
+	return probes
+}
+
+func sendNegativeProbe(ctx context.Context, host string, probe NegativeProbe) NegativeProbe {
+	u, err := url.Parse(*baseURL + probe.Path)
+	if err != nil {
+		probe.Error = err
+		return probe
+	}
+	req, err := http.NewRequestWithContext(ctx, probe.Method, u.String(), nil)
+	if err != nil {
+		probe.Error = err
+		return probe
+	}
+	if host != "" {
+		req.Host = host
+	}
+
+	client, err := clientFor(probe.Service, probe.Route, "")
+	if err != nil {
+		probe.Error = err
+		return probe
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		probe.Error = err
+		return probe
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	probe.StatusCode = resp.StatusCode
+	probe.Unrouted = noRouteMatched(resp.StatusCode, body)
+	return probe
+}
+
+func printNegativeProbe(probe NegativeProbe) {
+	if !*verbose && !probe.Unexpected() && probe.Error == nil && !*dryRun {
+		return
+	}
+
+	status := "✓"
+	switch {
+	case *dryRun:
+		status = "○"
+	case probe.Unexpected(), probe.Error != nil:
+		status = "✗"
+	}
+
+	fmt.Printf("%s %-30s %-40s %-6s %3d [NEGATIVE:%s]", status, probe.Service, truncate(probe.Path, 40), probe.Method, probe.StatusCode, probe.Reason)
+	switch {
+	case probe.Error != nil:
+		fmt.Printf(" ERROR: %v", probe.Error)
+	case probe.MatchedBy != "":
+		fmt.Printf(" - matches %s", probe.MatchedBy)
+	case probe.Unexpected():
+		fmt.Print(" - matched by a route not in the config")
+	}
+	fmt.Println()
+}
+
+func printNegativeSummary(probes []NegativeProbe) {
+	if !*negativeMode || *dryRun {
+		return
+	}
+
+	unrouted, errors := 0, 0
+	matches := make(map[string][]NegativeProbe)
+	for _, probe := range probes {
+		switch {
+		case probe.Error != nil:
+			errors++
+		case probe.Unexpected():
+			matches[probe.MatchedBy] = append(matches[probe.MatchedBy], probe)
+		default:
+			unrouted++
+		}
+	}
+
+	fmt.Printf("\nNegative Routing (%d probes):\n", len(probes))
+	fmt.Printf("  No route matched:   %d\n", unrouted)
+	fmt.Printf("  Unexpected matches: %d\n", len(probes)-unrouted-errors)
+	fmt.Printf("  Errors:             %d\n", errors)
+
+	if len(matches) == 0 {
+		return
+	}
+
+	// Routes swallowing the most probes first, usually greedy catch-alls
+	routes := make([]string, 0, len(matches))
+	for route := range matches {
+		routes = append(routes, route)
+	}
+	slices.SortFunc(routes, func(a, b string) int {
+		if n := len(matches[b]) - len(matches[a]); n != 0 {
+			return n
+		}
+		return strings.Compare(a, b)
+	})
+
+	fmt.Println("\nUnexpected matches by route:")
+	for _, route := range routes {
+		name := route
+		if name == "" {
+			name = "no route in the config (the gateway has routes the config lacks)"
+		}
+		fmt.Printf("  %s: %d\n", name, len(matches[route]))
+		for _, probe := range matches[route] {
+			fmt.Printf("    - %s %s [%s", probe.Method, probe.Path, probe.Reason)
+			if probe.Route != "" {
+				fmt.Printf(" of %s", probe.Route)
+			}
+			fmt.Printf("]: %d\n", probe.StatusCode)
+		}
+	}
+}
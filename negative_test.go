@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/danpilch/kong-route-tester/kong"
+	"github.com/danpilch/kong-route-tester/mock"
+)
+
+func TestBoundaryPaths(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    [][2]string
+	}{
+		{
+			pattern: "/api/v1/users",
+			want:    [][2]string{{"case changed", "/api/v1/Users"}, {"parent", "/api/v1"}, {"truncated", "/api/v1/user"}},
+		},
+		{
+			// Unanchored regexes match anything after them
+			pattern: "/api/v1/users/(?<user_id>[0-9a-fA-F-]+)/profile",
+			want:    [][2]string{{"case changed", "/api/v1/users/abc123def456/Profile"}, {"parent", "/api/v1/users/abc123def456"}, {"truncated", "/api/v1/users/abc123def456/profil"}, {"user_id invalid", "/api/v1/users/kong-route-tester!/profile"}},
+		},
+	}
+
+	for _, tt := range tests {
+		sample, params := expandRegexPathParams(tt.pattern)
+		got := boundaryPaths(tt.pattern, sample, params)
+		if len(got) != len(tt.want) {
+			t.Errorf("boundaryPaths(%q) = %v, want %v", tt.pattern, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("boundaryPaths(%q)[%d] = %v, want %v", tt.pattern, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+// TestTestNegative checks that a greedy catch-all is reported as matching
+// probes meant for other routes
+func TestTestNegative(t *testing.T) {
+	config := &kong.Config{Services: []Service{
+		{Name: "users", URL: "http://users:8000", Routes: []Route{
+			{Name: "users", Paths: []string{"/api/v1/users"}, Methods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"}},
+		}},
+		{Name: "legacy", URL: "http://legacy:8000", Routes: []Route{
+			{Name: "catchall", Paths: []string{"/api/v1/(.*)"}},
+		}},
+	}}
+
+	server := httptest.NewServer(mock.NewGateway(config, mock.Options{}))
+	defer server.Close()
+
+	originalURL := *baseURL
+	*baseURL = server.URL
+	defer func() { *baseURL = originalURL }()
+
+	probes := testNegative(context.Background(), config)
+
+	var unexpected []NegativeProbe
+	for _, probe := range probes {
+		if probe.Error != nil {
+			t.Fatalf("probe %s %s failed: %v", probe.Method, probe.Path, probe.Error)
+		}
+		if probe.Unexpected() {
+			unexpected = append(unexpected, probe)
+		}
+	}
+
+	// OPTIONS and the truncated and case changed paths of users
+	if len(unexpected) != 3 {
+		t.Fatalf("expected three unexpected matches, got %+v", unexpected)
+	}
+	for _, probe := range unexpected {
+		if probe.Route != "users" || probe.MatchedBy != "catchall /api/v1/(.*) (legacy)" {
+			t.Errorf("expected a users probe matched by the catch-all, got %+v", probe)
+		}
+	}
+	if last := probes[len(probes)-1]; last.Kind != negativeRandom || !last.Unrouted {
+		t.Errorf("expected the random path to be unrouted, got %+v", last)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// OAuth2 configuration flags
+var (
+	oauthTokenURL     = pflag.String("oauth-token-url", "", "OAuth2/OIDC token endpoint used to acquire access tokens")
+	oauthGrant        = pflag.String("oauth-grant", "client_credentials", "OAuth2 grant type (client_credentials or password)")
+	oauthClientID     = pflag.String("oauth-client-id", "", "OAuth2 client ID")
+	oauthClientSecret = pflag.String("oauth-client-secret", "", "OAuth2 client secret")
+	oauthUsername     = pflag.String("oauth-username", "", "Resource owner username for the password grant")
+	oauthPassword     = pflag.String("oauth-password", "", "Resource owner password for the password grant")
+	oauthAudience     = pflag.String("oauth-audience", "", "Default audience to request tokens for")
+	oauthScope        = pflag.String("oauth-scope", "", "Default space-separated scopes to request")
+	oauthAudienceMap  = pflag.StringToString("oauth-audience-map", nil, "Audience per service or route name (name=audience)")
+	oauthScopeMap     = pflag.StringToString("oauth-scope-map", nil, "Scopes per service or route name (name=\"scope1 scope2\")")
+)
+
+// tokenExpirySkew is how long before expiry a cached token is considered stale
+const tokenExpirySkew = 30 * time.Second
+
+// oauthClient acquires and caches access tokens from an OAuth2 token endpoint
+type oauthClient struct {
+	tokenURL     string
+	grantType    string
+	clientID     string
+	clientSecret string
+	username     string
+	password     string
+	httpClient   *http.Client
+	now          func() time.Time
+
+	mu    sync.Mutex
+	cache map[tokenKey]cachedToken
+}
+
+// tokenKey identifies a cached token by the audience and scopes it was issued for
+type tokenKey struct {
+	audience string
+	scope    string
+}
+
+type cachedToken struct {
+	accessToken  string
+	refreshToken string
+	expiresAt    time.Time // zero means the server did not report an expiry
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int    `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// oauth is the shared token client, nil when no token URL is configured
+var oauth *oauthClient
+
+func newOAuthClientFromFlags() (*oauthClient, error) {
+	if *oauthTokenURL == "" {
+		return nil, nil
+	}
+
+	switch *oauthGrant {
+	case "client_credentials":
+	case "password":
+		if *oauthUsername == "" {
+			return nil, fmt.Errorf("--oauth-username is required for the password grant")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported OAuth2 grant type %q", *oauthGrant)
+	}
+
+	return &oauthClient{
+		tokenURL:     *oauthTokenURL,
+		grantType:    *oauthGrant,
+		clientID:     *oauthClientID,
+		clientSecret: *oauthClientSecret,
+		username:     *oauthUsername,
+		password:     *oauthPassword,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+		now:          time.Now,
+		cache:        make(map[tokenKey]cachedToken),
+	}, nil
+}
+
+// token returns a valid access token for the audience and scope, fetching or
+// refreshing it from the token endpoint when the cached one is missing or stale
+func (c *oauthClient) token(audience, scope string) (string, error) {
+	key := tokenKey{audience: audience, scope: scope}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.cache[key]
+	if ok && (cached.expiresAt.IsZero() || c.now().Before(cached.expiresAt.Add(-tokenExpirySkew))) {
+		return cached.accessToken, nil
+	}
+
+	var fresh cachedToken
+	var err error
+	if ok && cached.refreshToken != "" {
+		fresh, err = c.fetch(url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {cached.refreshToken},
+		})
+	}
+	if !ok || cached.refreshToken == "" || err != nil {
+		fresh, err = c.fetch(c.grantParams(audience, scope))
+	}
+	if err != nil {
+		return "", err
+	}
+
+	c.cache[key] = fresh
+	return fresh.accessToken, nil
+}
+
+func (c *oauthClient) grantParams(audience, scope string) url.Values {
+	params := url.Values{"grant_type": {c.grantType}}
+	if c.grantType == "password" {
+		params.Set("username", c.username)
+		params.Set("password", c.password)
+	}
+	if audience != "" {
+		params.Set("audience", audience)
+	}
+	if scope != "" {
+		params.Set("scope", scope)
+	}
+	return params
+}
+
+func (c *oauthClient) fetch(params url.Values) (cachedToken, error) {
+	req, err := http.NewRequest("POST", c.tokenURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return cachedToken{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if c.clientID != "" {
+		req.SetBasicAuth(url.QueryEscape(c.clientID), url.QueryEscape(c.clientSecret))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return cachedToken{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return cachedToken{}, err
+	}
+
+	var tr tokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return cachedToken{}, fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, truncate(string(body), 100))
+	}
+	if tr.Error != "" {
+		return cachedToken{}, fmt.Errorf("token endpoint returned %s: %s", tr.Error, tr.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK || tr.AccessToken == "" {
+		return cachedToken{}, fmt.Errorf("token endpoint returned %d without an access token", resp.StatusCode)
+	}
+
+	token := cachedToken{accessToken: tr.AccessToken, refreshToken: tr.RefreshToken}
+	if tr.ExpiresIn > 0 {
+		token.expiresAt = c.now().Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// tokenTarget resolves the audience and scope for a route, preferring a route
+// name mapping over a service name mapping over the defaults
+func tokenTarget(service, route string) (audience, scope string) {
+	audience, scope = *oauthAudience, *oauthScope
+
+	if aud, ok := (*oauthAudienceMap)[service]; ok {
+		audience = aud
+	}
+	if aud, ok := (*oauthAudienceMap)[route]; ok {
+		audience = aud
+	}
+	if s, ok := (*oauthScopeMap)[service]; ok {
+		scope = s
+	}
+	if s, ok := (*oauthScopeMap)[route]; ok {
+		scope = s
+	}
+
+	return audience, scope
+}
+
+// credentialFor returns the bearer token to send to an authenticated route,
+// or an empty string when no credentials are configured
+func credentialFor(service, route string) (string, error) {
+	if oauth != nil {
+		audience, scope := tokenTarget(service, route)
+		return oauth.token(audience, scope)
+	}
+	return *authToken, nil
+}
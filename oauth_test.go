@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestTokenServer returns a token endpoint that issues sequentially numbered
+// tokens and records the form values of every request it receives
+func newTestTokenServer(t *testing.T, expiresIn int, refreshToken string) (*httptest.Server, *[]map[string]string) {
+	t.Helper()
+
+	var requests []map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm() error = %v", err)
+		}
+
+		user, pass, _ := r.BasicAuth()
+		params := map[string]string{"client_id": user, "client_secret": pass}
+		for key := range r.PostForm {
+			params[key] = r.PostForm.Get(key)
+		}
+		requests = append(requests, params)
+
+		if params["client_secret"] != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error": "invalid_client", "error_description": "bad secret"}`)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "Bearer", "expires_in": %d, "refresh_token": %q}`,
+			len(requests), expiresIn, refreshToken)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func newTestOAuthClient(tokenURL, grant, secret string) *oauthClient {
+	return &oauthClient{
+		tokenURL:     tokenURL,
+		grantType:    grant,
+		clientID:     "client",
+		clientSecret: secret,
+		username:     "alice",
+		password:     "wonderland",
+		httpClient:   &http.Client{Timeout: time.Second},
+		now:          time.Now,
+		cache:        make(map[tokenKey]cachedToken),
+	}
+}
+
+func TestOAuthClientCachesTokens(t *testing.T) {
+	server, requests := newTestTokenServer(t, 3600, "")
+	client := newTestOAuthClient(server.URL, "client_credentials", "secret")
+
+	first, err := client.token("users-api", "users:read")
+	if err != nil {
+		t.Fatalf("token() error = %v", err)
+	}
+	second, err := client.token("users-api", "users:read")
+	if err != nil {
+		t.Fatalf("token() error = %v", err)
+	}
+
+	if first != "token-1" || second != "token-1" {
+		t.Errorf("token() = %q, %q, want cached token-1 twice", first, second)
+	}
+	if len(*requests) != 1 {
+		t.Fatalf("expected 1 token request, got %d", len(*requests))
+	}
+
+	got := (*requests)[0]
+	if got["grant_type"] != "client_credentials" || got["audience"] != "users-api" || got["scope"] != "users:read" {
+		t.Errorf("unexpected token request parameters: %v", got)
+	}
+
+	// A different audience needs its own token
+	other, err := client.token("media-api", "")
+	if err != nil {
+		t.Fatalf("token() error = %v", err)
+	}
+	if other != "token-2" {
+		t.Errorf("token() for new audience = %q, want token-2", other)
+	}
+}
+
+func TestOAuthClientRefreshesExpiredTokens(t *testing.T) {
+	tests := []struct {
+		name          string
+		refreshToken  string
+		expectedGrant string
+	}{
+		{
+			name:          "re-runs the grant without a refresh token",
+			refreshToken:  "",
+			expectedGrant: "client_credentials",
+		},
+		{
+			name:          "uses the refresh token when one was issued",
+			refreshToken:  "refresh-me",
+			expectedGrant: "refresh_token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := newTestTokenServer(t, 60, tt.refreshToken)
+			client := newTestOAuthClient(server.URL, "client_credentials", "secret")
+
+			now := time.Now()
+			client.now = func() time.Time { return now }
+
+			if _, err := client.token("", ""); err != nil {
+				t.Fatalf("token() error = %v", err)
+			}
+
+			// Move past the expiry skew so the token is considered stale
+			now = now.Add(45 * time.Second)
+
+			token, err := client.token("", "")
+			if err != nil {
+				t.Fatalf("token() error = %v", err)
+			}
+			if token != "token-2" {
+				t.Errorf("token() after expiry = %q, want token-2", token)
+			}
+			if grant := (*requests)[1]["grant_type"]; grant != tt.expectedGrant {
+				t.Errorf("refresh used grant %q, want %q", grant, tt.expectedGrant)
+			}
+		})
+	}
+}
+
+func TestOAuthClientPasswordGrant(t *testing.T) {
+	server, requests := newTestTokenServer(t, 3600, "")
+	client := newTestOAuthClient(server.URL, "password", "secret")
+
+	if _, err := client.token("", ""); err != nil {
+		t.Fatalf("token() error = %v", err)
+	}
+
+	got := (*requests)[0]
+	if got["grant_type"] != "password" || got["username"] != "alice" || got["password"] != "wonderland" {
+		t.Errorf("unexpected password grant parameters: %v", got)
+	}
+}
+
+func TestOAuthClientError(t *testing.T) {
+	server, _ := newTestTokenServer(t, 3600, "")
+	client := newTestOAuthClient(server.URL, "client_credentials", "wrong")
+
+	_, err := client.token("", "")
+	if err == nil {
+		t.Fatal("expected error for rejected client credentials, got nil")
+	}
+	if err.Error() != "token endpoint returned invalid_client: bad secret" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestTokenTarget(t *testing.T) {
+	*oauthAudience = "default-aud"
+	*oauthScope = "default-scope"
+	*oauthAudienceMap = map[string]string{"protected-api": "protected-aud", "admin-endpoints": "admin-aud"}
+	*oauthScopeMap = map[string]string{"admin-endpoints": "admin:write"}
+	defer func() {
+		*oauthAudience = ""
+		*oauthScope = ""
+		*oauthAudienceMap = nil
+		*oauthScopeMap = nil
+	}()
+
+	tests := []struct {
+		name             string
+		service          string
+		route            string
+		expectedAudience string
+		expectedScope    string
+	}{
+		{
+			name:             "defaults when unmapped",
+			service:          "public-api",
+			route:            "public-endpoints",
+			expectedAudience: "default-aud",
+			expectedScope:    "default-scope",
+		},
+		{
+			name:             "service mapping",
+			service:          "protected-api",
+			route:            "user-data",
+			expectedAudience: "protected-aud",
+			expectedScope:    "default-scope",
+		},
+		{
+			name:             "route mapping wins over service mapping",
+			service:          "protected-api",
+			route:            "admin-endpoints",
+			expectedAudience: "admin-aud",
+			expectedScope:    "admin:write",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audience, scope := tokenTarget(tt.service, tt.route)
+			if audience != tt.expectedAudience || scope != tt.expectedScope {
+				t.Errorf("tokenTarget() = %q, %q, want %q, %q", audience, scope, tt.expectedAudience, tt.expectedScope)
+			}
+		})
+	}
+}
@@ -0,0 +1,594 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/danpilch/kong-route-tester/kong"
+	"github.com/spf13/pflag"
+	"go.yaml.in/yaml/v4"
+)
+
+// openAPIFiles maps service names to the OpenAPI 3 spec of their upstream
+var openAPIFiles = pflag.StringToString("openapi", nil, "OpenAPI 3 spec per service, used to generate requests and check responses (service=spec.yaml)")
+
+// openAPISpecs holds the loaded specs keyed by service name
+var openAPISpecs map[string]*OpenAPISpec
+
+// OpenAPISpec is the subset of an OpenAPI 3 document the tester uses
+type OpenAPISpec struct {
+	Servers    []OpenAPIServer            `yaml:"servers"`
+	Paths      map[string]OpenAPIPathItem `yaml:"paths"`
+	Components OpenAPIComponents          `yaml:"components"`
+
+	// ops holds the resolved operations, computed once when the spec is loaded
+	ops []*openAPIOperation
+}
+
+type OpenAPIServer struct {
+	URL string `yaml:"url"`
+}
+
+type OpenAPIComponents struct {
+	Schemas       map[string]*Schema           `yaml:"schemas"`
+	Parameters    map[string]*OpenAPIParameter `yaml:"parameters"`
+	RequestBodies map[string]*OpenAPIBody      `yaml:"requestBodies"`
+	Responses     map[string]*OpenAPIResponse  `yaml:"responses"`
+}
+
+type OpenAPIPathItem struct {
+	Parameters []*OpenAPIParameter `yaml:"parameters"`
+	Get        *Operation          `yaml:"get"`
+	Put        *Operation          `yaml:"put"`
+	Post       *Operation          `yaml:"post"`
+	Delete     *Operation          `yaml:"delete"`
+	Patch      *Operation          `yaml:"patch"`
+	Head       *Operation          `yaml:"head"`
+	Options    *Operation          `yaml:"options"`
+}
+
+type Operation struct {
+	OperationID string                      `yaml:"operationId"`
+	Parameters  []*OpenAPIParameter         `yaml:"parameters"`
+	RequestBody *OpenAPIBody                `yaml:"requestBody"`
+	Responses   map[string]*OpenAPIResponse `yaml:"responses"`
+}
+
+type OpenAPIParameter struct {
+	Ref      string      `yaml:"$ref"`
+	Name     string      `yaml:"name"`
+	In       string      `yaml:"in"`
+	Required bool        `yaml:"required"`
+	Schema   *Schema     `yaml:"schema"`
+	Example  interface{} `yaml:"example"`
+}
+
+type OpenAPIBody struct {
+	Ref      string                       `yaml:"$ref"`
+	Required bool                         `yaml:"required"`
+	Content  map[string]*OpenAPIMediaType `yaml:"content"`
+}
+
+type OpenAPIResponse struct {
+	Ref     string                       `yaml:"$ref"`
+	Content map[string]*OpenAPIMediaType `yaml:"content"`
+}
+
+type OpenAPIMediaType struct {
+	Schema  *Schema     `yaml:"schema"`
+	Example interface{} `yaml:"example"`
+}
+
+// openAPIOperation is an operation resolved to its method and full upstream
+// path template, including any server base path
+type openAPIOperation struct {
+	Method     string
+	Template   string
+	Operation  *Operation
+	Parameters []*OpenAPIParameter
+	spec       *OpenAPISpec
+	pattern    *regexp.Regexp
+}
+
+// Name identifies the operation in output
+func (op *openAPIOperation) Name() string {
+	if op.Operation.OperationID != "" {
+		return op.Operation.OperationID
+	}
+	return op.Method + " " + op.Template
+}
+
+// loadOpenAPISpecs reads the spec for every --openapi entry
+func loadOpenAPISpecs() (map[string]*OpenAPISpec, error) {
+	specs := make(map[string]*OpenAPISpec)
+	for service, filename := range *openAPIFiles {
+		spec, err := loadOpenAPISpec(filename)
+		if err != nil {
+			return nil, fmt.Errorf("OpenAPI spec for %s: %w", service, err)
+		}
+		specs[service] = spec
+	}
+	return specs, nil
+}
+
+// loadOpenAPISpec reads an OpenAPI 3 document in YAML or JSON
+func loadOpenAPISpec(filename string) (*OpenAPISpec, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var spec OpenAPISpec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+	spec.ops = spec.resolveOperations()
+	return &spec, nil
+}
+
+// basePaths returns the path prefixes of the spec's servers. Relative and
+// absolute server URLs are both allowed, and no servers means "/".
+func (s *OpenAPISpec) basePaths() []string {
+	var paths []string
+	for _, server := range s.Servers {
+		u, err := url.Parse(server.URL)
+		if err != nil || strings.Contains(u.Path, "{") {
+			continue
+		}
+		path := strings.TrimSuffix(u.Path, "/")
+		if !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		paths = append(paths, "")
+	}
+	return paths
+}
+
+// operations lists every operation in the spec in a stable order
+func (s *OpenAPISpec) operations() []*openAPIOperation {
+	return s.ops
+}
+
+// resolveOperations resolves every operation in the spec to its full path
+// template and compiles the template's pattern
+func (s *OpenAPISpec) resolveOperations() []*openAPIOperation {
+	templates := make([]string, 0, len(s.Paths))
+	for template := range s.Paths {
+		templates = append(templates, template)
+	}
+	sort.Strings(templates)
+
+	var ops []*openAPIOperation
+	for _, base := range s.basePaths() {
+		for _, template := range templates {
+			item := s.Paths[template]
+			for _, entry := range []struct {
+				method string
+				op     *Operation
+			}{
+				{"GET", item.Get}, {"POST", item.Post}, {"PUT", item.Put}, {"PATCH", item.Patch},
+				{"DELETE", item.Delete}, {"HEAD", item.Head}, {"OPTIONS", item.Options},
+			} {
+				if entry.op == nil {
+					continue
+				}
+				full := base + template
+				ops = append(ops, &openAPIOperation{
+					Method:     entry.method,
+					Template:   full,
+					Operation:  entry.op,
+					Parameters: s.mergeParameters(item.Parameters, entry.op.Parameters),
+					spec:       s,
+					pattern:    templateRegex(full),
+				})
+			}
+		}
+	}
+	return ops
+}
+
+// mergeParameters resolves path item and operation parameters, letting the
+// operation override parameters with the same name and location
+func (s *OpenAPISpec) mergeParameters(shared, own []*OpenAPIParameter) []*OpenAPIParameter {
+	var merged []*OpenAPIParameter
+	index := make(map[string]int)
+
+	for _, list := range [][]*OpenAPIParameter{shared, own} {
+		for _, p := range list {
+			p = s.parameter(p)
+			if p == nil {
+				continue
+			}
+			key := p.In + ":" + p.Name
+			if i, ok := index[key]; ok {
+				merged[i] = p
+				continue
+			}
+			index[key] = len(merged)
+			merged = append(merged, p)
+		}
+	}
+	return merged
+}
+
+// templateRegex converts an OpenAPI path template to an anchored regex
+func templateRegex(template string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	rest := template
+	for {
+		start := strings.Index(rest, "{")
+		end := strings.Index(rest, "}")
+		if start < 0 || end < start {
+			break
+		}
+		b.WriteString(regexp.QuoteMeta(rest[:start]))
+		b.WriteString("[^/]+")
+		rest = rest[end+1:]
+	}
+	b.WriteString(regexp.QuoteMeta(rest))
+	b.WriteString("/?$")
+	return regexp.MustCompile(b.String())
+}
+
+// localRef returns the component name of a local reference of the given kind
+func localRef(ref, kind string) (string, bool) {
+	prefix := "#/components/" + kind + "/"
+	if !strings.HasPrefix(ref, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(ref, prefix), true
+}
+
+func (s *OpenAPISpec) parameter(p *OpenAPIParameter) *OpenAPIParameter {
+	for i := 0; p != nil && p.Ref != "" && i < maxRefDepth; i++ {
+		name, _ := localRef(p.Ref, "parameters")
+		p = s.Components.Parameters[name]
+	}
+	return p
+}
+
+func (s *OpenAPISpec) requestBody(b *OpenAPIBody) *OpenAPIBody {
+	for i := 0; b != nil && b.Ref != "" && i < maxRefDepth; i++ {
+		name, _ := localRef(b.Ref, "requestBodies")
+		b = s.Components.RequestBodies[name]
+	}
+	return b
+}
+
+func (s *OpenAPISpec) response(r *OpenAPIResponse) *OpenAPIResponse {
+	for i := 0; r != nil && r.Ref != "" && i < maxRefDepth; i++ {
+		name, _ := localRef(r.Ref, "responses")
+		r = s.Components.Responses[name]
+	}
+	return r
+}
+
+// concretePath fills the operation's path template with example parameter
+// values, returning the path and the values chosen
+func (op *openAPIOperation) concretePath() (string, map[string]string) {
+	params := make(map[string]string)
+	for _, p := range op.Parameters {
+		if p.In == "path" {
+			params[p.Name] = parameterValue(op.spec, p)
+		}
+	}
+
+	var b strings.Builder
+	rest := op.Template
+	for {
+		start := strings.Index(rest, "{")
+		end := strings.Index(rest, "}")
+		if start < 0 || end < start {
+			break
+		}
+		name := rest[start+1 : end]
+		value, ok := params[name]
+		if !ok {
+			value = "example"
+			params[name] = value
+		}
+		b.WriteString(rest[:start])
+		b.WriteString(url.PathEscape(value))
+		rest = rest[end+1:]
+	}
+	b.WriteString(rest)
+	return b.String(), params
+}
+
+// parameterValue returns an example value for a parameter as a string
+func parameterValue(spec *OpenAPISpec, p *OpenAPIParameter) string {
+	value := p.Example
+	if value == nil {
+		value = generateValue(spec, p.Schema, 0)
+	}
+	if value == nil {
+		return "example"
+	}
+	return fmt.Sprint(value)
+}
+
+// findOperation returns the operation serving an upstream path and method
+func findOperation(ops []*openAPIOperation, method, path string) *openAPIOperation {
+	for _, op := range ops {
+		if op.Method == method && op.pattern.MatchString(path) {
+			return op
+		}
+	}
+	return nil
+}
+
+// gatewayPathFor returns a request path that Kong routes through the route
+// path pattern to the upstream path, or false if the route cannot reach it
+func gatewayPathFor(service Service, route Route, pattern, upstream string) (string, bool) {
+	base := strings.TrimSuffix(kong.ServicePath(service), "/")
+	if !strings.HasPrefix(upstream, base) {
+		return "", false
+	}
+	rest := strings.TrimPrefix(upstream, base)
+
+	var candidates []string
+	if kong.StripPath(route) {
+		prefix := pattern
+		if kong.IsRegexPath(pattern) {
+			prefix = expandRegexPath(strings.TrimPrefix(pattern, "~"))
+		}
+		candidates = append(candidates, prefix+rest, strings.TrimSuffix(prefix, "/")+rest)
+		if rest == "" {
+			candidates = append(candidates, prefix+"/")
+		}
+	} else {
+		candidates = append(candidates, rest, "/"+strings.TrimPrefix(rest, "/"))
+	}
+
+	for _, candidate := range candidates {
+		if _, ok := kong.MatchPath(pattern, candidate); !ok {
+			continue
+		}
+		if kong.UpstreamPath(service, route, pattern, candidate) == upstream {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// operationTarget is an operation reachable through a route path
+type operationTarget struct {
+	Operation *openAPIOperation
+	Path      string
+	Params    map[string]string
+}
+
+// routeOperations lists the operations reachable through a route path with
+// the given method, along with the request path that reaches each one
+func routeOperations(service Service, route Route, pattern, method string) []operationTarget {
+	spec := openAPISpecs[service.Name]
+	if spec == nil {
+		return nil
+	}
+
+	var targets []operationTarget
+	for _, op := range spec.operations() {
+		if op.Method != method {
+			continue
+		}
+		upstream, params := op.concretePath()
+		if path, ok := gatewayPathFor(service, route, pattern, upstream); ok {
+			targets = append(targets, operationTarget{Operation: op, Path: path, Params: params})
+		}
+	}
+	return targets
+}
+
+// openAPIRequest builds the request for an operation from its documented
+// parameters and request body schema
+func openAPIRequest(op *openAPIOperation) (*requestTemplate, error) {
+	tmpl := &requestTemplate{
+		Headers: make(map[string]string),
+		Query:   url.Values{},
+	}
+
+	for _, p := range op.Parameters {
+		if !p.Required {
+			continue
+		}
+		switch p.In {
+		case "query":
+			tmpl.Query.Set(p.Name, parameterValue(op.spec, p))
+		case "header":
+			tmpl.Headers[p.Name] = parameterValue(op.spec, p)
+		}
+	}
+
+	body := op.spec.requestBody(op.Operation.RequestBody)
+	if body == nil || len(body.Content) == 0 {
+		return tmpl, nil
+	}
+
+	contentType, media := preferredMediaType(body.Content)
+	value := media.Example
+	if value == nil {
+		value = generateValue(op.spec, media.Schema, 0)
+	}
+
+	fixture := Fixture{ContentType: contentType}
+	switch {
+	case strings.HasPrefix(contentType, "multipart/form-data"), contentType == "application/x-www-form-urlencoded":
+		fixture.Form = make(map[string]string)
+		if fields, ok := value.(map[string]interface{}); ok {
+			for name, field := range fields {
+				fixture.Form[name] = fmt.Sprint(field)
+			}
+		}
+	case isJSONMediaType(contentType):
+		fixture.JSON = value
+	default:
+		fixture.Body = fmt.Sprint(value)
+	}
+
+	rendered, err := fixture.render(templateData{})
+	if err != nil {
+		return nil, err
+	}
+	rendered.Headers, rendered.Query = tmpl.Headers, tmpl.Query
+	return rendered, nil
+}
+
+// preferredMediaType picks JSON when an operation documents several types
+func preferredMediaType(content map[string]*OpenAPIMediaType) (string, *OpenAPIMediaType) {
+	types := make([]string, 0, len(content))
+	for contentType := range content {
+		types = append(types, contentType)
+	}
+	sort.Strings(types)
+
+	for _, contentType := range types {
+		if isJSONMediaType(contentType) {
+			return contentType, mediaOrEmpty(content[contentType])
+		}
+	}
+	return types[0], mediaOrEmpty(content[types[0]])
+}
+
+func mediaOrEmpty(media *OpenAPIMediaType) *OpenAPIMediaType {
+	if media == nil {
+		return &OpenAPIMediaType{}
+	}
+	return media
+}
+
+func isJSONMediaType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// documentedResponse returns the response documented for a status code,
+// trying the exact code, then a range such as 2XX, then default
+func (op *openAPIOperation) documentedResponse(status int) (*OpenAPIResponse, bool) {
+	code := fmt.Sprint(status)
+	for _, key := range []string{code, code[:1] + "XX", code[:1] + "xx", "default"} {
+		if response, ok := op.Operation.Responses[key]; ok {
+			return op.spec.response(response), true
+		}
+	}
+	return nil, false
+}
+
+// checkContract validates a response against the operation's documented
+// responses, returning a description of each mismatch
+func checkContract(op *openAPIOperation, status int, contentType string, body []byte) []string {
+	response, ok := op.documentedResponse(status)
+	if !ok {
+		return []string{fmt.Sprintf("status %d is not documented", status)}
+	}
+	if response == nil || len(response.Content) == 0 || !isJSONMediaType(contentType) {
+		return nil
+	}
+
+	var schema *Schema
+	for documented, media := range response.Content {
+		if isJSONMediaType(documented) && media != nil {
+			schema = media.Schema
+			break
+		}
+	}
+	if schema == nil {
+		return nil
+	}
+
+	value, err := decodeJSON(body)
+	if err != nil {
+		return []string{fmt.Sprintf("invalid JSON body: %v", err)}
+	}
+	return validateValue(op.spec, schema, value, "body", 0)
+}
+
+// unmatchedRoute is a Kong route path and method with no OpenAPI operation
+type unmatchedRoute struct {
+	Service string
+	Route   string
+	Path    string
+	Method  string
+}
+
+// unmatchedRoutes lists the route paths and methods of services with a spec
+// that reach no documented operation
+func unmatchedRoutes(config *KongConfig) []unmatchedRoute {
+	var routes []unmatchedRoute
+
+	for _, service := range config.Services {
+		if openAPISpecs[service.Name] == nil {
+			continue
+		}
+		for _, route := range service.Routes {
+			for _, pattern := range route.Paths {
+				for _, method := range routeMethods(route) {
+					if len(routeOperations(service, route, pattern, method)) == 0 {
+						routes = append(routes, unmatchedRoute{service.Name, route.Name, pattern, method})
+					}
+				}
+			}
+		}
+	}
+	return routes
+}
+
+// operationLabel formats an optional operation ID for output
+func operationLabel(operationID string) string {
+	if operationID == "" {
+		return ""
+	}
+	return ", " + operationID
+}
+
+// printOpenAPIReport prints contract violations and the routes and operations
+// that could not be matched to each other
+func printOpenAPIReport(config *KongConfig, results []TestResult) {
+	if len(openAPISpecs) == 0 {
+		return
+	}
+
+	fmt.Println("\nContract Violations:")
+	violations := 0
+	for _, result := range results {
+		for _, problem := range result.ContractErrors {
+			fmt.Printf("  - %s %s (%s, %s): %s\n", result.Method, result.Path, result.Service, result.Operation, problem)
+			violations++
+		}
+	}
+	if violations == 0 {
+		fmt.Println("  None")
+	}
+
+	routes := unmatchedRoutes(config)
+
+	fmt.Println("\nKong Routes Without OpenAPI Operations:")
+	for _, route := range routes {
+		fmt.Printf("  - %s %s (%s/%s)\n", route.Method, route.Path, route.Service, route.Route)
+	}
+	if len(routes) == 0 {
+		fmt.Println("  None")
+	}
+
+	fmt.Println("\nOpenAPI Operations Without Kong Routes:")
+	unrouted := 0
+	for _, service := range buildCoverage(config).Services {
+		for _, op := range service.Operations {
+			if !op.Reachable {
+				fmt.Printf("  - %s %s (%s%s)\n", op.Method, op.Path, service.Service, operationLabel(op.OperationID))
+				unrouted++
+			}
+		}
+	}
+	if unrouted == 0 {
+		fmt.Println("  None")
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testOpenAPISpec = `openapi: 3.0.3
+servers:
+  - url: https://users.internal/api
+paths:
+  /accounts:
+    post:
+      operationId: createAccount
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/NewAccount'
+      responses:
+        "201":
+          description: Created
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Account'
+  /accounts/{account_id}:
+    parameters:
+      - $ref: '#/components/parameters/AccountID'
+    get:
+      operationId: getAccount
+      parameters:
+        - name: expand
+          in: query
+          required: true
+          schema:
+            type: string
+            enum: [profile]
+      responses:
+        "200":
+          description: OK
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Account'
+        4XX:
+          description: Client error
+  /internal/reindex:
+    post:
+      operationId: reindex
+      responses:
+        "202":
+          description: Accepted
+components:
+  parameters:
+    AccountID:
+      name: account_id
+      in: path
+      required: true
+      schema:
+        type: string
+        format: uuid
+  schemas:
+    NewAccount:
+      type: object
+      required: [email]
+      properties:
+        email:
+          type: string
+          format: email
+        seats:
+          type: integer
+          minimum: 5
+    Account:
+      allOf:
+        - $ref: '#/components/schemas/NewAccount'
+        - type: object
+          required: [id]
+          properties:
+            id:
+              type: string
+              readOnly: true
+`
+
+func loadTestOpenAPISpec(t *testing.T) *OpenAPISpec {
+	t.Helper()
+
+	filename := filepath.Join(t.TempDir(), "users.yaml")
+	if err := os.WriteFile(filename, []byte(testOpenAPISpec), 0644); err != nil {
+		t.Fatalf("Failed to write spec: %v", err)
+	}
+	spec, err := loadOpenAPISpec(filename)
+	if err != nil {
+		t.Fatalf("loadOpenAPISpec() error = %v", err)
+	}
+	return spec
+}
+
+func TestRouteOperations(t *testing.T) {
+	openAPISpecs = map[string]*OpenAPISpec{"user-service": loadTestOpenAPISpec(t)}
+	defer func() { openAPISpecs = nil }()
+
+	service := Service{Name: "user-service", URL: "http://users:8080/api/accounts"}
+	route := Route{Name: "accounts", Paths: []string{"/users/v1/accounts"}}
+
+	targets := routeOperations(service, route, "/users/v1/accounts", "GET")
+	if len(targets) != 1 {
+		t.Fatalf("expected 1 GET operation, got %d", len(targets))
+	}
+	expected := "/users/v1/accounts/123e4567-e89b-12d3-a456-426614174000"
+	if targets[0].Operation.Name() != "getAccount" || targets[0].Path != expected {
+		t.Errorf("unexpected target %s %s, want getAccount %s", targets[0].Operation.Name(), targets[0].Path, expected)
+	}
+
+	if targets := routeOperations(service, route, "/users/v1/accounts", "POST"); len(targets) != 1 || targets[0].Operation.Name() != "createAccount" {
+		t.Errorf("expected createAccount for POST, got %v", targets)
+	}
+
+	// The reindex operation lives outside the route's prefix
+	config := &KongConfig{Services: []Service{{
+		Name:   service.Name,
+		URL:    service.URL,
+		Routes: []Route{{Name: "accounts", Paths: []string{"/users/v1/accounts"}, Methods: []string{"GET", "POST", "DELETE"}}},
+	}}}
+	if routes := unmatchedRoutes(config); len(routes) != 1 || routes[0].Method != "DELETE" {
+		t.Errorf("expected only DELETE to be unmatched, got %v", routes)
+	}
+}
+
+func TestOpenAPIRequest(t *testing.T) {
+	spec := loadTestOpenAPISpec(t)
+	ops := spec.operations()
+
+	create := findOperation(ops, "POST", "/api/accounts")
+	if create == nil {
+		t.Fatal("createAccount not found")
+	}
+	tmpl, err := openAPIRequest(create)
+	if err != nil {
+		t.Fatalf("openAPIRequest() error = %v", err)
+	}
+	if tmpl.ContentType != "application/json" || string(tmpl.Body) != `{"email":"user@example.com","seats":5}` {
+		t.Errorf("unexpected body %q %q", tmpl.ContentType, tmpl.Body)
+	}
+
+	get := findOperation(ops, "GET", "/api/accounts/abc")
+	if get == nil {
+		t.Fatal("getAccount not found")
+	}
+	tmpl, err = openAPIRequest(get)
+	if err != nil {
+		t.Fatalf("openAPIRequest() error = %v", err)
+	}
+	if tmpl.Query.Get("expand") != "profile" || tmpl.Body != nil {
+		t.Errorf("unexpected request query %v body %q", tmpl.Query, tmpl.Body)
+	}
+}
+
+func TestCheckContract(t *testing.T) {
+	spec := loadTestOpenAPISpec(t)
+	get := findOperation(spec.operations(), "GET", "/api/accounts/abc")
+
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		expected string
+	}{
+		{name: "valid body", status: 200, body: `{"id": "a1", "email": "a@b.c"}`},
+		{name: "documented range", status: 404, body: `{"message": "not found"}`},
+		{name: "missing property", status: 200, body: `{"email": "a@b.c"}`, expected: `body: missing required property "id"`},
+		{name: "wrong type", status: 200, body: `{"id": 1, "email": "a@b.c"}`, expected: "body.id: expected string, got number"},
+		{name: "undocumented status", status: 500, body: `{}`, expected: "status 500 is not documented"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := checkContract(get, tt.status, "application/json", []byte(tt.body))
+			result := strings.Join(problems, "; ")
+			if result != tt.expected {
+				t.Errorf("checkContract() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTestEndpointChecksContract(t *testing.T) {
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"email": "user@example.com"}`))
+	}))
+	defer server.Close()
+
+	originalURL := *baseURL
+	*baseURL = server.URL
+	defer func() { *baseURL = originalURL }()
+
+	openAPISpecs = map[string]*OpenAPISpec{"user-service": loadTestOpenAPISpec(t)}
+	defer func() { openAPISpecs = nil }()
+
+	service := Service{Name: "user-service", URL: "http://users:8080/api/accounts"}
+	route := Route{Name: "accounts", Paths: []string{"/users/v1/accounts"}}
+	targets := withOperations(requestTarget{Service: service, Route: route, Pattern: route.Paths[0], Method: "POST"})
+	if len(targets) != 1 || targets[0].Path != "/users/v1/accounts" {
+		t.Fatalf("unexpected targets %v", targets)
+	}
+
+	result := testEndpoint(context.Background(), targets[0], credentialVariant{})
+
+	if received["email"] != "user@example.com" {
+		t.Errorf("expected generated body, got %v", received)
+	}
+	if result.Operation != "createAccount" || len(result.ContractErrors) != 1 {
+		t.Errorf("expected one contract error for createAccount, got %s %v", result.Operation, result.ContractErrors)
+	}
+}
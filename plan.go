@@ -0,0 +1,647 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"go.yaml.in/yaml/v4"
+)
+
+// planFile is the path to the test plan
+var planFile = pflag.String("plan", "", "YAML test plan with the settings for a run; flags override it")
+
+// planVersion is the test plan schema version this build reads
+const planVersion = 1
+
+// Plan is a reusable test scenario. Every setting has a flag of the same
+// meaning, and flags given on the command line take precedence.
+type Plan struct {
+	Version      int                        `yaml:"version"`
+	Kong         PlanKong                   `yaml:"kong"`
+	URL          string                     `yaml:"url"`
+	Environment  string                     `yaml:"environment"`
+	Environments map[string]PlanEnvironment `yaml:"environments"`
+	Filters      PlanFilters                `yaml:"filters"`
+	Credentials  PlanCredentials            `yaml:"credentials"`
+	Fixtures     string                     `yaml:"fixtures"`
+	OpenAPI      map[string]string          `yaml:"openapi"`
+	Expectations PlanExpectations           `yaml:"expectations"`
+	Checks       PlanChecks                 `yaml:"checks"`
+	Limits       PlanLimits                 `yaml:"limits"`
+	Safety       PlanSafety                 `yaml:"safety"`
+	Reporters    PlanReporters              `yaml:"reporters"`
+
+	// dir is the directory of the plan file, used to resolve relative paths
+	dir string
+}
+
+// PlanKong is where the Kong configuration comes from
+type PlanKong struct {
+	File string `yaml:"file"`
+}
+
+// PlanEnvironment is a named profile for a gateway the plan can run
+// against. Its settings take precedence over the rest of the plan.
+type PlanEnvironment struct {
+	URL         string `yaml:"url"`
+	TokenEnv    string `yaml:"token_env"`
+	Delay       string `yaml:"delay"`
+	MaxRequests *int   `yaml:"max_requests"`
+
+	// Production profiles refuse methods other than GET, HEAD and OPTIONS,
+	// except those listed per route name in AllowWrites
+	Production  bool                `yaml:"production"`
+	AllowWrites map[string][]string `yaml:"allow_writes"`
+}
+
+// PlanFilters selects the routes to test
+type PlanFilters struct {
+	Services   []string `yaml:"services"`
+	Routes     []string `yaml:"routes"`
+	TestAuth   *bool    `yaml:"test_auth"`
+	TestUnauth *bool    `yaml:"test_unauth"`
+
+	// ChangedFrom is a Kong configuration file or git revision to test
+	// changes since
+	ChangedFrom string `yaml:"changed_from"`
+}
+
+// PlanCredentials refers to credentials without containing them. Secrets are
+// read from the environment variables named by the *_env settings.
+type PlanCredentials struct {
+	TokenEnv           string            `yaml:"token_env"`
+	RateLimitTokenEnv  string            `yaml:"rate_limit_token_env"`
+	OAuth              PlanOAuth         `yaml:"oauth"`
+	ClientCert         string            `yaml:"client_cert"`
+	ClientKey          string            `yaml:"client_key"`
+	ClientCertMap      map[string]string `yaml:"client_cert_map"`
+	ClientKeyMap       map[string]string `yaml:"client_key_map"`
+	CACert             string            `yaml:"ca_cert"`
+	ServerName         string            `yaml:"server_name"`
+	InsecureSkipVerify *bool             `yaml:"insecure_skip_verify"`
+}
+
+// PlanOAuth configures OAuth2 token acquisition
+type PlanOAuth struct {
+	TokenURL        string            `yaml:"token_url"`
+	Grant           string            `yaml:"grant"`
+	ClientID        string            `yaml:"client_id"`
+	ClientSecretEnv string            `yaml:"client_secret_env"`
+	Username        string            `yaml:"username"`
+	PasswordEnv     string            `yaml:"password_env"`
+	Audience        string            `yaml:"audience"`
+	Scope           string            `yaml:"scope"`
+	AudienceMap     map[string]string `yaml:"audience_map"`
+	ScopeMap        map[string]string `yaml:"scope_map"`
+}
+
+// PlanExpectations are checks applied to every response
+type PlanExpectations struct {
+	ClientIP           string            `yaml:"client_ip"`
+	PluginProbes       *bool             `yaml:"plugin_probes"`
+	AssertUpstreamPath *bool             `yaml:"assert_upstream_path"`
+	SLO                map[string]string `yaml:"slo"`
+	SLODefault         string            `yaml:"slo_default"`
+	SLOPercentile      *float64          `yaml:"slo_percentile"`
+
+	// Baseline to compare with, and how much slower a route may get
+	Baseline             string   `yaml:"baseline"`
+	LatencyRegression    *float64 `yaml:"latency_regression"`
+	LatencyRegressionMin string   `yaml:"latency_regression_min"`
+}
+
+// PlanChecks enables the optional test passes
+type PlanChecks struct {
+	AuthMatrix        *bool  `yaml:"auth_matrix"`
+	InvalidToken      string `yaml:"invalid_token"`
+	CORS              *bool  `yaml:"cors"`
+	CORSForeignOrigin string `yaml:"cors_foreign_origin"`
+	BypassProbes      *bool  `yaml:"bypass_probes"`
+	HostProbes        *bool  `yaml:"host_probes"`
+	Negative          *bool  `yaml:"negative"`
+	RateLimitTest     *bool  `yaml:"rate_limit_test"`
+	RateLimitMax      *int   `yaml:"rate_limit_max"`
+	TrustedXFF        *bool  `yaml:"trusted_xff"`
+}
+
+// PlanLimits bounds how hard and how long the gateway is exercised
+type PlanLimits struct {
+	MaxRequests     *int     `yaml:"max_requests"`
+	Deadline        string   `yaml:"deadline"`
+	Delay           string   `yaml:"delay"`
+	Timeout         string   `yaml:"timeout"`
+	DialTimeout     string   `yaml:"dial_timeout"`
+	TLSTimeout      string   `yaml:"tls_timeout"`
+	HeaderTimeout   string   `yaml:"header_timeout"`
+	MaxAttempts     *int     `yaml:"max_attempts"`
+	RetryBackoff    string   `yaml:"retry_backoff"`
+	RetryMaxBackoff string   `yaml:"retry_max_backoff"`
+	RetryStatus     []int    `yaml:"retry_status"`
+	RetryOn         []string `yaml:"retry_on"`
+	RetryAnyMethod  *bool    `yaml:"retry_non_idempotent"`
+	KeepAlive       *bool    `yaml:"keep_alive"`
+	MaxIdleConns    *int     `yaml:"max_idle_conns"`
+	HTTP2           *bool    `yaml:"http2"`
+	Proxy           string   `yaml:"proxy"`
+	UnixSocket      string   `yaml:"unix_socket"`
+}
+
+// PlanSafety limits which requests are sent
+type PlanSafety struct {
+	Mode      string   `yaml:"mode"`
+	DenyPaths []string `yaml:"deny_paths"`
+}
+
+// PlanReporters configures output
+type PlanReporters struct {
+	Verbose      *bool  `yaml:"verbose"`
+	CoverageJSON string `yaml:"coverage_json"`
+	SaveBaseline string `yaml:"save_baseline"`
+	CoverageHTML string `yaml:"coverage_html"`
+}
+
+// planSetting is a plan value to apply to a flag
+type planSetting struct {
+	flag  string
+	value string
+	field string // Plan field the value came from, for error messages
+}
+
+func loadPlan(filename string) (*Plan, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan Plan
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&plan); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	switch plan.Version {
+	case 0:
+		return nil, fmt.Errorf("version is required (this build reads version %d)", planVersion)
+	case planVersion:
+	default:
+		return nil, fmt.Errorf("unsupported version %d (this build reads version %d)", plan.Version, planVersion)
+	}
+
+	plan.dir = filepath.Dir(filename)
+	return &plan, nil
+}
+
+// path resolves a file named in the plan relative to the plan file
+func (p *Plan) path(name string) string {
+	if name == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(p.dir, name)
+}
+
+// selectedEnvironment returns the profile named by environment, or nil when
+// the plan doesn't select one
+func (p *Plan) selectedEnvironment() (*PlanEnvironment, error) {
+	if p.Environment == "" {
+		return nil, nil
+	}
+	env, ok := p.Environments[p.Environment]
+	if !ok {
+		return nil, fmt.Errorf("environment %q is not defined in environments", p.Environment)
+	}
+	return &env, nil
+}
+
+// settings returns the flag values the plan sets. Secrets are looked up in
+// the environment only for flags the command line leaves unset.
+func (p *Plan) settings(changed func(flag string) bool) ([]planSetting, error) {
+	var settings []planSetting
+	var errs []error
+
+	str := func(field, flag, value string) {
+		if value != "" {
+			settings = append(settings, planSetting{flag, value, field})
+		}
+	}
+	file := func(field, flag, value string) {
+		str(field, flag, p.path(value))
+	}
+	secret := func(field, flag, env string) {
+		if env == "" || changed(flag) {
+			return
+		}
+		value, ok := os.LookupEnv(env)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: environment variable %s is not set", field, env))
+			return
+		}
+		str(field, flag, value)
+	}
+	boolean := func(field, flag string, value *bool) {
+		if value != nil {
+			str(field, flag, strconv.FormatBool(*value))
+		}
+	}
+	integer := func(field, flag string, value *int) {
+		if value != nil {
+			str(field, flag, strconv.Itoa(*value))
+		}
+	}
+	list := func(field, flag string, values []string) {
+		if len(values) > 0 {
+			str(field, flag, csvLine(values))
+		}
+	}
+	mapping := func(field, flag string, values map[string]string, resolve func(string) string) {
+		pairs := make([]string, 0, len(values))
+		for key, value := range values {
+			pairs = append(pairs, key+"="+resolve(value))
+		}
+		sort.Strings(pairs)
+		list(field, flag, pairs)
+	}
+	same := func(value string) string { return value }
+
+	// The selected environment profile overrides the plan-wide settings
+	env, err := p.selectedEnvironment()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if env == nil {
+		env = &PlanEnvironment{}
+	}
+	envField := "environments." + p.Environment + "."
+
+	file("kong.file", "file", p.Kong.File)
+	if env.URL != "" {
+		str(envField+"url", "url", env.URL)
+	} else {
+		str("url", "url", p.URL)
+	}
+
+	list("filters.services", "service", p.Filters.Services)
+	list("filters.routes", "route", p.Filters.Routes)
+	boolean("filters.test_auth", "test-auth", p.Filters.TestAuth)
+	boolean("filters.test_unauth", "test-unauth", p.Filters.TestUnauth)
+	str("filters.changed_from", "changed-from", p.Filters.ChangedFrom)
+
+	c := p.Credentials
+	if env.TokenEnv != "" {
+		secret(envField+"token_env", "token", env.TokenEnv)
+	} else {
+		secret("credentials.token_env", "token", c.TokenEnv)
+	}
+	secret("credentials.rate_limit_token_env", "rate-limit-token", c.RateLimitTokenEnv)
+	str("credentials.oauth.token_url", "oauth-token-url", c.OAuth.TokenURL)
+	str("credentials.oauth.grant", "oauth-grant", c.OAuth.Grant)
+	str("credentials.oauth.client_id", "oauth-client-id", c.OAuth.ClientID)
+	secret("credentials.oauth.client_secret_env", "oauth-client-secret", c.OAuth.ClientSecretEnv)
+	str("credentials.oauth.username", "oauth-username", c.OAuth.Username)
+	secret("credentials.oauth.password_env", "oauth-password", c.OAuth.PasswordEnv)
+	str("credentials.oauth.audience", "oauth-audience", c.OAuth.Audience)
+	str("credentials.oauth.scope", "oauth-scope", c.OAuth.Scope)
+	mapping("credentials.oauth.audience_map", "oauth-audience-map", c.OAuth.AudienceMap, same)
+	mapping("credentials.oauth.scope_map", "oauth-scope-map", c.OAuth.ScopeMap, same)
+	file("credentials.client_cert", "client-cert", c.ClientCert)
+	file("credentials.client_key", "client-key", c.ClientKey)
+	mapping("credentials.client_cert_map", "client-cert-map", c.ClientCertMap, p.path)
+	mapping("credentials.client_key_map", "client-key-map", c.ClientKeyMap, p.path)
+	file("credentials.ca_cert", "ca-cert", c.CACert)
+	str("credentials.server_name", "server-name", c.ServerName)
+	boolean("credentials.insecure_skip_verify", "insecure-skip-verify", c.InsecureSkipVerify)
+
+	file("fixtures", "fixtures", p.Fixtures)
+	mapping("openapi", "openapi", p.OpenAPI, p.path)
+
+	e := p.Expectations
+	str("expectations.client_ip", "client-ip", e.ClientIP)
+	boolean("expectations.plugin_probes", "plugin-probes", e.PluginProbes)
+	boolean("expectations.assert_upstream_path", "assert-upstream-path", e.AssertUpstreamPath)
+	mapping("expectations.slo", "slo", e.SLO, same)
+	str("expectations.slo_default", "slo-default", e.SLODefault)
+	if e.SLOPercentile != nil {
+		str("expectations.slo_percentile", "slo-percentile", strconv.FormatFloat(*e.SLOPercentile, 'f', -1, 64))
+	}
+	file("expectations.baseline", "baseline", e.Baseline)
+	if e.LatencyRegression != nil {
+		str("expectations.latency_regression", "latency-regression", strconv.FormatFloat(*e.LatencyRegression, 'f', -1, 64))
+	}
+	str("expectations.latency_regression_min", "latency-regression-min", e.LatencyRegressionMin)
+
+	k := p.Checks
+	boolean("checks.auth_matrix", "auth-matrix", k.AuthMatrix)
+	str("checks.invalid_token", "invalid-token", k.InvalidToken)
+	boolean("checks.cors", "cors", k.CORS)
+	str("checks.cors_foreign_origin", "cors-foreign-origin", k.CORSForeignOrigin)
+	boolean("checks.bypass_probes", "bypass-probes", k.BypassProbes)
+	boolean("checks.host_probes", "host-probes", k.HostProbes)
+	boolean("checks.negative", "negative", k.Negative)
+	boolean("checks.rate_limit_test", "rate-limit-test", k.RateLimitTest)
+	integer("checks.rate_limit_max", "rate-limit-max", k.RateLimitMax)
+	boolean("checks.trusted_xff", "trusted-xff", k.TrustedXFF)
+
+	l := p.Limits
+	if env.MaxRequests != nil {
+		integer(envField+"max_requests", "max", env.MaxRequests)
+	} else {
+		integer("limits.max_requests", "max", l.MaxRequests)
+	}
+	str("limits.deadline", "deadline", l.Deadline)
+	if env.Delay != "" {
+		str(envField+"delay", "delay", env.Delay)
+	} else {
+		str("limits.delay", "delay", l.Delay)
+	}
+	str("limits.timeout", "timeout", l.Timeout)
+	str("limits.dial_timeout", "dial-timeout", l.DialTimeout)
+	str("limits.tls_timeout", "tls-timeout", l.TLSTimeout)
+	str("limits.header_timeout", "header-timeout", l.HeaderTimeout)
+	integer("limits.max_attempts", "max-attempts", l.MaxAttempts)
+	str("limits.retry_backoff", "retry-backoff", l.RetryBackoff)
+	str("limits.retry_max_backoff", "retry-max-backoff", l.RetryMaxBackoff)
+	if len(l.RetryStatus) > 0 {
+		statuses := make([]string, len(l.RetryStatus))
+		for i, status := range l.RetryStatus {
+			statuses[i] = strconv.Itoa(status)
+		}
+		str("limits.retry_status", "retry-status", strings.Join(statuses, ","))
+	}
+	list("limits.retry_on", "retry-on", l.RetryOn)
+	boolean("limits.retry_non_idempotent", "retry-non-idempotent", l.RetryAnyMethod)
+	boolean("limits.keep_alive", "keep-alive", l.KeepAlive)
+	integer("limits.max_idle_conns", "max-idle-conns", l.MaxIdleConns)
+	boolean("limits.http2", "http2", l.HTTP2)
+	str("limits.proxy", "proxy", l.Proxy)
+	str("limits.unix_socket", "unix-socket", l.UnixSocket)
+
+	str("safety.mode", "safe-mode", p.Safety.Mode)
+	list("safety.deny_paths", "deny-path", p.Safety.DenyPaths)
+
+	boolean("reporters.verbose", "verbose", p.Reporters.Verbose)
+	file("reporters.coverage_json", "coverage-json", p.Reporters.CoverageJSON)
+	file("reporters.coverage_html", "coverage-html", p.Reporters.CoverageHTML)
+	file("reporters.save_baseline", "save-baseline", p.Reporters.SaveBaseline)
+
+	return settings, errors.Join(errs...)
+}
+
+// csvLine joins values the way pflag parses slice and map flags
+func csvLine(values []string) string {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Write(values)
+	w.Flush()
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// applyPlan sets every flag the plan configures and the command line does not
+func applyPlan(plan *Plan, flags *pflag.FlagSet) error {
+	settings, err := plan.settings(flags.Changed)
+	if err != nil {
+		return err
+	}
+	for _, s := range settings {
+		if flags.Changed(s.flag) {
+			continue
+		}
+		if err := flags.Set(s.flag, s.value); err != nil {
+			return fmt.Errorf("%s: %w", s.field, err)
+		}
+	}
+	return nil
+}
+
+// validatePlan checks a plan against everything it refers to, returning
+// problems that would stop a run and warnings about the current environment
+func validatePlan(plan *Plan) (problems, warnings []string) {
+	// Parse every value with the flag it sets, in a scratch copy of the
+	// command line so nothing leaks into this process
+	settings, err := plan.settings(func(string) bool { return false })
+	if err != nil {
+		for _, e := range strings.Split(err.Error(), "\n") {
+			if strings.Contains(e, "environment variable") {
+				warnings = append(warnings, e)
+			} else {
+				problems = append(problems, e)
+			}
+		}
+	}
+	scratch := pflag.NewFlagSet("plan", pflag.ContinueOnError)
+	pflag.CommandLine.VisitAll(func(f *pflag.Flag) {
+		scratch.Var(newScratchValue(f.Value), f.Name, f.Usage)
+	})
+	for _, s := range settings {
+		if err := scratch.Set(s.flag, s.value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", s.field, err))
+		}
+	}
+
+	for name, env := range plan.Environments {
+		field := "environments." + name + "."
+		if env.URL == "" {
+			problems = append(problems, field+"url is required")
+		}
+		for route, methods := range env.AllowWrites {
+			for _, method := range methods {
+				if !slices.Contains(httpMethods, strings.ToUpper(method)) {
+					problems = append(problems, fmt.Sprintf("%sallow_writes.%s: %q is not an HTTP method", field, route, method))
+				}
+			}
+		}
+		if name == plan.Environment {
+			// Checked above with the flags the profile sets
+			continue
+		}
+		if _, err := time.ParseDuration(env.Delay); env.Delay != "" && err != nil {
+			problems = append(problems, fmt.Sprintf("%sdelay: %v", field, err))
+		}
+		if env.MaxRequests != nil && *env.MaxRequests < 0 {
+			problems = append(problems, field+"max_requests must not be negative")
+		}
+	}
+	urls := []string{plan.URL}
+	for _, env := range plan.Environments {
+		urls = append(urls, env.URL)
+	}
+	for _, u := range urls {
+		if parsed, err := url.Parse(u); u != "" && (err != nil || parsed.Scheme == "" || parsed.Host == "") {
+			problems = append(problems, fmt.Sprintf("%q is not an absolute URL", u))
+		}
+	}
+
+	if err := checkSafeMode(plan.Safety.Mode); err != nil {
+		problems = append(problems, fmt.Sprintf("safety.mode: %v", err))
+	}
+	if _, err := compileDenyPaths(plan.Safety.DenyPaths); err != nil {
+		problems = append(problems, fmt.Sprintf("safety.deny_paths: %v", err))
+	}
+
+	for _, file := range plan.files() {
+		if _, err := os.Stat(file.path); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", file.field, err))
+		}
+	}
+
+	kongFile := plan.path(plan.Kong.File)
+	if kongFile == "" {
+		kongFile = "kong.yaml"
+	}
+	config, err := readKongConfig(kongFile)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("kong.file: %v", err))
+		return problems, warnings
+	}
+	services, routes := make(map[string]bool), make(map[string]bool)
+	for _, service := range config.Services {
+		services[service.Name] = true
+		for _, route := range service.Routes {
+			routes[route.Name] = true
+		}
+	}
+	for _, name := range plan.Filters.Services {
+		if !services[name] {
+			problems = append(problems, fmt.Sprintf("filters.services: no service named %q in %s", name, kongFile))
+		}
+	}
+	for _, name := range plan.Filters.Routes {
+		if !routes[name] {
+			problems = append(problems, fmt.Sprintf("filters.routes: no route named %q in %s", name, kongFile))
+		}
+	}
+	for profile, env := range plan.Environments {
+		for name := range env.AllowWrites {
+			if !routes[name] {
+				problems = append(problems, fmt.Sprintf("environments.%s.allow_writes: no route named %q in %s", profile, name, kongFile))
+			}
+		}
+	}
+
+	slices.Sort(problems)
+	return problems, warnings
+}
+
+// planFileRef is a file the plan reads
+type planFileRef struct {
+	field string
+	path  string
+}
+
+// files returns every input file the plan names
+func (p *Plan) files() []planFileRef {
+	refs := []planFileRef{
+		{"fixtures", p.Fixtures},
+		{"expectations.baseline", p.Expectations.Baseline},
+		{"credentials.client_cert", p.Credentials.ClientCert},
+		{"credentials.client_key", p.Credentials.ClientKey},
+		{"credentials.ca_cert", p.Credentials.CACert},
+	}
+	for name, file := range p.OpenAPI {
+		refs = append(refs, planFileRef{"openapi." + name, file})
+	}
+	for name, file := range p.Credentials.ClientCertMap {
+		refs = append(refs, planFileRef{"credentials.client_cert_map." + name, file})
+	}
+	for name, file := range p.Credentials.ClientKeyMap {
+		refs = append(refs, planFileRef{"credentials.client_key_map." + name, file})
+	}
+
+	var files []planFileRef
+	for _, ref := range refs {
+		if ref.path != "" {
+			files = append(files, planFileRef{ref.field, p.path(ref.path)})
+		}
+	}
+	return files
+}
+
+// scratchValue parses like a flag's value without changing it
+type scratchValue struct {
+	typ   string
+	parse func(string) error
+}
+
+func newScratchValue(v pflag.Value) pflag.Value {
+	typ := v.Type()
+	s := &scratchValue{typ: typ, parse: func(string) error { return nil }}
+	switch typ {
+	case "bool":
+		s.parse = func(value string) error { _, err := strconv.ParseBool(value); return err }
+	case "int":
+		s.parse = func(value string) error { _, err := strconv.Atoi(value); return err }
+	case "float64":
+		s.parse = func(value string) error { _, err := strconv.ParseFloat(value, 64); return err }
+	case "duration":
+		s.parse = func(value string) error { _, err := time.ParseDuration(value); return err }
+	case "intSlice":
+		s.parse = func(value string) error {
+			for _, item := range strings.Split(value, ",") {
+				if _, err := strconv.Atoi(item); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	return s
+}
+
+func (s *scratchValue) String() string         { return "" }
+func (s *scratchValue) Set(value string) error { return s.parse(value) }
+func (s *scratchValue) Type() string           { return s.typ }
+
+// runValidatePlan checks test plan files without running them
+func runValidatePlan(args []string) int {
+	flags := pflag.NewFlagSet("validate-plan", pflag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Println("Usage: kong-route-tester validate-plan PLAN...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	status := 0
+	for _, filename := range flags.Args() {
+		plan, err := loadPlan(filename)
+		if err != nil {
+			fmt.Printf("✗ %s: %v\n", filename, err)
+			status = 1
+			continue
+		}
+
+		problems, warnings := validatePlan(plan)
+		if len(problems) > 0 {
+			fmt.Printf("✗ %s:\n", filename)
+			status = 1
+		} else {
+			fmt.Printf("✓ %s (version %d)\n", filename, plan.Version)
+		}
+		for _, problem := range problems {
+			fmt.Printf("  - %s\n", problem)
+		}
+		for _, warning := range warnings {
+			fmt.Printf("  warning: %s\n", warning)
+		}
+	}
+	return status
+}
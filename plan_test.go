@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func writePlan(t *testing.T, content string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "plan.yaml")
+	if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestLoadPlan(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{name: "valid", content: "version: 1\nurl: http://localhost:8080\n"},
+		{name: "missing version", content: "url: http://localhost:8080\n", err: "version is required"},
+		{name: "future version", content: "version: 2\n", err: "unsupported version 2"},
+		{name: "unknown field", content: "version: 1\nchecks:\n  corz: true\n", err: "field corz not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadPlan(writePlan(t, tt.content))
+			if tt.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestApplyPlan(t *testing.T) {
+	t.Setenv("PLAN_TEST_TOKEN", "secret")
+	plan, err := loadPlan(writePlan(t, `version: 1
+environment: staging
+environments:
+  staging:
+    url: https://staging.example.com
+fixtures: fixtures.yaml
+credentials:
+  token_env: PLAN_TEST_TOKEN
+filters:
+  services: [users, "billing,legacy"]
+checks:
+  cors: true
+limits:
+  delay: 1s
+reporters:
+  coverage_json: out/coverage.json
+  coverage_html: out/coverage.html
+  save_baseline: out/baseline.json
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	url := flags.String("url", "", "")
+	fixtures := flags.String("fixtures", "", "")
+	token := flags.String("token", "", "")
+	services := flags.StringSlice("service", nil, "")
+	cors := flags.Bool("cors", false, "")
+	delay := flags.Duration("delay", 0, "")
+	coverageJSON := flags.String("coverage-json", "", "")
+	coverageHTML := flags.String("coverage-html", "", "")
+	saveBaseline := flags.String("save-baseline", "", "")
+	if err := flags.Parse([]string{"--delay", "5s"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := applyPlan(plan, flags); err != nil {
+		t.Fatal(err)
+	}
+	if *url != "https://staging.example.com" || *token != "secret" || !*cors {
+		t.Errorf("plan not applied: url=%q token=%q cors=%v", *url, *token, *cors)
+	}
+	if *fixtures != filepath.Join(plan.dir, "fixtures.yaml") {
+		t.Errorf("expected fixtures relative to the plan, got %q", *fixtures)
+	}
+	for _, output := range []string{*coverageJSON, *coverageHTML, *saveBaseline} {
+		if filepath.Dir(output) != filepath.Join(plan.dir, "out") {
+			t.Errorf("expected reports relative to the plan, got %q", output)
+		}
+	}
+	if !slices.Equal(*services, []string{"users", "billing,legacy"}) {
+		t.Errorf("services = %q", *services)
+	}
+	if delay.String() != "5s" {
+		t.Errorf("expected the --delay flag to override the plan, got %s", delay)
+	}
+}
+
+func TestApplyPlanEnvironment(t *testing.T) {
+	t.Setenv("PLAN_TEST_TOKEN", "plan")
+	t.Setenv("PLAN_TEST_PROD_TOKEN", "prod")
+	plan, err := loadPlan(writePlan(t, `version: 1
+url: http://localhost:8000
+environment: dev
+environments:
+  dev:
+    url: https://api.dev.example.com
+  prod:
+    url: https://api.example.com
+    token_env: PLAN_TEST_PROD_TOKEN
+    delay: 2s
+    max_requests: 0
+    production: true
+credentials:
+  token_env: PLAN_TEST_TOKEN
+limits:
+  delay: 100ms
+  max_requests: 500
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		env   string
+		url   string
+		token string
+		delay string
+		max   int
+	}{
+		{"", "https://api.dev.example.com", "plan", "100ms", 500},
+		{"prod", "https://api.example.com", "prod", "2s", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			p := *plan
+			if tt.env != "" {
+				p.Environment = tt.env
+			}
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			url := flags.String("url", "", "")
+			token := flags.String("token", "", "")
+			delay := flags.Duration("delay", 0, "")
+			max := flags.Int("max", -1, "")
+			if err := applyPlan(&p, flags); err != nil {
+				t.Fatal(err)
+			}
+			if *url != tt.url || *token != tt.token || delay.String() != tt.delay || *max != tt.max {
+				t.Errorf("got url=%q token=%q delay=%s max=%d", *url, *token, delay, *max)
+			}
+		})
+	}
+}
+
+func TestValidatePlan(t *testing.T) {
+	plan, err := loadPlan(writePlan(t, `version: 1
+kong:
+  file: `+filepath.Join(mustGetwd(t), "kong.yaml")+`
+environment: prod
+environments:
+  dev:
+    url: api.dev.example.com
+  production:
+    url: https://api.example.com
+    delay: later
+    production: true
+    allow_writes:
+      no-such-route: [FETCH]
+filters:
+  routes: [no-such-route]
+credentials:
+  token_env: PLAN_TEST_UNSET_TOKEN
+fixtures: missing.yaml
+limits:
+  delay: soon
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	problems, warnings := validatePlan(plan)
+	want := []string{
+		`"api.dev.example.com" is not an absolute URL`,
+		`environment "prod" is not defined in environments`,
+		`environments.production.allow_writes.no-such-route: "FETCH" is not an HTTP method`,
+		`environments.production.allow_writes: no route named "no-such-route"`,
+		`environments.production.delay:`,
+		`filters.routes: no route named "no-such-route"`,
+		"fixtures: stat",
+		`limits.delay:`,
+	}
+	for _, w := range want {
+		if !slices.ContainsFunc(problems, func(p string) bool { return strings.Contains(p, w) }) {
+			t.Errorf("expected a problem containing %q, got %q", w, problems)
+		}
+	}
+	if len(problems) != len(want) {
+		t.Errorf("expected %d problems, got %q", len(want), problems)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "PLAN_TEST_UNSET_TOKEN") {
+		t.Errorf("expected a warning about the unset token variable, got %q", warnings)
+	}
+}
+
+func TestExamplePlanIsValid(t *testing.T) {
+	t.Setenv("KONG_ROUTE_TESTER_TOKEN", "example")
+	plan, err := loadPlan("plan.yaml.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems, warnings := validatePlan(plan); len(problems)+len(warnings) > 0 {
+		t.Errorf("example plan: %q %q", problems, warnings)
+	}
+}
+
+// TestPlanSchemaMatchesPlan keeps the JSON Schema in step with the Plan type
+func TestPlanSchemaMatchesPlan(t *testing.T) {
+	data, err := os.ReadFile("plan.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	var compare func(prefix string, typ reflect.Type, node map[string]interface{})
+	compare = func(prefix string, typ reflect.Type, node map[string]interface{}) {
+		properties, _ := node["properties"].(map[string]interface{})
+		fields := make(map[string]bool)
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if name == "" {
+				continue
+			}
+			fields[name] = true
+			property, ok := properties[name].(map[string]interface{})
+			if !ok {
+				t.Errorf("schema is missing %s%s", prefix, name)
+				continue
+			}
+			switch {
+			case field.Type.Kind() == reflect.Struct:
+				compare(prefix+name+".", field.Type, property)
+			case field.Type.Kind() == reflect.Map && field.Type.Elem().Kind() == reflect.Struct:
+				entry, _ := property["additionalProperties"].(map[string]interface{})
+				compare(prefix+name+".*.", field.Type.Elem(), entry)
+			}
+		}
+		for name := range properties {
+			if !fields[name] {
+				t.Errorf("schema has %s%s, which the Plan type lacks", prefix, name)
+			}
+		}
+	}
+	compare("", reflect.TypeOf(Plan{}), schema)
+}
+
+func mustGetwd(t *testing.T) string {
+	t.Helper()
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danpilch/kong-route-tester/kong"
+	"github.com/spf13/pflag"
+)
+
+// Rate limit verification flags
+var (
+	rateLimitTest = pflag.Bool("rate-limit-test", false, "Exhaust the limit of routes with the rate-limiting plugin and check the 429 and RateLimit headers")
+	rateLimitMax  = pflag.Int("rate-limit-max", 100, "Largest limit the rate limit test will exhaust; routes with higher limits are skipped")
+	trustedXFF    = pflag.Bool("trusted-xff", false, "Kong trusts X-Forwarded-For from the tester (trusted_ips), so rate limit tests by IP send a unique client address")
+	rateLimitKey  = pflag.String("rate-limit-token", "", "Bearer token of a consumer reserved for rate limit tests, giving consumer limits a counter of their own")
+)
+
+// RateLimitCheck is the outcome of exhausting one route's rate limit
+type RateLimitCheck struct {
+	Service string
+	Route   string
+	Path    string
+	Method  string
+	Period  string
+	Limit   int
+	LimitBy string
+
+	// Identity is what gave the requests a counter of their own, empty when
+	// the counter is shared with other traffic
+	Identity string
+
+	// Verified is true when the burst started on a full counter, so the
+	// request count and countdown could be checked
+	Verified bool
+
+	Sent     int
+	Rejected int // Request number of the first 429, 0 if none
+	Skipped  string
+	Error    error
+	Failures []string
+}
+
+// rateLimitedRoute is a route with the rate-limiting plugin
+type rateLimitedRoute struct {
+	target requestTarget
+	config map[string]interface{}
+	router *kong.Router
+}
+
+// rateLimitedRoutes returns every route with the rate-limiting plugin. Gateway
+// self-test services are included since they usually exist for this check.
+func rateLimitedRoutes(config *KongConfig) []rateLimitedRoute {
+	var routes []rateLimitedRoute
+	router := kong.NewRouter(config)
+	for _, service := range config.Services {
+		for _, route := range service.Routes {
+			if len(route.Paths) == 0 || !routeSelected(service, route) {
+				continue
+			}
+			hasAuth := hasAuthPlugin(route, service)
+			if (hasAuth && !*testAuth) || (!hasAuth && !*testUnauth) {
+				continue
+			}
+
+			for _, plugin := range config.EffectivePlugins(service, route) {
+				if plugin.Name != "rate-limiting" {
+					continue
+				}
+				path, params := expandRegexPathParams(route.Paths[0])
+				routes = append(routes, rateLimitedRoute{
+					target: requestTarget{
+						Service:      service,
+						Route:        route,
+						Pattern:      route.Paths[0],
+						Path:         path,
+						Params:       params,
+						Method:       preferredMethod(route),
+						RequiresAuth: hasAuth,
+						Plugins:      config.EffectivePlugins(service, route),
+					},
+					config: plugin.Config,
+					router: router,
+				})
+			}
+		}
+	}
+	return routes
+}
+
+// bindingLimit returns the limit a burst of requests exhausts first
+func bindingLimit(config map[string]interface{}) (period string, length time.Duration, limit int) {
+	for _, p := range kong.RatePeriods {
+		if l := kong.IntValue(config, p.Name, 0); l > 0 && (limit == 0 || l < limit) {
+			period, length, limit = p.Name, p.Length, l
+		}
+	}
+	return period, length, limit
+}
+
+// rateLimitIdentity is what a burst is counted against under limit_by
+type rateLimitIdentity struct {
+	header string // Sent with every request when set
+	value  string
+	path   string // Replaces the target path when set
+
+	// description names the identity in output, empty when the counter is
+	// shared with other traffic
+	description string
+
+	// reserved identities are shared with earlier tests only, so the counter
+	// is fresh when the first response reports it full
+	reserved bool
+}
+
+// separateCounter returns an identity that gives the test its own counter
+// under limit_by, or an empty identity when the counter can't be separated
+func separateCounter(config map[string]interface{}, target requestTarget, router *kong.Router) rateLimitIdentity {
+	switch kong.StringValue(config, "limit_by", "consumer") {
+	case "header":
+		if name := kong.StringValue(config, "header_name", ""); name != "" {
+			value := fmt.Sprintf("kong-route-tester-%d", time.Now().UnixNano())
+			return rateLimitIdentity{header: name, value: value, description: name + ": " + value}
+		}
+	case "ip":
+		return clientIdentity()
+	case "path":
+		if path, ok := uniqueRoutePath(router, target); ok {
+			return rateLimitIdentity{path: path, description: "path " + path}
+		}
+	case "", "consumer", "credential":
+		if !target.RequiresAuth {
+			// Without a consumer Kong counts by client address
+			return clientIdentity()
+		}
+		if *rateLimitKey != "" {
+			return rateLimitIdentity{header: "Authorization", value: "Bearer " + *rateLimitKey, description: "consumer of --rate-limit-token", reserved: true}
+		}
+	}
+	// service counters, and consumers without a reserved token, are shared
+	return rateLimitIdentity{}
+}
+
+// clientIdentity returns a unique client address, which Kong only uses when
+// it trusts the tester's X-Forwarded-For
+func clientIdentity() rateLimitIdentity {
+	if !*trustedXFF {
+		return rateLimitIdentity{}
+	}
+	ip := uniqueClientIP()
+	return rateLimitIdentity{header: "X-Forwarded-For", value: ip, description: "X-Forwarded-For: " + ip}
+}
+
+// uniqueRoutePath returns a path no other traffic uses that the router still
+// sends to the target route, replacing a path parameter or extending the path
+func uniqueRoutePath(router *kong.Router, target requestTarget) (string, bool) {
+	nonce := strconv.FormatInt(time.Now().UnixNano()%1e12, 10)
+	var candidates []string
+	for _, name := range unionKeys(target.Params, nil) {
+		if value := target.Params[name]; value != "" {
+			candidates = append(candidates, strings.Replace(target.Path, value, nonce, 1))
+		}
+	}
+	candidates = append(candidates, strings.TrimSuffix(target.Path, "/")+"/kong-route-tester-"+nonce)
+
+	for _, path := range candidates {
+		match, ok := router.Match(target.Method, routeHost(target.Route), path)
+		if ok && routeKey(match.Service, match.Route) == routeKey(target.Service, target.Route) {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// uniqueClientIP returns an address from the benchmarking range 198.18.0.0/15
+func uniqueClientIP() string {
+	n := rand.N(1 << 17)
+	return fmt.Sprintf("198.%d.%d.%d", 18+n>>16, n>>8&0xff, n&0xff)
+}
+
+// waitForWindow waits for the next fixed window when the current one is too
+// close to its end for the burst to fit
+func waitForWindow(ctx context.Context, length time.Duration, requests int) {
+	if length > time.Hour {
+		return
+	}
+	now := time.Now()
+	left := now.Truncate(length).Add(length).Sub(now)
+	if needed := time.Duration(requests) * 50 * time.Millisecond; left < needed && needed < length {
+		sleepContext(ctx, left+50*time.Millisecond)
+	}
+}
+
+// testRateLimits exhausts the limit of every rate limited route
+func testRateLimits(ctx context.Context, config *KongConfig) []RateLimitCheck {
+	var checks []RateLimitCheck
+	for _, r := range rateLimitedRoutes(config) {
+		if ctx.Err() != nil {
+			break
+		}
+		t := r.target
+		switch safetyAction("rate limit", t.Service.Name, t.Route.Name, t.Path, t.Method) {
+		case safetySkip:
+			continue
+		case safetyProbe:
+			// Exhausting a limit takes real, authenticated requests
+			skipForSafety("rate limit", t.Service.Name, t.Route.Name, t.Path, t.Method, "probe-only mode")
+			continue
+		}
+		check := checkRateLimit(ctx, r.target, r.config, r.router)
+		printRateLimitCheck(check)
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// checkRateLimit sends limit+1 requests in one window and checks that the
+// last is rejected and the RateLimit headers count down to it
+func checkRateLimit(ctx context.Context, target requestTarget, config map[string]interface{}, router *kong.Router) RateLimitCheck {
+	period, length, limit := bindingLimit(config)
+	check := RateLimitCheck{
+		Service: target.Service.Name,
+		Route:   target.Route.Name,
+		Path:    target.Path,
+		Method:  target.Method,
+		Period:  period,
+		Limit:   limit,
+		LimitBy: "consumer",
+	}
+	if limitBy := kong.StringValue(config, "limit_by", ""); limitBy != "" {
+		check.LimitBy = limitBy
+	}
+
+	switch {
+	case limit == 0:
+		check.Skipped = "no limits configured"
+		return check
+	case limit > *rateLimitMax:
+		check.Skipped = fmt.Sprintf("limit %d is above --rate-limit-max", limit)
+		return check
+	case *dryRun:
+		return check
+	}
+
+	identity := separateCounter(config, target, router)
+	check.Identity = identity.description
+	if identity.path != "" {
+		target.Path = identity.path
+		check.Path = identity.path
+	}
+
+	tmpl, err := buildRequestTemplate(target)
+	if err != nil {
+		check.Error = fmt.Errorf("rendering fixture: %w", err)
+		return check
+	}
+	client, err := clientFor(target.Service.Name, target.Route.Name, "")
+	if err != nil {
+		check.Error = err
+		return check
+	}
+
+	waitForWindow(ctx, length, limit+1)
+
+	hidden := kong.BoolValue(config, "hide_client_headers", false)
+	counter := &rateLimitCounter{period: period, length: length, limit: limit, fresh: identity.description != "" && !identity.reserved}
+	for i := 1; i <= limit+1; i++ {
+		req, err := newTargetRequest(ctx, target, tmpl, "")
+		if err != nil {
+			check.Error = err
+			return check
+		}
+		if identity.header != "" {
+			req.Header.Set(identity.header, identity.value)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			check.Error = err
+			return check
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+		resp.Body.Close()
+
+		check.Sent = i
+		if !hidden {
+			check.Failures = counter.check(check.Failures, i, resp)
+			if i == 1 && identity.reserved {
+				counter.fresh = resp.Header.Get("RateLimit-Remaining") == strconv.Itoa(limit-1)
+			}
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			check.Rejected = i
+			break
+		}
+	}
+
+	check.Verified = counter.fresh
+	switch {
+	case check.Rejected == 0:
+		check.Failures = append(check.Failures, fmt.Sprintf("no 429 after %d requests with a limit of %d per %s", check.Sent, limit, period))
+	case check.Rejected != limit+1 && counter.fresh:
+		check.Failures = append(check.Failures, fmt.Sprintf("429 after %d requests, expected after %d", check.Rejected, limit+1))
+	}
+	return check
+}
+
+// rateLimitCounter follows the RateLimit headers through a burst
+type rateLimitCounter struct {
+	period    string
+	length    time.Duration
+	limit     int
+	fresh     bool // Whether the burst has a counter of its own
+	remaining int  // Remaining reported by the previous response
+	reported  map[string]bool
+}
+
+// check compares one response's headers with the expected countdown,
+// appending failures not already reported for an earlier request
+func (c *rateLimitCounter) check(failures []string, request int, resp *http.Response) []string {
+	add := func(format string, args ...interface{}) {
+		message := fmt.Sprintf(format, args...)
+		if c.reported == nil {
+			c.reported = make(map[string]bool)
+		}
+		if !c.reported[message] {
+			c.reported[message] = true
+			failures = append(failures, fmt.Sprintf("request %d: %s", request, message))
+		}
+	}
+
+	suffix := ""
+	for _, p := range kong.RatePeriods {
+		if p.Name == c.period {
+			suffix = p.Header
+		}
+	}
+
+	if got := resp.Header.Get("X-RateLimit-Limit-" + suffix); got != strconv.Itoa(c.limit) {
+		add("expected X-RateLimit-Limit-%s %d, got %q", suffix, c.limit, got)
+	}
+	remaining, err := strconv.Atoi(resp.Header.Get("RateLimit-Remaining"))
+	if err != nil {
+		add("missing RateLimit-Remaining")
+		return failures
+	}
+	reset, err := strconv.Atoi(resp.Header.Get("RateLimit-Reset"))
+	if err != nil || reset < 0 || time.Duration(reset)*time.Second > c.length {
+		add("RateLimit-Reset %q outside the %s window", resp.Header.Get("RateLimit-Reset"), c.period)
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if remaining != 0 {
+			add("429 with RateLimit-Remaining %d", remaining)
+		}
+		if retry, err := strconv.Atoi(resp.Header.Get("Retry-After")); err != nil || retry <= 0 {
+			add("429 without a positive Retry-After")
+		}
+		return failures
+	}
+
+	expected := c.remaining - 1
+	if request == 1 {
+		expected = c.limit - 1
+	}
+	if remaining != expected && (request > 1 || c.fresh) {
+		add("expected RateLimit-Remaining %d, got %d", expected, remaining)
+	}
+	c.remaining = remaining
+	return failures
+}
+
+func printRateLimitCheck(check RateLimitCheck) {
+	passed := check.Error == nil && len(check.Failures) == 0
+	if !*verbose && passed && !*dryRun && check.Skipped == "" {
+		return
+	}
+
+	status := "✓"
+	switch {
+	case *dryRun, check.Skipped != "":
+		status = "○"
+	case !passed:
+		status = "✗"
+	}
+
+	fmt.Printf("%s %-30s %-40s %-6s %3d [RATE:%d/%s]", status, check.Service, truncate(check.Path, 40), check.Method, check.Rejected, check.Limit, check.Period)
+	switch {
+	case check.Skipped != "":
+		fmt.Printf(" - skipped: %s", check.Skipped)
+	case *dryRun:
+		fmt.Printf(" - DRY RUN would send %d requests", check.Limit+1)
+	case check.Error != nil:
+		fmt.Printf(" ERROR: %v", check.Error)
+	case len(check.Failures) > 0:
+		fmt.Printf(" - %s", truncate(check.Failures[0], 60))
+	case !check.Verified:
+		fmt.Printf(" - 429 after %d requests, shared counter, not verified", check.Rejected)
+	default:
+		fmt.Printf(" - 429 after %d requests", check.Rejected)
+	}
+	fmt.Println()
+}
+
+func printRateLimitSummary(checks []RateLimitCheck) {
+	if !*rateLimitTest || *dryRun || len(checks) == 0 {
+		return
+	}
+
+	fmt.Printf("\nRate Limits (%d routes):\n", len(checks))
+	for _, check := range checks {
+		label := fmt.Sprintf("  %-30s %d/%s by %s", check.Route, check.Limit, check.Period, check.LimitBy)
+		switch {
+		case check.Skipped != "":
+			fmt.Printf("%s: skipped, %s\n", label, check.Skipped)
+		case check.Error != nil:
+			fmt.Printf("%s: error after %d requests: %v\n", label, check.Sent, check.Error)
+		case len(check.Failures) > 0:
+			fmt.Printf("%s: %d problems\n", label, len(check.Failures))
+			for _, failure := range check.Failures {
+				fmt.Printf("    - %s\n", failure)
+			}
+		default:
+			fmt.Printf("%s: 429 after %d requests\n", label, check.Rejected)
+		}
+		if !check.Verified && check.Skipped == "" && check.Error == nil && check.Rejected > 0 {
+			fmt.Printf("    (shared counter, not verified: %d requests were counted before the test)\n", check.Limit+1-check.Rejected)
+		}
+	}
+}
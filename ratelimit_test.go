@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/danpilch/kong-route-tester/kong"
+	"github.com/danpilch/kong-route-tester/mock"
+)
+
+func TestBindingLimit(t *testing.T) {
+	period, length, limit := bindingLimit(map[string]interface{}{"hour": 100, "minute": 10, "second": 20})
+	if period != "minute" || length.Minutes() != 1 || limit != 10 {
+		t.Errorf("bindingLimit = %s %s %d, want minute 1m0s 10", period, length, limit)
+	}
+}
+
+// TestTestRateLimits exhausts limits on the mock gateway, which counts
+// requests per limit_by identity like Kong and trusts X-Forwarded-For
+func TestTestRateLimits(t *testing.T) {
+	limited := func(name string, config map[string]interface{}) Route {
+		return Route{Name: name, Paths: []string{"/_test/" + name}, Methods: []string{"GET"}, Plugins: []Plugin{{Name: "rate-limiting", Config: config}}}
+	}
+	consumer := limited("by-consumer", map[string]interface{}{"minute": 2})
+	consumer.Plugins = append(consumer.Plugins, Plugin{Name: "auth"})
+	service := Service{Name: "gateway-test", URL: "http://127.0.0.1:9001", Routes: []Route{
+		limited("by-ip", map[string]interface{}{"minute": 3, "limit_by": "ip"}),
+		limited("by-header", map[string]interface{}{"minute": 2, "limit_by": "header", "header_name": "X-Tenant"}),
+		limited("by-path", map[string]interface{}{"minute": 2, "limit_by": "path"}),
+		limited("by-service", map[string]interface{}{"minute": 2, "limit_by": "service"}),
+		limited("hidden", map[string]interface{}{"minute": 2, "limit_by": "ip", "hide_client_headers": true}),
+		limited("too-high", map[string]interface{}{"hour": 1000}),
+		consumer,
+	}}
+	config := &kong.Config{Services: []Service{service}}
+
+	server := httptest.NewServer(mock.NewGateway(config, mock.Options{}))
+	defer server.Close()
+
+	originalURL := *baseURL
+	*baseURL, *trustedXFF, *rateLimitKey = server.URL, true, "rate-limit-token"
+	defer func() { *baseURL, *trustedXFF, *rateLimitKey = originalURL, false, "" }()
+
+	checks := testRateLimits(context.Background(), config)
+	if len(checks) != 7 {
+		t.Fatalf("expected 7 rate limited routes, got %d", len(checks))
+	}
+
+	want := map[string]int{"by-ip": 4, "by-header": 3, "by-path": 3, "by-service": 3, "hidden": 3, "too-high": 0, "by-consumer": 3}
+	for _, check := range checks {
+		if check.Error != nil || len(check.Failures) > 0 {
+			t.Errorf("%s: unexpected problems %v %v", check.Route, check.Error, check.Failures)
+		}
+		if check.Rejected != want[check.Route] {
+			t.Errorf("%s: 429 after %d requests, want %d", check.Route, check.Rejected, want[check.Route])
+		}
+		if verified := check.Route != "by-service" && check.Route != "too-high"; check.Verified != verified {
+			t.Errorf("%s: verified = %v, want %v", check.Route, check.Verified, verified)
+		}
+	}
+	if path := checks[2]; path.Path == "/_test/by-path" {
+		t.Errorf("expected a unique path for the path counter, got %s", path.Path)
+	}
+
+	// A second run gets fresh path counters, but shares the exhausted service
+	// counter and the reserved consumer's, which are reported as unverified
+	again := testRateLimits(context.Background(), config)
+	if path := again[2]; path.Rejected != 3 || !path.Verified {
+		t.Errorf("expected a fresh path counter, got %+v", path)
+	}
+	for _, check := range []RateLimitCheck{again[3], again[6]} {
+		if check.Rejected != 1 || check.Verified || len(check.Failures) > 0 {
+			t.Errorf("%s: expected an immediate, unverified 429, got %+v", check.Route, check)
+		}
+	}
+}
+
+// TestTestRateLimitsUntrustedXFF checks that client addresses aren't varied
+// unless Kong trusts them, so the shared counter isn't held to a fresh count
+func TestTestRateLimitsUntrustedXFF(t *testing.T) {
+	route := Route{Name: "by-ip", Paths: []string{"/_test/by-ip"}, Methods: []string{"GET"},
+		Plugins: []Plugin{{Name: "rate-limiting", Config: map[string]interface{}{"minute": 3, "limit_by": "ip"}}}}
+	config := &kong.Config{Services: []Service{{Name: "gateway-test", URL: "http://127.0.0.1:9001", Routes: []Route{route}}}}
+
+	server := httptest.NewServer(mock.NewGateway(config, mock.Options{}))
+	defer server.Close()
+
+	originalURL := *baseURL
+	*baseURL = server.URL
+	defer func() { *baseURL = originalURL }()
+
+	for run := 1; run <= 2; run++ {
+		check := testRateLimits(context.Background(), config)[0]
+		if check.Identity != "" || check.Verified || len(check.Failures) > 0 {
+			t.Errorf("run %d: expected an unverified shared counter without failures, got %+v", run, check)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// Retry policy flags
+var (
+	maxAttempts     = pflag.Int("max-attempts", 1, "Maximum attempts per request, including the first")
+	retryBackoffMin = pflag.Duration("retry-backoff", 200*time.Millisecond, "Backoff before the first retry, doubled for each further retry")
+	retryBackoffMax = pflag.Duration("retry-max-backoff", 5*time.Second, "Upper bound on the backoff between retries")
+	retryStatuses   = pflag.IntSlice("retry-status", []int{502, 503, 504}, "Status codes that are retried")
+	retryErrors     = pflag.StringSlice("retry-on", []string{"timeout", "connection-reset", "connection-refused", "eof"}, "Error classes that are retried")
+	retryAnyMethod  = pflag.Bool("retry-non-idempotent", false, "Also retry POST, PATCH and DELETE, which may repeat a write that reached the upstream")
+)
+
+// retryableMethods can be repeated without changing the outcome of a request
+// that already reached the upstream
+var retryableMethods = []string{"GET", "HEAD", "OPTIONS", "PUT"}
+
+// Attempt records the outcome of a single try at a request
+type Attempt struct {
+	StatusCode int
+	Error      error
+	Duration   time.Duration
+}
+
+// errorClass buckets transport errors into the classes accepted by --retry-on
+func errorClass(err error) string {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "connection-reset"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection-refused"
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "eof"
+	}
+	return "other"
+}
+
+// shouldRetry reports whether the outcome of the last attempt is transient
+func shouldRetry(result TestResult) bool {
+	if result.CertMode != "" {
+		// Gateways often reject client certificates by dropping the connection
+		return false
+	}
+	if !*retryAnyMethod && !slices.Contains(retryableMethods, strings.ToUpper(result.Method)) {
+		return false
+	}
+	if result.Error != nil {
+		return slices.Contains(*retryErrors, errorClass(result.Error))
+	}
+	if result.ExpectedStatus != 0 && result.StatusCode == result.ExpectedStatus {
+		// A plugin such as request-termination answered as configured
+		return false
+	}
+	return slices.Contains(*retryStatuses, result.StatusCode)
+}
+
+// retryBackoff returns how long to wait after the given attempt, using
+// exponential backoff with equal jitter
+func retryBackoff(attempt int) time.Duration {
+	backoff := *retryBackoffMin
+	for i := 1; i < attempt; i++ {
+		if backoff > *retryBackoffMax/2 {
+			// Stop doubling at the cap, before the backoff can overflow
+			backoff = *retryBackoffMax
+			break
+		}
+		backoff *= 2
+	}
+	if backoff > *retryBackoffMax || backoff <= 0 {
+		backoff = *retryBackoffMax
+	}
+
+	half := backoff / 2
+	if half <= 0 {
+		return backoff
+	}
+	return half + rand.N(half)
+}
+
+// describeHistory formats the outcome of each attempt, e.g. "502 -> 502 -> 200"
+func describeHistory(history []Attempt) string {
+	var outcomes []string
+	for _, attempt := range history {
+		if attempt.Error != nil {
+			outcomes = append(outcomes, errorClass(attempt.Error))
+		} else {
+			outcomes = append(outcomes, fmt.Sprint(attempt.StatusCode))
+		}
+	}
+	return strings.Join(outcomes, " -> ")
+}
+
+// retriedResults splits the retried requests into those whose final attempt
+// passed and those that still failed
+func retriedResults(results []TestResult) (flaky, failed []TestResult) {
+	for _, result := range results {
+		if result.Attempts <= 1 {
+			continue
+		}
+		if resultPassed(result) {
+			flaky = append(flaky, result)
+		} else {
+			failed = append(failed, result)
+		}
+	}
+	return flaky, failed
+}
+
+func printRetrySummary(results []TestResult) {
+	flaky, failed := retriedResults(results)
+
+	if len(flaky) > 0 {
+		fmt.Println("\nFlaky Routes (recovered after retries):")
+		for _, result := range flaky {
+			fmt.Printf("  - %s %s (%s): %s\n", result.Method, result.Path, result.Service, describeHistory(result.History))
+		}
+	}
+
+	if len(failed) > 0 {
+		fmt.Println("\nFailed After Retries:")
+		for _, result := range failed {
+			fmt.Printf("  - %s %s (%s): %s\n", result.Method, result.Path, result.Service, describeHistory(result.History))
+		}
+	}
+}
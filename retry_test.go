@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{name: "no error", err: nil, expected: ""},
+		{name: "deadline", err: context.DeadlineExceeded, expected: "timeout"},
+		{name: "wrapped reset", err: &url.Error{Op: "Get", URL: "http://x", Err: syscall.ECONNRESET}, expected: "connection-reset"},
+		{name: "refused", err: fmt.Errorf("dial: %w", syscall.ECONNREFUSED), expected: "connection-refused"},
+		{name: "unexpected eof", err: &url.Error{Op: "Get", URL: "http://x", Err: io.ErrUnexpectedEOF}, expected: "eof"},
+		{name: "anything else", err: errors.New("certificate signed by unknown authority"), expected: "other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := errorClass(tt.err); result != tt.expected {
+				t.Errorf("errorClass() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   TestResult
+		expected bool
+	}{
+		{name: "bad gateway", result: TestResult{Method: "GET", StatusCode: 502}, expected: true},
+		{name: "not found", result: TestResult{Method: "GET", StatusCode: 404}, expected: false},
+		{name: "connection reset", result: TestResult{Method: "GET", Error: syscall.ECONNRESET}, expected: true},
+		{name: "unclassified error", result: TestResult{Method: "GET", Error: errors.New("boom")}, expected: false},
+		{name: "negative certificate check", result: TestResult{Method: "GET", CertMode: certNone, Error: syscall.ECONNRESET}, expected: false},
+		{name: "idempotent write", result: TestResult{Method: "PUT", StatusCode: 503}, expected: true},
+		{name: "non-idempotent write", result: TestResult{Method: "POST", StatusCode: 503}, expected: false},
+		{name: "non-idempotent write after reset", result: TestResult{Method: "DELETE", Error: syscall.ECONNRESET}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := shouldRetry(tt.result); result != tt.expected {
+				t.Errorf("shouldRetry() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRetriedResults(t *testing.T) {
+	results := []TestResult{
+		{Path: "/once", Method: "GET", StatusCode: 200, Attempts: 1},
+		{Path: "/recovered", Method: "GET", StatusCode: 200, Attempts: 2},
+		// Retrying stopped on a status that isn't retried, but the request still failed
+		{Path: "/changed", Method: "GET", StatusCode: 500, Attempts: 2},
+		{Path: "/exhausted", Method: "GET", StatusCode: 503, Attempts: 3},
+	}
+
+	flaky, failed := retriedResults(results)
+	if len(flaky) != 1 || flaky[0].Path != "/recovered" {
+		t.Errorf("flaky = %+v", flaky)
+	}
+	if len(failed) != 2 || failed[0].Path != "/changed" || failed[1].Path != "/exhausted" {
+		t.Errorf("failed = %+v", failed)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	for attempt := 1; attempt <= 100; attempt++ {
+		expected := *retryBackoffMax
+		if attempt <= 10 && *retryBackoffMin<<(attempt-1) < expected {
+			expected = *retryBackoffMin << (attempt - 1)
+		}
+
+		backoff := retryBackoff(attempt)
+		if backoff < expected/2 || backoff > expected {
+			t.Errorf("retryBackoff(%d) = %v, want between %v and %v", attempt, backoff, expected/2, expected)
+		}
+	}
+}
+
+func TestTestEndpointRetriesTransientFailures(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	originalURL, originalBackoff := *baseURL, *retryBackoffMin
+	*baseURL = server.URL
+	*maxAttempts = 3
+	*retryBackoffMin = time.Millisecond
+	defer func() {
+		*baseURL = originalURL
+		*maxAttempts = 1
+		*retryBackoffMin = originalBackoff
+	}()
+
+	result := testEndpoint(context.Background(), publicHealthTarget, credentialVariant{})
+
+	if result.StatusCode != 200 || result.Attempts != 3 {
+		t.Errorf("expected success on attempt 3, got status %d after %d attempts", result.StatusCode, result.Attempts)
+	}
+	if history := describeHistory(result.History); history != "502 -> 502 -> 200" {
+		t.Errorf("describeHistory() = %q, want %q", history, "502 -> 502 -> 200")
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// Safety flags
+var (
+	safeMode  = pflag.String("safe-mode", "", "Policy for methods other than GET, HEAD and OPTIONS: read-only skips them, probe-only sends them without credentials and with an invalid body")
+	denyPaths = pflag.StringSlice("deny-path", nil, "Regex of paths never to send requests to, anchored at the start like Kong regex paths (repeatable)")
+)
+
+// Safe modes
+const (
+	safeReadOnly  = "read-only"
+	safeProbeOnly = "probe-only"
+)
+
+// What the safety policy does with a request
+const (
+	safetySend  = "send"
+	safetyProbe = "probe"
+	safetySkip  = "skip"
+)
+
+// probeBody is the deliberately malformed JSON sent with probe-only writes,
+// so that an upstream reached by mistake rejects the request
+const probeBody = `{"kong-route-tester": "probe-only",`
+
+// denyPatterns are the compiled --deny-path patterns
+var denyPatterns []*regexp.Regexp
+
+// httpMethods are the methods an environment profile can allow
+var httpMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "TRACE", "CONNECT"}
+
+// safetySkipped is a request the safety policy did not send
+type safetySkipped struct {
+	Service string
+	Route   string
+	Path    string
+	Method  string
+	Pass    string // Test pass that would have sent the request
+	Reason  string
+}
+
+// skippedForSafety collects the requests skipped during the run
+var skippedForSafety []safetySkipped
+
+// parseSafetyFlags checks --safe-mode and compiles --deny-path
+func parseSafetyFlags() error {
+	if err := checkSafeMode(*safeMode); err != nil {
+		return err
+	}
+	patterns, err := compileDenyPaths(*denyPaths)
+	if err != nil {
+		return err
+	}
+	denyPatterns = patterns
+	return nil
+}
+
+func checkSafeMode(mode string) error {
+	switch mode {
+	case "", safeReadOnly, safeProbeOnly:
+		return nil
+	}
+	return fmt.Errorf("unknown safe mode %q (want %s or %s)", mode, safeReadOnly, safeProbeOnly)
+}
+
+func compileDenyPaths(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("^(?:" + pattern + ")")
+		if err != nil {
+			return nil, fmt.Errorf("deny path %q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// idempotentMethod reports whether a method only reads; PUT is idempotent
+// but still overwrites data, so it is excluded
+func idempotentMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// safetyAction decides whether a pass may send a request. Denied paths are
+// never sent. Writes are refused by production environments unless allowed
+// for the route, skipped in read-only mode and probed in probe-only mode.
+// Skipped requests are recorded for the summary.
+func safetyAction(pass, service, route, path, method string) string {
+	reason := ""
+	for i, re := range denyPatterns {
+		if re.MatchString(path) {
+			reason = "--deny-path " + (*denyPaths)[i]
+			break
+		}
+	}
+	switch {
+	case reason != "":
+	case idempotentMethod(method):
+		return safetySend
+	case !writeAllowed(route, method):
+		reason = fmt.Sprintf("production environment %s", environmentName)
+	case *safeMode == safeReadOnly:
+		reason = "read-only mode"
+	case *safeMode == safeProbeOnly:
+		return safetyProbe
+	default:
+		return safetySend
+	}
+
+	skipForSafety(pass, service, route, path, method, reason)
+	return safetySkip
+}
+
+// probeCredentials returns how a probe-only write presents credentials:
+// protected routes get none and should answer 401
+func probeCredentials(requiresAuth bool) credentialVariant {
+	if requiresAuth {
+		return credentialVariant{authMode: authNone}
+	}
+	return credentialVariant{}
+}
+
+// neutralizeTemplate replaces a probe's body with invalid JSON and drops
+// fixture headers, which may carry credentials, keeping only the Host routes
+// match on
+func neutralizeTemplate(tmpl *requestTemplate) {
+	tmpl.Body, tmpl.ContentType = []byte(probeBody), "application/json"
+	headers := make(map[string]string)
+	for name, value := range tmpl.Headers {
+		if strings.EqualFold(name, "Host") {
+			headers[name] = value
+		}
+	}
+	tmpl.Headers = headers
+}
+
+// skipForSafety records a request that won't be sent
+func skipForSafety(pass, service, route, path, method, reason string) {
+	skippedForSafety = append(skippedForSafety, safetySkipped{
+		Service: service,
+		Route:   route,
+		Path:    path,
+		Method:  method,
+		Pass:    pass,
+		Reason:  reason,
+	})
+	if *verbose {
+		fmt.Printf("○ %-30s %-40s %-6s     [SAFETY] - %s\n", service, truncate(path, 40), method, reason)
+	}
+}
+
+func printSafetySummary(results []TestResult) {
+	probed := 0
+	for _, result := range results {
+		if result.Probe {
+			probed++
+		}
+	}
+	if len(skippedForSafety) == 0 && probed == 0 {
+		return
+	}
+
+	fmt.Printf("\nSafety (%d requests not sent, %d writes probed):\n", len(skippedForSafety), probed)
+	if probed > 0 {
+		fmt.Printf("  Probed writes were sent without credentials and with an invalid body\n")
+	}
+
+	byReason := make(map[string][]string)
+	var reasons []string
+	for _, s := range skippedForSafety {
+		if _, ok := byReason[s.Reason]; !ok {
+			reasons = append(reasons, s.Reason)
+		}
+		line := fmt.Sprintf("%s %s (%s)", s.Method, s.Path, s.Route)
+		if s.Pass != "routes" {
+			line += " [" + s.Pass + "]"
+		}
+		byReason[s.Reason] = append(byReason[s.Reason], line)
+	}
+	slices.Sort(reasons)
+	for _, reason := range reasons {
+		fmt.Printf("  Skipped for %s:\n", reason)
+		for _, line := range byReason[reason] {
+			fmt.Printf("    - %s\n", line)
+		}
+	}
+	if slices.ContainsFunc(reasons, func(reason string) bool { return strings.HasPrefix(reason, "production environment") }) {
+		fmt.Println("  Allow writes per route with allow_writes in the environment profile")
+	}
+}
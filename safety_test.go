@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// withSafety sets the safety policy for a test
+func withSafety(t *testing.T, mode string, deny []string, env *PlanEnvironment) {
+	t.Helper()
+	originalMode, originalDeny := *safeMode, *denyPaths
+	*safeMode, *denyPaths, environment, environmentName = mode, deny, env, "prod"
+	if err := parseSafetyFlags(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		*safeMode, *denyPaths, environment, environmentName = originalMode, originalDeny, nil, ""
+		denyPatterns, skippedForSafety = nil, nil
+	})
+}
+
+func TestParseSafetyFlags(t *testing.T) {
+	tests := []struct {
+		mode string
+		deny []string
+		err  string
+	}{
+		{mode: "", deny: nil},
+		{mode: "read-only", deny: []string{"/admin", "/api/v[0-9]+/purge"}},
+		{mode: "careful", err: `unknown safe mode "careful"`},
+		{mode: "probe-only", deny: []string{"/admin("}, err: `deny path "/admin("`},
+	}
+
+	for _, tt := range tests {
+		*safeMode, *denyPaths = tt.mode, tt.deny
+		err := parseSafetyFlags()
+		if tt.err == "" && err != nil {
+			t.Errorf("%q %q: unexpected error: %v", tt.mode, tt.deny, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%q %q: expected error containing %q, got %v", tt.mode, tt.deny, tt.err, err)
+		}
+	}
+	*safeMode, *denyPaths, denyPatterns = "", nil, nil
+}
+
+func TestSafetyAction(t *testing.T) {
+	production := &PlanEnvironment{Production: true, AllowWrites: map[string][]string{"webhooks": {"POST"}}}
+	tests := []struct {
+		name   string
+		mode   string
+		deny   []string
+		env    *PlanEnvironment
+		route  string
+		path   string
+		method string
+		want   string
+		reason string
+	}{
+		{name: "no policy", method: "DELETE", want: safetySend},
+		{name: "read-only read", mode: safeReadOnly, method: "GET", want: safetySend},
+		{name: "read-only write", mode: safeReadOnly, method: "PUT", want: safetySkip, reason: "read-only mode"},
+		{name: "probe-only write", mode: safeProbeOnly, method: "DELETE", want: safetyProbe},
+		{name: "probe-only read", mode: safeProbeOnly, method: "HEAD", want: safetySend},
+		{name: "denied path", deny: []string{"/admin"}, path: "/admin/users", method: "GET", want: safetySkip, reason: "--deny-path /admin"},
+		{name: "deny is anchored", deny: []string{"/admin"}, path: "/api/admin", method: "GET", want: safetySend},
+		{name: "production write", env: production, route: "users", method: "POST", want: safetySkip, reason: "production environment prod"},
+		{name: "production allowed write", env: production, route: "webhooks", method: "POST", want: safetySend},
+		{name: "production allowed write, read-only", mode: safeReadOnly, env: production, route: "webhooks", method: "POST", want: safetySkip, reason: "read-only mode"},
+		{name: "production write, probe-only", mode: safeProbeOnly, env: production, route: "users", method: "POST", want: safetySkip, reason: "production environment prod"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withSafety(t, tt.mode, tt.deny, tt.env)
+			path := tt.path
+			if path == "" {
+				path = "/users"
+			}
+
+			if got := safetyAction("routes", "api", tt.route, path, tt.method); got != tt.want {
+				t.Fatalf("safetyAction(%s %s) = %s, want %s", tt.method, path, got, tt.want)
+			}
+			switch {
+			case tt.want != safetySkip && len(skippedForSafety) != 0:
+				t.Errorf("expected nothing recorded, got %+v", skippedForSafety)
+			case tt.want == safetySkip && (len(skippedForSafety) != 1 || skippedForSafety[0].Reason != tt.reason):
+				t.Errorf("expected a skip for %q, got %+v", tt.reason, skippedForSafety)
+			}
+		})
+	}
+}
+
+func TestTestRoutesSafety(t *testing.T) {
+	*dryRun = true
+	defer func() { *dryRun = false }()
+	withSafety(t, safeReadOnly, []string{"/users/purge"}, &PlanEnvironment{Production: true})
+
+	config := &KongConfig{Services: []Service{{
+		Name: "api",
+		Routes: []Route{
+			{Name: "users", Paths: []string{"/users", "/users/purge"}, Methods: []string{"GET", "POST", "DELETE"}},
+		},
+	}}}
+
+	var sent []string
+	for _, result := range testRoutes(context.Background(), config) {
+		sent = append(sent, result.Method+" "+result.Path)
+	}
+	if !slices.Equal(sent, []string{"GET /users"}) {
+		t.Errorf("expected only GET /users to be tested, got %v", sent)
+	}
+	if len(skippedForSafety) != 5 {
+		t.Errorf("expected 5 requests skipped, got %+v", skippedForSafety)
+	}
+}
+
+func TestTestRoutesProbeOnly(t *testing.T) {
+	type request struct {
+		method, auth, body string
+	}
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, request{r.Method, r.Header.Get("Authorization"), string(body)})
+		if r.Header.Get("Authorization") == "" && r.URL.Path == "/private" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	originalURL, originalToken := *baseURL, *authToken
+	*baseURL, *authToken = server.URL, "secret"
+	defer func() { *baseURL, *authToken = originalURL, originalToken }()
+	withSafety(t, safeProbeOnly, nil, nil)
+
+	config := &KongConfig{Services: []Service{{
+		Name: "api",
+		Routes: []Route{
+			{Name: "private", Paths: []string{"/private"}, Methods: []string{"POST"}, Plugins: []Plugin{{Name: "auth"}}},
+			{Name: "public", Paths: []string{"/public"}, Methods: []string{"DELETE"}},
+		},
+	}}}
+
+	results := testRoutes(context.Background(), config)
+	if len(results) != 2 || !results[0].Probe || !results[1].Probe {
+		t.Fatalf("expected two probes, got %+v", results)
+	}
+	if results[0].AuthMode != authNone || results[0].StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected the protected write to be sent without credentials and rejected, got %+v", results[0])
+	}
+	for _, r := range requests {
+		if r.auth != "" || r.body != probeBody {
+			t.Errorf("%s sent with credentials %q or a valid body %q", r.method, r.auth, r.body)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"regexp/syntax"
+	"strings"
+)
+
+// preferredRunes are tried in order when picking an example character from a
+// character class, so that samples stay readable and URL-safe
+const preferredRunes = "a0A-_."
+
+// sampleRegex returns a short string matched by the pattern, or "example" if
+// the pattern cannot be parsed
+func sampleRegex(pattern string) string {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "example"
+	}
+
+	var b strings.Builder
+	writeSample(&b, re.Simplify())
+	return b.String()
+}
+
+func writeSample(b *strings.Builder, re *syntax.Regexp) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			b.WriteRune(r)
+		}
+	case syntax.OpCharClass:
+		b.WriteRune(sampleRune(re.Rune))
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		b.WriteRune('x')
+	case syntax.OpCapture:
+		writeSample(b, re.Sub[0])
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			writeSample(b, sub)
+		}
+	case syntax.OpAlternate:
+		writeSample(b, re.Sub[0])
+	case syntax.OpPlus:
+		writeSample(b, re.Sub[0])
+	case syntax.OpRepeat:
+		for i := 0; i < re.Min; i++ {
+			writeSample(b, re.Sub[0])
+		}
+	}
+	// Star, quest, anchors and empty matches contribute nothing
+}
+
+// sampleRune picks a rune from a character class given as sorted range pairs
+func sampleRune(ranges []rune) rune {
+	for _, preferred := range preferredRunes {
+		for i := 0; i+1 < len(ranges); i += 2 {
+			if preferred >= ranges[i] && preferred <= ranges[i+1] {
+				return preferred
+			}
+		}
+	}
+
+	// Skip control characters when the class starts with them
+	for i := 0; i+1 < len(ranges); i += 2 {
+		if ranges[i+1] > ' ' {
+			return max(ranges[i], '!')
+		}
+	}
+	if len(ranges) > 0 {
+		return ranges[0]
+	}
+	return 'x'
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestSampleRegex(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		expected string
+	}{
+		{name: "uuid", pattern: `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`, expected: "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"},
+		{name: "digits", pattern: `\d+`, expected: "0"},
+		{name: "alternation picks first", pattern: `(stripe|paypal)`, expected: "stripe"},
+		{name: "optional parts are dropped", pattern: `^https?:\/\/localhost(:\d+)?$`, expected: "http://localhost"},
+		{name: "negated class", pattern: `[^/]+`, expected: "a"},
+		{name: "invalid pattern", pattern: `(unclosed`, expected: "example"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := sampleRegex(tt.pattern)
+			if result != tt.expected {
+				t.Errorf("sampleRegex() = %q, want %q", result, tt.expected)
+			}
+			if tt.expected != "example" && !regexp.MustCompile(`^(?:`+tt.pattern+`)$`).MatchString(result) {
+				t.Errorf("sample %q does not match %s", result, tt.pattern)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+)
+
+// maxRefDepth bounds $ref chains and nesting so recursive schemas terminate
+const maxRefDepth = 8
+
+// Schema is the subset of an OpenAPI 3.0 schema object used to generate and
+// validate values
+type Schema struct {
+	Ref        string             `yaml:"$ref"`
+	Type       string             `yaml:"type"`
+	Format     string             `yaml:"format"`
+	Enum       []interface{}      `yaml:"enum"`
+	Default    interface{}        `yaml:"default"`
+	Example    interface{}        `yaml:"example"`
+	Nullable   bool               `yaml:"nullable"`
+	ReadOnly   bool               `yaml:"readOnly"`
+	Properties map[string]*Schema `yaml:"properties"`
+	Required   []string           `yaml:"required"`
+	Items      *Schema            `yaml:"items"`
+	AllOf      []*Schema          `yaml:"allOf"`
+	OneOf      []*Schema          `yaml:"oneOf"`
+	AnyOf      []*Schema          `yaml:"anyOf"`
+	Minimum    *float64           `yaml:"minimum"`
+	Maximum    *float64           `yaml:"maximum"`
+	MinLength  *int               `yaml:"minLength"`
+	MaxLength  *int               `yaml:"maxLength"`
+	MinItems   *int               `yaml:"minItems"`
+	Pattern    string             `yaml:"pattern"`
+}
+
+// Example values for common string formats
+var formatSamples = map[string]string{
+	"uuid":      "123e4567-e89b-12d3-a456-426614174000",
+	"date":      "2024-01-01",
+	"date-time": "2024-01-01T00:00:00Z",
+	"email":     "user@example.com",
+	"uri":       "https://example.com",
+	"hostname":  "example.com",
+	"ipv4":      "192.0.2.1",
+	"byte":      "ZXhhbXBsZQ==",
+}
+
+func (s *OpenAPISpec) schema(schema *Schema) *Schema {
+	for i := 0; schema != nil && schema.Ref != "" && i < maxRefDepth; i++ {
+		name, _ := localRef(schema.Ref, "schemas")
+		schema = s.Components.Schemas[name]
+	}
+	return schema
+}
+
+// generateValue returns an example value that satisfies the schema, preferring
+// documented examples, defaults and enums. Read-only properties are left out
+// since the value is sent as a request.
+func generateValue(spec *OpenAPISpec, schema *Schema, depth int) interface{} {
+	schema = spec.schema(schema)
+	if schema == nil || depth > maxRefDepth {
+		return nil
+	}
+
+	switch {
+	case schema.Example != nil:
+		return schema.Example
+	case schema.Default != nil:
+		return schema.Default
+	case len(schema.Enum) > 0:
+		return schema.Enum[0]
+	case len(schema.AllOf) > 0:
+		merged := make(map[string]interface{})
+		for _, sub := range schema.AllOf {
+			if fields, ok := generateValue(spec, sub, depth+1).(map[string]interface{}); ok {
+				for name, value := range fields {
+					merged[name] = value
+				}
+			}
+		}
+		return merged
+	case len(schema.OneOf) > 0:
+		return generateValue(spec, schema.OneOf[0], depth+1)
+	case len(schema.AnyOf) > 0:
+		return generateValue(spec, schema.AnyOf[0], depth+1)
+	}
+
+	switch schemaType(schema) {
+	case "object":
+		fields := make(map[string]interface{})
+		for name, property := range schema.Properties {
+			property = spec.schema(property)
+			if property == nil || property.ReadOnly {
+				continue
+			}
+			fields[name] = generateValue(spec, property, depth+1)
+		}
+		return fields
+	case "array":
+		count := 1
+		if schema.MinItems != nil && *schema.MinItems > count {
+			count = *schema.MinItems
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			items[i] = generateValue(spec, schema.Items, depth+1)
+		}
+		return items
+	case "integer":
+		return int(boundedNumber(schema, 1))
+	case "number":
+		return boundedNumber(schema, 1.5)
+	case "boolean":
+		return true
+	}
+	return generateString(schema)
+}
+
+// schemaType returns the declared type, inferring object and array from the
+// keywords present when the type is omitted
+func schemaType(schema *Schema) string {
+	switch {
+	case schema.Type != "":
+		return schema.Type
+	case len(schema.Properties) > 0:
+		return "object"
+	case schema.Items != nil:
+		return "array"
+	}
+	return "string"
+}
+
+func boundedNumber(schema *Schema, fallback float64) float64 {
+	value := fallback
+	if schema.Minimum != nil && value < *schema.Minimum {
+		value = math.Ceil(*schema.Minimum)
+	}
+	if schema.Maximum != nil && value > *schema.Maximum {
+		value = math.Floor(*schema.Maximum)
+	}
+	return value
+}
+
+func generateString(schema *Schema) string {
+	value, ok := formatSamples[schema.Format]
+	if !ok {
+		value = "example"
+	}
+	if schema.Pattern != "" {
+		value = sampleRegex(schema.Pattern)
+	}
+
+	for schema.MinLength != nil && len(value) < *schema.MinLength {
+		value += "x"
+	}
+	if schema.MaxLength != nil && len(value) > *schema.MaxLength {
+		value = value[:*schema.MaxLength]
+	}
+	return value
+}
+
+// decodeJSON decodes a response body keeping numbers as float64
+func decodeJSON(body []byte) (interface{}, error) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// validateValue checks a decoded JSON value against a schema, returning a
+// description of each mismatch prefixed with its location
+func validateValue(spec *OpenAPISpec, schema *Schema, value interface{}, location string, depth int) []string {
+	schema = spec.schema(schema)
+	if schema == nil || depth > maxRefDepth {
+		return nil
+	}
+
+	if value == nil {
+		if schema.Nullable || (schema.Type == "" && len(schema.Properties) == 0 && schema.Items == nil) {
+			return nil
+		}
+		return []string{location + ": is null"}
+	}
+
+	var problems []string
+	for _, sub := range schema.AllOf {
+		problems = append(problems, validateValue(spec, sub, value, location, depth+1)...)
+	}
+	for _, alternatives := range [][]*Schema{schema.OneOf, schema.AnyOf} {
+		if len(alternatives) > 0 && !matchesAny(spec, alternatives, value, location, depth) {
+			problems = append(problems, location+": matches none of the documented alternatives")
+		}
+	}
+
+	if len(schema.Enum) > 0 && !containsValue(schema.Enum, value) {
+		problems = append(problems, fmt.Sprintf("%s: %v is not one of %v", location, value, schema.Enum))
+	}
+
+	if schema.Type == "" && len(schema.Properties) == 0 && schema.Items == nil {
+		return problems
+	}
+
+	switch schemaType(schema) {
+	case "object":
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return append(problems, fmt.Sprintf("%s: expected object, got %s", location, jsonType(value)))
+		}
+		for _, name := range schema.Required {
+			if _, ok := fields[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required property %q", location, name))
+			}
+		}
+		names := make([]string, 0, len(schema.Properties))
+		for name := range schema.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if field, ok := fields[name]; ok {
+				problems = append(problems, validateValue(spec, schema.Properties[name], field, location+"."+name, depth+1)...)
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return append(problems, fmt.Sprintf("%s: expected array, got %s", location, jsonType(value)))
+		}
+		for i, item := range items {
+			problems = append(problems, validateValue(spec, schema.Items, item, fmt.Sprintf("%s[%d]", location, i), depth+1)...)
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != math.Trunc(n) {
+			problems = append(problems, fmt.Sprintf("%s: expected integer, got %s", location, jsonType(value)))
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			problems = append(problems, fmt.Sprintf("%s: expected number, got %s", location, jsonType(value)))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			problems = append(problems, fmt.Sprintf("%s: expected boolean, got %s", location, jsonType(value)))
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: expected string, got %s", location, jsonType(value)))
+			break
+		}
+		if schema.Pattern != "" {
+			if re, err := regexp.Compile(schema.Pattern); err == nil && !re.MatchString(s) {
+				problems = append(problems, fmt.Sprintf("%s: %q does not match %s", location, s, schema.Pattern))
+			}
+		}
+	}
+	return problems
+}
+
+func matchesAny(spec *OpenAPISpec, alternatives []*Schema, value interface{}, location string, depth int) bool {
+	for _, alternative := range alternatives {
+		if len(validateValue(spec, alternative, value, location, depth+1)) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if fmt.Sprint(v) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType names the JSON type of a decoded value for error messages
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "null"
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGenerateValue(t *testing.T) {
+	spec := &OpenAPISpec{}
+	minLength, minItems := 10, 2
+	minimum := 3.0
+
+	tests := []struct {
+		name     string
+		schema   *Schema
+		expected interface{}
+	}{
+		{name: "example wins", schema: &Schema{Type: "string", Example: "documented", Enum: []interface{}{"other"}}, expected: "documented"},
+		{name: "enum", schema: &Schema{Type: "string", Enum: []interface{}{"active", "disabled"}}, expected: "active"},
+		{name: "format", schema: &Schema{Type: "string", Format: "date-time"}, expected: "2024-01-01T00:00:00Z"},
+		{name: "pattern", schema: &Schema{Type: "string", Pattern: "^[A-Z]{3}-[0-9]{2}$"}, expected: "AAA-00"},
+		{name: "min length", schema: &Schema{Type: "string", MinLength: &minLength}, expected: "examplexxx"},
+		{name: "integer minimum", schema: &Schema{Type: "integer", Minimum: &minimum}, expected: 3},
+		{name: "array min items", schema: &Schema{Type: "array", MinItems: &minItems, Items: &Schema{Type: "boolean"}}, expected: []interface{}{true, true}},
+		{name: "one of", schema: &Schema{OneOf: []*Schema{{Type: "integer"}, {Type: "string"}}}, expected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := generateValue(spec, tt.schema, 0); !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("generateValue() = %#v, want %#v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidateValue(t *testing.T) {
+	spec := &OpenAPISpec{Components: OpenAPIComponents{Schemas: map[string]*Schema{
+		"Node": {Type: "object", Properties: map[string]*Schema{
+			"name":     {Type: "string"},
+			"children": {Type: "array", Items: &Schema{Ref: "#/components/schemas/Node"}},
+		}},
+	}}}
+
+	tests := []struct {
+		name     string
+		schema   *Schema
+		body     string
+		expected []string
+	}{
+		{name: "recursive ref", schema: &Schema{Ref: "#/components/schemas/Node"}, body: `{"name": "a", "children": [{"name": 1}]}`, expected: []string{"body.children[0].name: expected string, got number"}},
+		{name: "nullable", schema: &Schema{Type: "string", Nullable: true}, body: `null`},
+		{name: "not nullable", schema: &Schema{Type: "string"}, body: `null`, expected: []string{"body: is null"}},
+		{name: "integer", schema: &Schema{Type: "integer"}, body: `1.5`, expected: []string{"body: expected integer, got number"}},
+		{name: "any of", schema: &Schema{AnyOf: []*Schema{{Type: "integer"}, {Type: "boolean"}}}, body: `"x"`, expected: []string{"body: matches none of the documented alternatives"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := decodeJSON([]byte(tt.body))
+			if err != nil {
+				t.Fatalf("decodeJSON() error = %v", err)
+			}
+			if result := validateValue(spec, tt.schema, value, "body", 0); !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("validateValue() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
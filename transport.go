@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// HTTP transport flags
+var (
+	requestTimeout = pflag.Duration("timeout", 10*time.Second, "Total time limit for each request")
+	dialTimeout    = pflag.Duration("dial-timeout", 5*time.Second, "Time limit for establishing TCP connections")
+	tlsTimeout     = pflag.Duration("tls-timeout", 5*time.Second, "Time limit for TLS handshakes")
+	headerTimeout  = pflag.Duration("header-timeout", 10*time.Second, "Time limit for waiting on response headers")
+	keepAlive      = pflag.Bool("keep-alive", true, "Reuse connections between requests")
+	maxIdleConns   = pflag.Int("max-idle-conns", 100, "Maximum idle connections kept open per host")
+	enableHTTP2    = pflag.Bool("http2", true, "Negotiate HTTP/2 with TLS gateways")
+	proxyURL       = pflag.String("proxy", "", "HTTP proxy URL (defaults to HTTP_PROXY/HTTPS_PROXY/NO_PROXY)")
+	unixSocket     = pflag.String("unix-socket", "", "Connect to the gateway through a Unix domain socket")
+)
+
+// Timings records how long each phase of a request took
+type Timings struct {
+	DNS       time.Duration
+	Connect   time.Duration
+	TLS       time.Duration
+	FirstByte time.Duration
+	Total     time.Duration
+	Reused    bool
+}
+
+// String lists the phases of the request, leaving out the connection phases
+// of requests that reused a connection
+func (t Timings) String() string {
+	ms := func(d time.Duration) string { return d.Round(time.Millisecond).String() }
+	parts := []string{"total " + ms(t.Total)}
+	if t.Reused {
+		parts = append(parts, "reused connection")
+	} else {
+		if t.DNS > 0 {
+			parts = append(parts, "dns "+ms(t.DNS))
+		}
+		if t.Connect > 0 {
+			parts = append(parts, "connect "+ms(t.Connect))
+		}
+		if t.TLS > 0 {
+			parts = append(parts, "tls "+ms(t.TLS))
+		}
+	}
+	if t.FirstByte > 0 {
+		parts = append(parts, "first byte "+ms(t.FirstByte))
+	}
+	return strings.Join(parts, ", ")
+}
+
+var (
+	clientsMu sync.Mutex
+	clients   = make(map[string]*http.Client)
+)
+
+// clientFor returns the shared HTTP client for a route. Clients are shared by
+// every route presenting the same client certificate so connections are reused.
+func clientFor(service, route, certMode string) (*http.Client, error) {
+	certFile, keyFile := clientCertFor(service, route)
+	key := certMode
+	if certMode == "" {
+		key = certFile + "\x00" + keyFile
+	}
+
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	if client, ok := clients[key]; ok {
+		return client, nil
+	}
+
+	tlsConfig, err := tlsConfigFor(service, route, certMode)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := newTransport(tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   *requestTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse // Don't follow redirects
+		},
+	}
+	clients[key] = client
+	return client, nil
+}
+
+func newTransport(tlsConfig *tls.Config) (*http.Transport, error) {
+	dialer := &net.Dialer{
+		Timeout:   *dialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   *tlsTimeout,
+		ResponseHeaderTimeout: *headerTimeout,
+		DisableKeepAlives:     !*keepAlive,
+		MaxIdleConns:          *maxIdleConns,
+		MaxIdleConnsPerHost:   *maxIdleConns,
+		IdleConnTimeout:       90 * time.Second,
+		ForceAttemptHTTP2:     *enableHTTP2,
+	}
+
+	if !*enableHTTP2 {
+		// A non-nil empty map disables the automatic HTTP/2 upgrade
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	if *proxyURL != "" {
+		proxy, err := url.Parse(*proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if *unixSocket != "" {
+		socket := *unixSocket
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+	}
+
+	return transport, nil
+}
+
+// phaseTimer collects request phase timings from httptrace callbacks, which
+// may fire on different goroutines when several addresses are dialled
+type phaseTimer struct {
+	mu        sync.Mutex
+	start     time.Time
+	dnsStart  time.Time
+	dialStart time.Time
+	tlsStart  time.Time
+	timings   Timings
+
+	handshakeErr error
+}
+
+// traceRequest attaches a phase timer to the request
+func traceRequest(req *http.Request) (*http.Request, *phaseTimer) {
+	timer := &phaseTimer{start: time.Now()}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			timer.record(func() { timer.dnsStart = time.Now() })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			timer.record(func() { timer.timings.DNS = time.Since(timer.dnsStart) })
+		},
+		ConnectStart: func(string, string) {
+			timer.record(func() { timer.dialStart = time.Now() })
+		},
+		ConnectDone: func(string, string, error) {
+			timer.record(func() { timer.timings.Connect = time.Since(timer.dialStart) })
+		},
+		TLSHandshakeStart: func() {
+			timer.record(func() { timer.tlsStart = time.Now() })
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			timer.record(func() {
+				timer.timings.TLS = time.Since(timer.tlsStart)
+				timer.handshakeErr = err
+			})
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			timer.record(func() { timer.timings.Reused = info.Reused })
+		},
+		GotFirstResponseByte: func() {
+			timer.record(func() { timer.timings.FirstByte = time.Since(timer.start) })
+		},
+	}
+
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace)), timer
+}
+
+func (t *phaseTimer) record(update func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	update()
+}
+
+// handshakeFailed reports whether the TLS handshake ended in an error
+func (t *phaseTimer) handshakeFailed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.handshakeErr != nil
+}
+
+// done stops the timer and returns the collected timings
+func (t *phaseTimer) done() Timings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.timings.Total = time.Since(t.start)
+	return t.timings
+}
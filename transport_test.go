@@ -0,0 +1,163 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func resetClients() {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	clients = make(map[string]*http.Client)
+}
+
+func TestClientForSharesClients(t *testing.T) {
+	resetClients()
+	defer resetClients()
+
+	first, err := clientFor("public-api", "public-endpoints", "")
+	if err != nil {
+		t.Fatalf("clientFor() error = %v", err)
+	}
+	second, err := clientFor("media-service", "media-assets", "")
+	if err != nil {
+		t.Fatalf("clientFor() error = %v", err)
+	}
+	if first != second {
+		t.Error("expected routes with the same TLS identity to share a client")
+	}
+
+	untrusted, err := clientFor("public-api", "public-endpoints", certUntrusted)
+	if err != nil {
+		t.Fatalf("clientFor() error = %v", err)
+	}
+	if untrusted == first {
+		t.Error("expected the untrusted certificate mode to use its own client")
+	}
+}
+
+func TestNewTransportHTTP2(t *testing.T) {
+	defer func() { *enableHTTP2 = true }()
+
+	*enableHTTP2 = true
+	transport, err := newTransport(nil)
+	if err != nil {
+		t.Fatalf("newTransport() error = %v", err)
+	}
+	if !transport.ForceAttemptHTTP2 || transport.TLSNextProto != nil {
+		t.Error("expected HTTP/2 to be negotiated when enabled")
+	}
+
+	*enableHTTP2 = false
+	transport, err = newTransport(nil)
+	if err != nil {
+		t.Fatalf("newTransport() error = %v", err)
+	}
+	if transport.ForceAttemptHTTP2 || transport.TLSNextProto == nil {
+		t.Error("expected HTTP/2 to be disabled")
+	}
+}
+
+func TestNewTransportInvalidProxy(t *testing.T) {
+	*proxyURL = "://missing-scheme"
+	defer func() { *proxyURL = "" }()
+
+	if _, err := newTransport(nil); err == nil {
+		t.Error("expected error for invalid proxy URL, got nil")
+	}
+}
+
+func TestNewTransportUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "gateway.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.URL.Path)
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	*unixSocket = socket
+	defer func() { *unixSocket = "" }()
+
+	transport, err := newTransport(nil)
+	if err != nil {
+		t.Fatalf("newTransport() error = %v", err)
+	}
+
+	// The host is ignored, every connection goes through the socket
+	resp, err := (&http.Client{Transport: transport}).Get("http://gateway.invalid/api/v1/public/health")
+	if err != nil {
+		t.Fatalf("request through unix socket failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "/api/v1/public/health" {
+		t.Errorf("unexpected response body %q", body)
+	}
+}
+
+func TestTraceRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{}}
+
+	for i, expectReused := range []bool{false, true} {
+		req, err := http.NewRequest("GET", server.URL, nil)
+		if err != nil {
+			t.Fatalf("NewRequest() error = %v", err)
+		}
+
+		req, timer := traceRequest(req)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("request %d failed: %v", i, err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		timings := timer.done()
+		if timings.FirstByte <= 0 || timings.Total < timings.FirstByte {
+			t.Errorf("request %d: unexpected timings %+v", i, timings)
+		}
+		if timings.Reused != expectReused {
+			t.Errorf("request %d: Reused = %v, want %v", i, timings.Reused, expectReused)
+		}
+		if !expectReused && timings.Connect <= 0 {
+			t.Errorf("request %d: expected connect time for a new connection", i)
+		}
+	}
+}
+
+func TestTimingsString(t *testing.T) {
+	tests := []struct {
+		name    string
+		timings Timings
+		want    string
+	}{
+		{"new connection", Timings{DNS: 2 * time.Millisecond, Connect: 3 * time.Millisecond, TLS: 10 * time.Millisecond, FirstByte: 40 * time.Millisecond, Total: 42 * time.Millisecond},
+			"total 42ms, dns 2ms, connect 3ms, tls 10ms, first byte 40ms"},
+		{"plain http", Timings{Connect: time.Millisecond, FirstByte: 5 * time.Millisecond, Total: 6 * time.Millisecond},
+			"total 6ms, connect 1ms, first byte 5ms"},
+		{"reused", Timings{Reused: true, FirstByte: 5 * time.Millisecond, Total: 6 * time.Millisecond},
+			"total 6ms, reused connection, first byte 5ms"},
+	}
+	for _, tt := range tests {
+		if got := tt.timings.String(); got != tt.want {
+			t.Errorf("%s: String() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/danpilch/kong-route-tester/kong"
+	"github.com/spf13/pflag"
+)
+
+// assertUpstreamPath checks the path an echo upstream reports against the
+// path Kong is expected to forward
+var assertUpstreamPath = pflag.Bool("assert-upstream-path", false, "Check that an echo upstream received the expected path (reads \"path\" or \"url\" from JSON responses)")
+
+// upstreamURL returns the full URL the upstream receives for a request
+func upstreamURL(target requestTarget, query url.Values) string {
+	u := kong.ServiceOrigin(target.Service) + kong.UpstreamPath(target.Service, target.Route, target.Pattern, target.Path)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+// echoedPath extracts the request path an echo upstream reports in its JSON
+// response, as either a "path" or a "url" field
+func echoedPath(body []byte) (string, bool) {
+	var echo map[string]interface{}
+	if err := json.Unmarshal(body, &echo); err != nil {
+		return "", false
+	}
+	if path, ok := echo["path"].(string); ok {
+		return path, true
+	}
+	if raw, ok := echo["url"].(string); ok {
+		if u, err := url.Parse(raw); err == nil {
+			return u.Path, true
+		}
+	}
+	return "", false
+}
+
+// checkUpstreamPath compares the path an echo upstream saw with the expected
+// upstream path, returning a description of any mismatch. Responses without
+// an echoed path only fail when they claim success, since error responses
+// may come from Kong itself.
+func checkUpstreamPath(expected string, status int, body []byte) string {
+	observed, ok := echoedPath(body)
+	if !ok {
+		if status >= 200 && status < 400 {
+			return "upstream response did not include the path it received"
+		}
+		return ""
+	}
+	if observed != expected {
+		return fmt.Sprintf("upstream received %s, expected %s", observed, expected)
+	}
+	return ""
+}
+
+// printUpstreamSummary lists requests whose upstream saw an unexpected path
+func printUpstreamSummary(results []TestResult) {
+	if !*assertUpstreamPath {
+		return
+	}
+
+	fmt.Println("\nUpstream Path Mismatches:")
+	mismatches := 0
+	for _, result := range results {
+		if result.UpstreamError != "" {
+			fmt.Printf("  - %s %s (%s/%s): %s\n", result.Method, result.Path, result.Service, result.Route, result.UpstreamError)
+			mismatches++
+		}
+	}
+	if mismatches == 0 {
+		fmt.Println("  None")
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestGatewayPathFor(t *testing.T) {
+	noStrip := false
+
+	tests := []struct {
+		name     string
+		service  Service
+		route    Route
+		pattern  string
+		upstream string
+		expected string
+		ok       bool
+	}{
+		{name: "prefix route strips", service: Service{URL: "http://users:8080/v2"}, pattern: "/users", upstream: "/v2/accounts/1", expected: "/users/accounts/1", ok: true},
+		{name: "prefix route keeps path", service: Service{URL: "http://users:8080"}, route: Route{StripPath: &noStrip}, pattern: "/users", upstream: "/users/1", expected: "/users/1", ok: true},
+		{name: "outside service path", service: Service{URL: "http://users:8080/v2"}, pattern: "/users", upstream: "/v1/accounts", ok: false},
+		{name: "outside route prefix", service: Service{URL: "http://users:8080"}, route: Route{StripPath: &noStrip}, pattern: "/users", upstream: "/orders/1", ok: false},
+		{name: "regex route", service: Service{URL: "http://users:8080"}, route: Route{StripPath: &noStrip}, pattern: "/users/(?<user_id>[^/]+)$", upstream: "/users/abc", expected: "/users/abc", ok: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, ok := gatewayPathFor(tt.service, tt.route, tt.pattern, tt.upstream)
+			if ok != tt.ok || path != tt.expected {
+				t.Errorf("gatewayPathFor() = %q, %v, want %q, %v", path, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
+
+func TestUpstreamURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		service  Service
+		expected string
+	}{
+		{name: "service url", service: Service{URL: "https://users.internal:8443/api"}, expected: "https://users.internal:8443/api/42?expand=profile"},
+		{name: "host and port", service: Service{Host: "users.internal", Port: 8080, Path: "/api"}, expected: "http://users.internal:8080/api/42?expand=profile"},
+		{name: "https default port", service: Service{Protocol: "https", Host: "users.internal"}, expected: "https://users.internal:443/42?expand=profile"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := requestTarget{Service: tt.service, Pattern: "/users", Path: "/users/42"}
+			if result := upstreamURL(target, url.Values{"expand": {"profile"}}); result != tt.expected {
+				t.Errorf("upstreamURL() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCheckUpstreamPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		expected string
+	}{
+		{name: "path matches", status: 200, body: `{"path": "/internal/42"}`},
+		{name: "url matches", status: 200, body: `{"url": "http://users:8080/internal/42?x=1"}`},
+		{name: "path differs", status: 200, body: `{"path": "/42"}`, expected: "upstream received /42, expected /internal/42"},
+		{name: "success without echo", status: 200, body: `ok`, expected: "upstream response did not include the path it received"},
+		{name: "kong rejection", status: 401, body: `{"message": "Unauthorized"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := checkUpstreamPath("/internal/42", tt.status, []byte(tt.body)); result != tt.expected {
+				t.Errorf("checkUpstreamPath() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTestEndpointAssertsUpstreamPath(t *testing.T) {
+	// Stands in for Kong and an echo upstream, forwarding paths unchanged
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"path": r.URL.Path})
+	}))
+	defer server.Close()
+
+	originalURL := *baseURL
+	*baseURL = server.URL
+	*assertUpstreamPath = true
+	defer func() {
+		*baseURL = originalURL
+		*assertUpstreamPath = false
+	}()
+
+	noStrip := false
+	target := requestTarget{
+		Service: Service{Name: "public-service", URL: "http://public:8080"},
+		Method:  "GET",
+		Pattern: "/api/v1/public",
+		Path:    "/api/v1/public/health",
+	}
+
+	target.Route = Route{Name: "public", StripPath: &noStrip}
+	if result := testEndpoint(context.Background(), target, credentialVariant{}); result.UpstreamError != "" {
+		t.Errorf("unexpected upstream error without strip_path: %s", result.UpstreamError)
+	}
+
+	target.Route = Route{Name: "public"}
+	result := testEndpoint(context.Background(), target, credentialVariant{})
+	if expected := "upstream received /api/v1/public/health, expected /health"; result.UpstreamError != expected {
+		t.Errorf("UpstreamError = %q, want %q", result.UpstreamError, expected)
+	}
+	if result.UpstreamURL != "http://public:8080/health" {
+		t.Errorf("UpstreamURL = %q, want %q", result.UpstreamURL, "http://public:8080/health")
+	}
+}